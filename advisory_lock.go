@@ -0,0 +1,76 @@
+package cursoriterator
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+// advisoryLockQuery tries to acquire a transaction level advisory lock for every key
+// and returns whether the locks were acquired, in the order of the keys.
+const advisoryLockQuery = `SELECT array_agg(pg_try_advisory_xact_lock(key) ORDER BY ord) ` +
+	`FROM unnest($1::bigint[]) WITH ORDINALITY AS keys(key, ord)`
+
+// WithAdvisoryLock lets the iterator acquire a transaction level advisory lock (pg_try_advisory_xact_lock)
+// for every fetched row, keyFn returns the lock key of a row. Rows that are already locked by another
+// transaction are skipped, so multiple iterators over the same query can be used as a work queue without
+// processing a row twice. The amount of skipped rows can be retrieved with LockSkippedCount().
+//
+// The locks are held until the iterator is closed. T must match the element type of the values slice.
+func WithAdvisoryLock[T any](keyFn func(value T) int64) Option {
+	return func(iter *CursorIterator) error {
+		if keyFn == nil {
+			return errors.New("advisory lock key function cannot be nil")
+		}
+		if _, ok := iter.valuesRef.([]T); !ok {
+			return errors.Errorf("advisory lock expects %T, but values is %T", []T(nil), iter.valuesRef)
+		}
+		// for pointer elements the scan destinations are the elements themselves, so they must be
+		// moved together with the elements
+		pointerElements := reflect.TypeOf((*T)(nil)).Elem().Kind() == reflect.Ptr
+
+		iter.lockRows = func(ctx context.Context, n int) (int, error) {
+			// use the current buffer, it might have been replaced by WithBufferPool()
+			values := iter.valuesRef.([]T)[:n]
+			keys := make([]int64, n)
+			for i := range values {
+				keys[i] = keyFn(values[i])
+			}
+			var locked []bool
+			if err := iter.tx.QueryRow(ctx, advisoryLockQuery, keys).Scan(&locked); err != nil {
+				return 0, err
+			}
+			if len(locked) != n {
+				return 0, errors.Errorf("expected %d lock results, got %d", n, len(locked))
+			}
+
+			// move the locked rows to the front
+			kept := 0
+			for i := range values {
+				if !locked[i] {
+					iter.lockSkippedCount++
+					continue
+				}
+				if i != kept {
+					values[kept], values[i] = values[i], values[kept]
+					if pointerElements {
+						iter.values[kept], iter.values[i] = iter.values[i], iter.values[kept]
+					}
+				}
+				kept++
+			}
+			return kept, nil
+		}
+		return nil
+	}
+}
+
+// LockSkippedCount returns the amount of rows that were skipped because their advisory lock was held
+// by another transaction, see WithAdvisoryLock().
+func (iter *CursorIterator) LockSkippedCount() int64 {
+	iter.mu.Lock()
+	n := iter.lockSkippedCount
+	iter.mu.Unlock()
+	return n
+}
@@ -0,0 +1,70 @@
+package cursoriterator_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+
+	cursoriterator "github.com/Eun/go-pgx-cursor-iterator/v2"
+)
+
+func TestWithAdvisoryLock(t *testing.T) {
+	t.Parallel()
+	users := []User{
+		{1, "Joe"},
+		{2, "Alice"},
+		{3, "Bob"},
+		{4, "Mike"},
+		{5, "Maria"},
+		{6, "Tom"},
+		{7, "Anna"},
+	}
+	runTest(t, users, func(pool *pgxpool.Pool) {
+		newIterator := func() *cursoriterator.TypedCursorIterator[User] {
+			iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+				pool,
+				2,
+				[]cursoriterator.Option{
+					cursoriterator.WithAdvisoryLock(func(u User) int64 {
+						return int64(u.ID)
+					}),
+				},
+				"SELECT * FROM users ORDER BY id",
+			)
+			require.NoError(t, err)
+			return iter
+		}
+
+		ctx := context.Background()
+		worker1 := newIterator()
+		worker2 := newIterator()
+
+		// process the rows alternately, a finished iterator releases its locks,
+		// so stop as soon as all rows were processed
+		processed := make(map[int]int)
+		for len(processed) < len(users) {
+			next1 := worker1.Next(ctx)
+			if next1 {
+				processed[worker1.Value().ID]++
+			}
+			next2 := worker2.Next(ctx)
+			if next2 {
+				processed[worker2.Value().ID]++
+			}
+			require.True(t, next1 || next2)
+		}
+		require.NoError(t, worker1.Error())
+		require.NoError(t, worker2.Error())
+
+		for id, n := range processed {
+			require.Equal(t, 1, n, "user %d was processed %d times", id, n)
+		}
+		require.Positive(t, worker1.LockSkippedCount())
+		require.Positive(t, worker2.LockSkippedCount())
+
+		require.NoError(t, worker1.Close(ctx))
+		require.NoError(t, worker2.Close(ctx))
+	})
+}
@@ -0,0 +1,79 @@
+package cursoriterator
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+// BatchIterators creates an iterator for every set of arguments in argSets, all running the same query.
+// It is intended for fan-out queries, e.g. iterating over the same query for many tenants.
+// Every iterator stores its rows in its own []T with a capacity of batchSize, which can be accessed with Values().
+//
+// The validation is shared by all iterators: the arguments of every set are checked against the placeholders
+// of the query, which is parsed once, and the query is validated with Validate() once, using the first set.
+// If maxExec is bigger than 0 every iterator executes at most maxExec fetches and stops with ErrMaxBatchesExceeded
+// if more rows follow, see WithMaxBatches(). This keeps a single arg set from dominating the fan-out.
+//
+// Example Usage:
+//
+//	iters, err := BatchIterators[User](ctx, pool, 1000, 4, "SELECT * FROM users WHERE tenant = $1", [][]interface{}{
+//		{"tenant-a"},
+//		{"tenant-b"},
+//	})
+//	for _, iter := range iters {
+//		values := iter.Values().([]User)
+//		for iter.Next(ctx) {
+//			fmt.Printf("Name: %s\n", values[iter.ValueIndex()].Name)
+//		}
+//	}
+func BatchIterators[T any](
+	ctx context.Context,
+	connector PgxConnector,
+	batchSize int,
+	maxExec int,
+	query string,
+	argSets [][]interface{},
+) ([]*CursorIterator, error) {
+	if connector == nil {
+		return nil, errors.New("connector cannot be nil")
+	}
+	if batchSize <= 0 {
+		return nil, errors.New("batch size must be bigger than 0")
+	}
+	if len(argSets) == 0 {
+		return nil, errors.New("arg sets cannot be empty")
+	}
+	if maxExec < 0 {
+		return nil, errors.New("max exec cannot be negative")
+	}
+	if placeholders, ok := countPlaceholders(query); ok {
+		for i, args := range argSets {
+			if len(args) != placeholders {
+				return nil, errors.Errorf("query has %d placeholders but arg set %d has %d args", placeholders, i, len(args))
+			}
+		}
+	}
+
+	var options []Option
+	if maxExec > 0 {
+		options = append(options, WithMaxBatches(maxExec))
+	}
+
+	iters := make([]*CursorIterator, 0, len(argSets))
+	for _, args := range argSets {
+		values := make([]T, batchSize)
+		iter, err := newCursorIterator(
+			connector, values, scanDestinations(reflect.ValueOf(values)), true, options, query, args...,
+		)
+		if err != nil {
+			return nil, err
+		}
+		iters = append(iters, iter)
+	}
+	if err := iters[0].Validate(ctx); err != nil {
+		return nil, errors.Wrap(err, "unable to validate query")
+	}
+	return iters, nil
+}
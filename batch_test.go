@@ -0,0 +1,140 @@
+package cursoriterator_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+
+	cursoriterator "github.com/Eun/go-pgx-cursor-iterator/v2"
+)
+
+func TestBatchIterators(t *testing.T) {
+	t.Parallel()
+	runTest(
+		t,
+		[]User{
+			{1, "Joe"},
+			{2, "Alice"},
+			{3, "Bob"},
+			{4, "Mike"},
+			{5, "Maria"},
+			{6, "Tom"},
+		},
+		func(pool *pgxpool.Pool) {
+			iters, err := cursoriterator.BatchIterators[User](
+				context.Background(),
+				pool,
+				2,
+				2,
+				"SELECT * FROM users WHERE id BETWEEN $1 AND $2 ORDER BY id",
+				[][]interface{}{{1, 3}, {2, 5}, {6, 6}, {7, 9}},
+			)
+			require.NoError(t, err)
+			require.Len(t, iters, 4)
+
+			results := make([][]User, len(iters))
+			var wg sync.WaitGroup
+			for i, iter := range iters {
+				i, iter := i, iter
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer iter.Close(context.Background())
+					values := iter.Values().([]User)
+					results[i] = []User{}
+					for iter.Next(context.Background()) {
+						results[i] = append(results[i], values[iter.ValueIndex()])
+					}
+				}()
+			}
+			wg.Wait()
+
+			for _, iter := range iters {
+				require.NoError(t, iter.Error())
+			}
+			require.Equal(t, [][]User{
+				{{1, "Joe"}, {2, "Alice"}, {3, "Bob"}},
+				{{2, "Alice"}, {3, "Bob"}, {4, "Mike"}, {5, "Maria"}},
+				{{6, "Tom"}},
+				{},
+			}, results)
+		})
+}
+
+func TestBatchIteratorsMaxExec(t *testing.T) {
+	t.Parallel()
+	users := []User{
+		{1, "Joe"},
+		{2, "Alice"},
+		{3, "Bob"},
+		{4, "Mike"},
+		{5, "Maria"},
+	}
+	runTest(
+		t,
+		users,
+		func(pool *pgxpool.Pool) {
+			iters, err := cursoriterator.BatchIterators[User](
+				context.Background(),
+				pool,
+				2,
+				2,
+				"SELECT * FROM users WHERE id > $1 ORDER BY id",
+				[][]interface{}{{0}, {1}},
+			)
+			require.NoError(t, err)
+			require.Len(t, iters, 2)
+
+			// the iterators are bounded independently, interleaving them does not block
+			for i := 0; i < 4; i++ {
+				for j, iter := range iters {
+					require.True(t, iter.Next(context.Background()))
+					require.Equal(t, users[i+j], iter.Values().([]User)[iter.ValueIndex()])
+				}
+			}
+			for _, iter := range iters {
+				require.False(t, iter.Next(context.Background()))
+				require.ErrorIs(t, iter.Error(), cursoriterator.ErrMaxBatchesExceeded)
+				require.ErrorIs(t, iter.Close(context.Background()), cursoriterator.ErrMaxBatchesExceeded)
+			}
+		})
+}
+
+func TestBatchIteratorsValidation(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name      string
+		batchSize int
+		maxExec   int
+		query     string
+		argSets   [][]interface{}
+		err       string
+	}{
+		{"empty arg sets", 2, 0, "SELECT * FROM users", nil, "arg sets cannot be empty"},
+		{"batch size", 0, 0, "SELECT * FROM users", [][]interface{}{{}}, "batch size must be bigger than 0"},
+		{"negative max exec", 2, -1, "SELECT * FROM users", [][]interface{}{{}}, "max exec cannot be negative"},
+		{
+			"arg count",
+			2,
+			0,
+			"SELECT * FROM users WHERE id = $1",
+			[][]interface{}{{1}, {1, 2}},
+			"query has 1 placeholders but arg set 1 has 2 args",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			connector, _ := newUsersConnector(1)
+			iters, err := cursoriterator.BatchIterators[User](
+				context.Background(), connector, test.batchSize, test.maxExec, test.query, test.argSets,
+			)
+			require.EqualError(t, err, test.err)
+			require.Nil(t, iters)
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package cursoriterator
+
+import (
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+// bufferPoolState is the state of WithBufferPool().
+type bufferPoolState struct {
+	// ownsValues reports whether the values were allocated by the iterator, only those can be replaced by a buffer
+	// of the pool.
+	ownsValues bool
+	// release puts the buffer back into the pool, released reports whether it was put back.
+	release  func()
+	released bool
+}
+
+// BufferPool provides the buffers for WithBufferPool(), it is implemented by *sync.Pool.
+type BufferPool interface {
+	Get() interface{}
+	Put(x interface{})
+}
+
+// WithBufferPool lets a TypedCursorIterator[T] take its buffer from pool instead of allocating it.
+// The buffer is put back into the pool on Close(), it is not released between batches because the current
+// value lives in it. This reduces allocations if many short-lived iterators run concurrently.
+//
+// The pool must contain *[]T values, if it is empty or returns a buffer that is smaller than the batch size
+// a new buffer will be allocated. The option can only be used with NewTypedCursorIteratorWithOptions().
+func WithBufferPool[T any](pool BufferPool) Option {
+	return func(iter *CursorIterator) error {
+		if pool == nil {
+			return errors.New("buffer pool cannot be nil")
+		}
+		if !iter.pool.ownsValues {
+			return errors.New("buffer pool can only be used with a typed iterator")
+		}
+		if _, ok := iter.valuesRef.([]T); !ok {
+			return errors.Errorf("buffer pool expects %T, but values is %T", []T(nil), iter.valuesRef)
+		}
+
+		capacity := len(iter.values)
+		buffer, ok := pool.Get().(*[]T)
+		if !ok || len(*buffer) < capacity {
+			b := make([]T, capacity)
+			buffer = &b
+		}
+		values := (*buffer)[:capacity]
+		iter.valuesRef = values
+		iter.values = scanDestinations(reflect.ValueOf(values))
+		iter.pool.release = func() {
+			pool.Put(buffer)
+		}
+		return nil
+	}
+}
+
+// releaseBufferToPool puts the buffer back into the pool if WithBufferPool() is used.
+func (iter *CursorIterator) releaseBufferToPool() {
+	if iter.pool.release != nil {
+		iter.pool.release()
+		iter.pool.release = nil
+		iter.pool.released = true
+		// another iterator can use the buffer now
+		iter.snapshotLen = 0
+		iter.partialLen = 0
+	}
+}
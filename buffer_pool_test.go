@@ -0,0 +1,143 @@
+package cursoriterator_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+
+	cursoriterator "github.com/Eun/go-pgx-cursor-iterator/v2"
+	"github.com/Eun/go-pgx-cursor-iterator/v2/cursoriteratortest"
+)
+
+// countingPool is a BufferPool that counts the calls to Get and Put.
+type countingPool struct {
+	mu      sync.Mutex
+	buffers []interface{}
+	gets    int
+	puts    int
+}
+
+func (p *countingPool) Get() interface{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.gets++
+	if len(p.buffers) == 0 {
+		return nil
+	}
+	buffer := p.buffers[len(p.buffers)-1]
+	p.buffers = p.buffers[:len(p.buffers)-1]
+	return buffer
+}
+
+func (p *countingPool) Put(x interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.puts++
+	p.buffers = append(p.buffers, x)
+}
+
+func TestWithBufferPool(t *testing.T) {
+	t.Parallel()
+
+	t.Run("typed iterator only", func(t *testing.T) {
+		t.Parallel()
+		iter, err := cursoriterator.NewCursorIteratorWithOptions(
+			&pgxpool.Pool{},
+			make([]User, 2),
+			[]cursoriterator.Option{cursoriterator.WithBufferPool[User](&sync.Pool{})},
+			"SELECT * FROM users",
+		)
+		require.EqualError(t, err, "buffer pool can only be used with a typed iterator")
+		require.Nil(t, iter)
+	})
+
+	t.Run("type must match", func(t *testing.T) {
+		t.Parallel()
+		iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+			&pgxpool.Pool{},
+			2,
+			[]cursoriterator.Option{cursoriterator.WithBufferPool[string](&sync.Pool{})},
+			"SELECT * FROM users",
+		)
+		require.EqualError(t, err, "buffer pool expects []string, but values is []cursoriterator_test.User")
+		require.Nil(t, iter)
+	})
+
+	t.Run("buffers are reused", func(t *testing.T) {
+		t.Parallel()
+		connector := cursoriteratortest.NewConnector(
+			[]string{"id", "name"},
+			[]interface{}{1, "Joe"},
+			[]interface{}{2, "Alice"},
+			[]interface{}{3, "Bob"},
+		)
+		pool := &countingPool{}
+
+		for i := 0; i < 3; i++ {
+			iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+				connector,
+				2,
+				[]cursoriterator.Option{cursoriterator.WithBufferPool[User](pool)},
+				"SELECT * FROM users",
+			)
+			require.NoError(t, err)
+
+			var users []User
+			for iter.Next(context.Background()) {
+				users = append(users, iter.Value())
+			}
+			require.NoError(t, iter.Error())
+			require.Equal(t, []User{{1, "Joe"}, {2, "Alice"}, {3, "Bob"}}, users)
+			require.NoError(t, iter.Close(context.Background()))
+			// closing twice must not put the buffer twice
+			require.NoError(t, iter.Close(context.Background()))
+		}
+
+		require.Equal(t, 3, pool.gets)
+		require.Equal(t, 3, pool.puts)
+		// only one buffer was allocated
+		require.Len(t, pool.buffers, 1)
+	})
+
+	t.Run("constructor error", func(t *testing.T) {
+		t.Parallel()
+		pool := &countingPool{}
+		iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+			&pgxpool.Pool{},
+			2,
+			[]cursoriterator.Option{cursoriterator.WithBufferPool[User](pool), cursoriterator.WithMaxBatches(0)},
+			"SELECT * FROM users",
+		)
+		require.EqualError(t, err, "max batches must be bigger than 0")
+		require.Nil(t, iter)
+		// the buffer was put back, since the iterator was not returned
+		require.Equal(t, 1, pool.gets)
+		require.Equal(t, 1, pool.puts)
+	})
+
+	t.Run("partial batch after close", func(t *testing.T) {
+		t.Parallel()
+		connector := cursoriteratortest.NewConnector(
+			[]string{"id", "name"},
+			[]interface{}{1, "Joe"},
+			[]interface{}{2, nil},
+		)
+		iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+			connector,
+			2,
+			[]cursoriterator.Option{cursoriterator.WithBufferPool[User](&countingPool{})},
+			"SELECT * FROM users",
+		)
+		require.NoError(t, err)
+		require.False(t, iter.Next(context.Background()))
+		require.Error(t, iter.Error())
+		require.Equal(t, []User{{1, "Joe"}}, iter.PartialBatch())
+
+		// the buffer belongs to the pool now
+		require.Error(t, iter.Close(context.Background()))
+		require.Nil(t, iter.PartialBatch())
+	})
+}
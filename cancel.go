@@ -0,0 +1,51 @@
+package cursoriterator
+
+import "context"
+
+// Cancel cancels the iteration, it can be called from any goroutine, also while Next() is running.
+// A running database operation fails fast (pgx closes its connection), the following operations are not started.
+// The iterator will be closed by the goroutine that uses it: Next() returns false and Error() returns ErrCanceled.
+// Close() should still be called. A canceled iterator can not be resumed.
+func (iter *CursorIterator) Cancel() {
+	iter.cancelOnce.Do(func() {
+		close(iter.canceled)
+	})
+}
+
+// isCanceled reports whether Cancel() was called.
+func (iter *CursorIterator) isCanceled() bool {
+	select {
+	case <-iter.canceled:
+		return true
+	default:
+		return false
+	}
+}
+
+// cancelable returns a context that will be canceled if ctx is canceled or Cancel() is called.
+// The returned cancel function must be called after the operation finished.
+func (iter *CursorIterator) cancelable(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-iter.canceled:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// abortIfCanceled closes the iterator with ErrCanceled if Cancel() was called.
+// ctx must not be the context returned by cancelable(), so the transaction can still be rolled back.
+func (iter *CursorIterator) abortIfCanceled(ctx context.Context) bool {
+	if !iter.isCanceled() {
+		return false
+	}
+	iter.close(ctx)
+	iter.err = ErrCanceled
+	iter.hasMore = false
+	iter.valuesPos = -1
+	iter.notifyClosed(ctx)
+	return true
+}
@@ -0,0 +1,176 @@
+package cursoriterator_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+
+	cursoriterator "github.com/Eun/go-pgx-cursor-iterator/v2"
+	"github.com/Eun/go-pgx-cursor-iterator/v2/cursoriteratortest"
+)
+
+// blockFetchesAfter lets the fetches of the connector block until their context is done, after the first
+// fetches succeeded. The returned channel is closed when the first fetch blocks.
+func blockFetchesAfter(connector *cursoriteratortest.Connector, blockAfter int) <-chan struct{} {
+	blocked := make(chan struct{})
+	var fetches int
+	connector.OnQuery = func(ctx context.Context, next cursoriteratortest.QueryFunc, sql string, args ...interface{}) (pgx.Rows, error) {
+		fetches++
+		if fetches > blockAfter {
+			close(blocked)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return next(ctx, sql, args...)
+	}
+	return blocked
+}
+
+func TestCancel(t *testing.T) {
+	t.Parallel()
+
+	t.Run("between fetches", func(t *testing.T) {
+		t.Parallel()
+		connector, users := newUsersConnector(100)
+		iter, err := cursoriterator.NewTypedCursorIterator[User](connector, 2, "SELECT * FROM users")
+		require.NoError(t, err)
+
+		consumed := make(chan struct{})
+		canceled := make(chan struct{})
+		go func() {
+			<-consumed
+			iter.Cancel()
+			// canceling twice is fine
+			iter.Cancel()
+			close(canceled)
+		}()
+
+		var result []User
+		for iter.Next(context.Background()) {
+			result = append(result, iter.Value())
+			if len(result) == 3 {
+				close(consumed)
+				<-canceled
+			}
+		}
+		require.Equal(t, users[:3], result)
+		require.ErrorIs(t, iter.Error(), cursoriterator.ErrCanceled)
+		require.False(t, iter.Next(context.Background()))
+		require.ErrorIs(t, iter.Close(context.Background()), cursoriterator.ErrCanceled)
+
+		statements := connector.Statements()
+		require.Equal(t, "ROLLBACK", statements[len(statements)-1])
+		select {
+		case <-iter.Done():
+		default:
+			t.Fatal("iterator is not done")
+		}
+	})
+
+	t.Run("in-flight fetch", func(t *testing.T) {
+		t.Parallel()
+		connector, users := newUsersConnector(100)
+		blocked := blockFetchesAfter(connector, 1)
+		iter, err := cursoriterator.NewTypedCursorIterator[User](connector, 2, "SELECT * FROM users")
+		require.NoError(t, err)
+
+		go func() {
+			<-blocked
+			iter.Cancel()
+		}()
+
+		var result []User
+		for iter.Next(context.Background()) {
+			result = append(result, iter.Value())
+		}
+		require.Equal(t, users[:2], result)
+		require.ErrorIs(t, iter.Error(), cursoriterator.ErrCanceled)
+		require.False(t, errors.Is(iter.Error(), context.Canceled))
+		require.ErrorIs(t, iter.Close(context.Background()), cursoriterator.ErrCanceled)
+	})
+
+	t.Run("before start", func(t *testing.T) {
+		t.Parallel()
+		connector, _ := newUsersConnector(3)
+		iter, err := cursoriterator.NewTypedCursorIterator[User](connector, 2, "SELECT * FROM users")
+		require.NoError(t, err)
+		iter.Cancel()
+		require.False(t, iter.Next(context.Background()))
+		require.ErrorIs(t, iter.Error(), cursoriterator.ErrCanceled)
+		require.Empty(t, connector.Statements())
+		require.ErrorIs(t, iter.Close(context.Background()), cursoriterator.ErrCanceled)
+	})
+}
+
+// cancelingScanRows calls cancel once cancelAt rows were read.
+type cancelingScanRows struct {
+	pgx.Rows
+	cancelAt int
+	cancel   context.CancelFunc
+	read     *int
+}
+
+func (rows *cancelingScanRows) Next() bool {
+	if !rows.Rows.Next() {
+		return false
+	}
+	*rows.read++
+	if *rows.read == rows.cancelAt {
+		rows.cancel()
+	}
+	return true
+}
+
+func TestCancelDuringScan(t *testing.T) {
+	t.Parallel()
+	const (
+		rowCount = 10000
+		cancelAt = 1000
+	)
+	connector, _ := newUsersConnector(rowCount)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var read int
+	connector.OnQuery = func(ctx context.Context, next cursoriteratortest.QueryFunc, sql string, args ...interface{}) (pgx.Rows, error) {
+		rows, err := next(ctx, sql, args...)
+		if err != nil {
+			return nil, err
+		}
+		return &cancelingScanRows{Rows: rows, cancelAt: cancelAt, cancel: cancel, read: &read}, nil
+	}
+	iter, err := cursoriterator.NewTypedCursorIterator[User](connector, rowCount, "SELECT * FROM users")
+	require.NoError(t, err)
+
+	require.False(t, iter.Next(ctx))
+	require.ErrorIs(t, iter.Error(), context.Canceled)
+	// the scan was aborted shortly after the context was canceled, not at the end of the batch
+	require.Less(t, read, cancelAt+300)
+	require.Equal(t, -1, iter.ValueIndex())
+
+	statements := connector.Statements()
+	require.Equal(t, "ROLLBACK", statements[len(statements)-1])
+	require.ErrorIs(t, iter.Close(context.Background()), context.Canceled)
+}
+
+func TestCanceledBeforeBegin(t *testing.T) {
+	t.Parallel()
+	connector, _ := newUsersConnector(3)
+	iter, err := cursoriterator.NewCursorIterator(connector, make([]User, 2), "SELECT * FROM users")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.False(t, iter.Next(ctx))
+	require.Equal(t, context.Canceled, iter.Error())
+	// the database was not touched
+	require.Empty(t, connector.Statements())
+
+	ctx, cancel = context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	require.False(t, iter.Next(ctx))
+	require.Equal(t, context.DeadlineExceeded, iter.Error())
+	require.Empty(t, connector.Statements())
+}
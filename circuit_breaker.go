@@ -0,0 +1,42 @@
+package cursoriterator
+
+import (
+	"github.com/pkg/errors"
+)
+
+// ErrCircuitOpen will be returned when an operation was not executed because the circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitBreaker can be used to fail fast when the database is unhealthy, see WithCircuitBreaker().
+type CircuitBreaker interface {
+	// Allow reports whether the next database operation should be executed.
+	Allow() bool
+	// Record will be called with the result of each executed database operation.
+	Record(err error)
+}
+
+// WithCircuitBreaker sets a CircuitBreaker that will be consulted before each database operation
+// (starting the transaction, declaring the cursor and fetching rows).
+// If the circuit breaker does not allow an operation, it will fail with ErrCircuitOpen.
+// A failed fetch can be retried by calling Next() again.
+func WithCircuitBreaker(cb CircuitBreaker) Option {
+	return func(iter *CursorIterator) error {
+		if cb == nil {
+			return errors.New("circuit breaker cannot be nil")
+		}
+		iter.circuitBreaker = cb
+		return nil
+	}
+}
+
+// allowOperation reports whether the circuit breaker allows the next database operation.
+func (iter *CursorIterator) allowOperation() bool {
+	return iter.circuitBreaker == nil || iter.circuitBreaker.Allow()
+}
+
+// recordOperation records the result of a database operation in the circuit breaker.
+func (iter *CursorIterator) recordOperation(err error) {
+	if iter.circuitBreaker != nil {
+		iter.circuitBreaker.Record(err)
+	}
+}
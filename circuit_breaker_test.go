@@ -0,0 +1,99 @@
+package cursoriterator_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+
+	cursoriterator "github.com/Eun/go-pgx-cursor-iterator/v2"
+)
+
+type stubCircuitBreaker struct {
+	mu      sync.Mutex
+	open    bool
+	records []error
+}
+
+func (cb *stubCircuitBreaker) Allow() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return !cb.open
+}
+
+func (cb *stubCircuitBreaker) Record(err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.records = append(cb.records, err)
+}
+
+func (cb *stubCircuitBreaker) setOpen(open bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.open = open
+}
+
+func TestWithCircuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	t.Run("open", func(t *testing.T) {
+		t.Parallel()
+		cb := &stubCircuitBreaker{open: true}
+		iter, err := cursoriterator.NewCursorIteratorWithOptions(
+			&pgxpool.Pool{},
+			make([]User, 3),
+			[]cursoriterator.Option{cursoriterator.WithCircuitBreaker(cb)},
+			"SELECT * FROM users",
+		)
+		require.NoError(t, err)
+
+		require.False(t, iter.Next(context.Background()))
+		require.True(t, errors.Is(iter.Error(), cursoriterator.ErrCircuitOpen))
+		require.Empty(t, cb.records)
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		t.Parallel()
+		runTest(
+			t,
+			[]User{
+				{1, "Joe"},
+				{2, "Alice"},
+				{3, "Bob"},
+			},
+			func(pool *pgxpool.Pool) {
+				cb := &stubCircuitBreaker{}
+				values := make([]User, 2)
+				iter, err := cursoriterator.NewCursorIteratorWithOptions(
+					pool,
+					values,
+					[]cursoriterator.Option{cursoriterator.WithCircuitBreaker(cb)},
+					"SELECT * FROM users ORDER BY id",
+				)
+				require.NoError(t, err)
+
+				require.True(t, iter.Next(context.Background()))
+				require.True(t, iter.Next(context.Background()))
+
+				// open the circuit breaker, the next fetch must fail
+				cb.setOpen(true)
+				require.False(t, iter.Next(context.Background()))
+				require.True(t, errors.Is(iter.Error(), cursoriterator.ErrCircuitOpen))
+
+				// close it again, the iteration can continue
+				cb.setOpen(false)
+				require.True(t, iter.Next(context.Background()))
+				require.NoError(t, iter.Error())
+				require.Equal(t, User{3, "Bob"}, values[iter.ValueIndex()])
+				require.False(t, iter.Next(context.Background()))
+				require.NoError(t, iter.Error())
+
+				// begin, declare, 3 fetches
+				require.Equal(t, []error{nil, nil, nil, nil, nil}, cb.records)
+				require.NoError(t, iter.Close(context.Background()))
+			})
+	})
+}
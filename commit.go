@@ -0,0 +1,112 @@
+package cursoriterator
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+)
+
+// commitState is the state of WithCommitPerBatch().
+type commitState struct {
+	// column is the key column, the transaction is committed after every batch if it is set.
+	column string
+	// started reports whether a batch was committed, value is the key of the last row of the committed batch.
+	started bool
+	value   interface{}
+	// key is the key of the last row of the current batch.
+	key interface{}
+}
+
+// reset forgets the committed batches, so the next cursor starts at the first row.
+func (s *commitState) reset() {
+	s.started = false
+	s.value = nil
+	s.key = nil
+}
+
+// WithCommitPerBatch commits the transaction of the iterator after every batch, so changes made on the transaction
+// (e.g. with ExecCurrentOf()) are committed and their locks are released batch by batch.
+// When the next batch is needed the transaction is committed and the cursor is declared again in a new transaction,
+// continuing after the last row of the previous batch:
+//
+//	SELECT * FROM (query) AS resume WHERE keyColumn > $n ORDER BY keyColumn
+//
+// keyColumn must be part of the query's result and must be unique, otherwise rows will be skipped. The rows are
+// ordered by keyColumn. Every batch sees the data committed before it was fetched, so the result is not a
+// consistent snapshot. Close() rolls back the changes made for the current batch.
+// WithCommitPerBatch can not be combined with WithBufferDepth(), WithPgBouncerCompat(), WithHold(),
+// WithOrderBy() and WithMaterialize(), WithResumeFrom() must use keyColumn.
+func WithCommitPerBatch(keyColumn string) Option {
+	return func(iter *CursorIterator) error {
+		if keyColumn == "" {
+			return errors.New("key column cannot be empty")
+		}
+		iter.commit.column = keyColumn
+		return nil
+	}
+}
+
+// validateCommitPerBatch checks whether the options that were applied can be used together with WithCommitPerBatch().
+func (iter *CursorIterator) validateCommitPerBatch() error {
+	if iter.commit.column == "" {
+		return nil
+	}
+	switch {
+	case iter.bufferDepth > 0:
+		return errors.New("commit per batch cannot be used with buffer depth")
+	case iter.keyset.column != "":
+		return errors.New("commit per batch cannot be used with pgbouncer compat")
+	case iter.hold:
+		return errors.New("commit per batch cannot be used with hold")
+	case iter.orderBy != "":
+		return errors.New("commit per batch cannot be used with order by")
+	case iter.resumeColumn != "" && iter.resumeColumn != iter.commit.column:
+		return errors.New("commit per batch requires the resume column to be the key column")
+	case iter.materialization.table != "":
+		return errors.New("commit per batch cannot be used with materialize")
+	}
+	return nil
+}
+
+// recordCommitKey stores the key of the current row, the next batch continues after the last stored key.
+func (iter *CursorIterator) recordCommitKey(rows pgx.Rows) error {
+	keyIndex := -1
+	for i, field := range rows.FieldDescriptions() {
+		if field.Name == iter.commit.column {
+			keyIndex = i
+			break
+		}
+	}
+	if keyIndex == -1 {
+		return errors.Errorf("key column %q is not part of the result", iter.commit.column)
+	}
+	values, err := rows.Values()
+	if err != nil {
+		return errors.Wrap(err, "unable to read key column")
+	}
+	iter.commit.key = values[keyIndex]
+	return nil
+}
+
+// commitBatch commits the transaction of the current batch and declares the cursor again in a new transaction,
+// after the last key of the batch.
+func (iter *CursorIterator) commitBatch(ctx context.Context) error {
+	if iter.tx == nil {
+		return nil
+	}
+	if _, err := iter.endSavepoint(ctx); err != nil {
+		return err
+	}
+	err := iter.tx.Commit(ctx)
+	iter.unregisterNoticeHandler()
+	iter.tx = nil
+	if err != nil {
+		return errors.Wrap(err, "unable to commit batch")
+	}
+	iter.commit.started = true
+	iter.commit.value = iter.commit.key
+	// the new cursor starts after the rows that were fetched so far
+	iter.cursorOffset = iter.position
+	return iter.begin(ctx)
+}
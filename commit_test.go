@@ -0,0 +1,203 @@
+package cursoriterator_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+
+	cursoriterator "github.com/Eun/go-pgx-cursor-iterator/v2"
+	"github.com/Eun/go-pgx-cursor-iterator/v2/cursoriteratortest"
+)
+
+// newCommitConnector simulates the resume queries of WithCommitPerBatch(), every declared cursor returns the rows
+// that have a bigger id (the first column) than the resume argument.
+func newCommitConnector(rows [][]interface{}) *cursoriteratortest.Connector {
+	connector := cursoriteratortest.NewConnector([]string{"id", "name"}, rows...)
+	connector.OnDeclare = func(sql string, args ...interface{}) [][]interface{} {
+		after := 0
+		if strings.Contains(sql, " WHERE ") {
+			after = args[len(args)-1].(int)
+		}
+		var result [][]interface{}
+		for _, row := range rows {
+			if row[0].(int) > after {
+				result = append(result, row)
+			}
+		}
+		return result
+	}
+	return connector
+}
+
+// declaredQueries returns the queries of the cursors that were declared on the connector.
+func declaredQueries(connector *cursoriteratortest.Connector) []string {
+	var queries []string
+	for _, sql := range connector.Statements() {
+		if strings.HasPrefix(sql, "DECLARE ") {
+			queries = append(queries, sql[strings.Index(sql, " FOR ")+5:])
+		}
+	}
+	return queries
+}
+
+func TestWithCommitPerBatch(t *testing.T) {
+	t.Parallel()
+
+	var rows [][]interface{}
+	for i := 1; i <= 7; i++ {
+		rows = append(rows, []interface{}{i, fmt.Sprintf("user%d", i)})
+	}
+
+	t.Run("commits every batch", func(t *testing.T) {
+		t.Parallel()
+		connector := newCommitConnector(rows)
+		transactions := 0
+		iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+			connector,
+			3,
+			[]cursoriterator.Option{
+				cursoriterator.WithCommitPerBatch("id"),
+				cursoriterator.WithConnectionInit(func(context.Context, pgx.Tx) error {
+					transactions++
+					return nil
+				}),
+			},
+			"SELECT * FROM users",
+		)
+		require.NoError(t, err)
+
+		var users []User
+		var commits []int
+		for iter.Next(context.Background()) {
+			users = append(users, iter.Value())
+			commits = append(commits, countStatements(connector, "COMMIT"))
+		}
+		require.NoError(t, iter.Error())
+		require.NoError(t, iter.Close(context.Background()))
+
+		require.Len(t, users, 7)
+		require.Equal(t, User{ID: 7, Name: "user7"}, users[6])
+		// the batch of a row was committed before the next batch was fetched
+		require.Equal(t, []int{0, 0, 0, 1, 1, 1, 2}, commits)
+		// the last batch is committed before the final fetch that returns no rows
+		require.Equal(t, 3, countStatements(connector, "COMMIT"))
+		require.Equal(t, 4, transactions)
+		require.Equal(t, []string{
+			`SELECT * FROM (SELECT * FROM users) AS ordered ORDER BY "id"`,
+			`SELECT * FROM (SELECT * FROM users) AS resume WHERE "id" > $1 ORDER BY "id"`,
+			`SELECT * FROM (SELECT * FROM users) AS resume WHERE "id" > $1 ORDER BY "id"`,
+			`SELECT * FROM (SELECT * FROM users) AS resume WHERE "id" > $1 ORDER BY "id"`,
+		}, declaredQueries(connector))
+	})
+
+	t.Run("close rolls back the current batch", func(t *testing.T) {
+		t.Parallel()
+		connector := newCommitConnector(rows)
+		iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+			connector,
+			3,
+			[]cursoriterator.Option{cursoriterator.WithCommitPerBatch("id")},
+			"SELECT * FROM users",
+		)
+		require.NoError(t, err)
+		for i := 0; i < 4; i++ {
+			require.True(t, iter.Next(context.Background()))
+		}
+		require.NoError(t, iter.Close(context.Background()))
+		require.Equal(t, 1, countStatements(connector, "COMMIT"))
+	})
+
+	t.Run("skip scan errors", func(t *testing.T) {
+		t.Parallel()
+		var rows [][]interface{}
+		for i := 1; i <= 9; i++ {
+			rows = append(rows, []interface{}{i, fmt.Sprintf("user%d", i)})
+		}
+		// a NULL name cannot be scanned into User.Name
+		rows[3][1] = nil
+		connector := newCommitConnector(rows)
+		var skippedRows []int64
+		iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+			connector,
+			3,
+			[]cursoriterator.Option{
+				cursoriterator.WithCommitPerBatch("id"),
+				cursoriterator.WithSkipScanErrors(func(rowIndex int64, err error) {
+					skippedRows = append(skippedRows, rowIndex)
+				}),
+			},
+			"SELECT * FROM users",
+		)
+		require.NoError(t, err)
+
+		var ids []int
+		for iter.Next(context.Background()) {
+			ids = append(ids, iter.Value().ID)
+		}
+		require.NoError(t, iter.Error())
+		require.NoError(t, iter.Close(context.Background()))
+		require.Equal(t, []int{1, 2, 3, 5, 6, 7, 8, 9}, ids)
+		require.Equal(t, []int64{3}, skippedRows)
+	})
+
+	t.Run("unknown key column", func(t *testing.T) {
+		t.Parallel()
+		connector := newCommitConnector(rows)
+		iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+			connector,
+			3,
+			[]cursoriterator.Option{cursoriterator.WithCommitPerBatch("uid")},
+			"SELECT * FROM users",
+		)
+		require.NoError(t, err)
+		require.False(t, iter.Next(context.Background()))
+		require.EqualError(t, iter.Error(), `key column "uid" is not part of the result`)
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		t.Parallel()
+		tests := []struct {
+			options []cursoriterator.Option
+			err     string
+		}{
+			{[]cursoriterator.Option{cursoriterator.WithCommitPerBatch("")}, "key column cannot be empty"},
+			{
+				[]cursoriterator.Option{cursoriterator.WithCommitPerBatch("id"), cursoriterator.WithBufferDepth(1)},
+				"commit per batch cannot be used with buffer depth",
+			},
+			{
+				[]cursoriterator.Option{cursoriterator.WithCommitPerBatch("id"), cursoriterator.WithPgBouncerCompat("id")},
+				"commit per batch cannot be used with pgbouncer compat",
+			},
+			{
+				[]cursoriterator.Option{cursoriterator.WithCommitPerBatch("id"), cursoriterator.WithHold()},
+				"commit per batch cannot be used with hold",
+			},
+			{
+				[]cursoriterator.Option{cursoriterator.WithCommitPerBatch("id"), cursoriterator.WithOrderBy("name")},
+				"commit per batch cannot be used with order by",
+			},
+			{
+				[]cursoriterator.Option{cursoriterator.WithCommitPerBatch("id"), cursoriterator.WithResumeFrom("name", "Joe")},
+				"commit per batch requires the resume column to be the key column",
+			},
+			{
+				[]cursoriterator.Option{cursoriterator.WithCommitPerBatch("id"), cursoriterator.WithMaterialize()},
+				"commit per batch cannot be used with materialize",
+			},
+		}
+		for _, test := range tests {
+			_, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+				newCommitConnector(nil),
+				3,
+				test.options,
+				"SELECT * FROM users",
+			)
+			require.EqualError(t, err, test.err)
+		}
+	})
+}
@@ -0,0 +1,54 @@
+package cursoriterator
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pkg/errors"
+)
+
+// ErrReadOnly will be returned by ExecCurrentOf() if the iterator was created with WithReadOnly().
+var ErrReadOnly = errors.New("iterator is read only, use a separate connection to write")
+
+// WithReadOnly starts the transaction as READ ONLY.
+// Use it if the connector is a read replica: the iterator will not assume that writes can happen on its
+// transaction, ExecCurrentOf() fails with ErrReadOnly and all other writes on the transaction are rejected by
+// the database. Writes must be performed on a separate connection to the primary, identifying the rows by their
+// primary key instead of WHERE CURRENT OF.
+func WithReadOnly() Option {
+	return func(iter *CursorIterator) error {
+		iter.readOnly = true
+		return nil
+	}
+}
+
+// ExecCurrentOf executes statement with WHERE CURRENT OF cursor appended on the iterator's transaction,
+// e.g. ExecCurrentOf(ctx, "UPDATE users SET name = $1", "Joe") updates the row the cursor is positioned on.
+//
+// The cursor is positioned on the last fetched row, which only matches the current value if the iterator fetches
+// one row at a time, so the capacity of values must be 1. The query must be updatable (see the postgres
+// documentation for DECLARE), the iterator must not be read only and the cursor must not be insensitive.
+func (iter *CursorIterator) ExecCurrentOf(ctx context.Context, statement string, args ...interface{}) (pgconn.CommandTag, error) {
+	iter.mu.Lock()
+	defer iter.mu.Unlock()
+	if iter.readOnly {
+		return pgconn.CommandTag{}, ErrReadOnly
+	}
+	if iter.bufferDepth > 0 {
+		return pgconn.CommandTag{}, errors.New("WHERE CURRENT OF cannot be used with buffer depth")
+	}
+	if iter.insensitive {
+		return pgconn.CommandTag{}, errors.New("WHERE CURRENT OF cannot be used with insensitive")
+	}
+	if len(iter.values) != 1 {
+		return pgconn.CommandTag{}, errors.New("WHERE CURRENT OF requires a capacity of 1")
+	}
+	if iter.tx == nil || iter.valuesPos < 0 {
+		return pgconn.CommandTag{}, errors.New("iterator has no current row")
+	}
+	tag, err := iter.tx.Exec(ctx, statement+" WHERE CURRENT OF "+iter.cursorIdentifier(), args...)
+	if err != nil {
+		return tag, errors.Wrap(err, "unable to execute statement for current row")
+	}
+	return tag, nil
+}
@@ -0,0 +1,100 @@
+package cursoriterator_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+
+	cursoriterator "github.com/Eun/go-pgx-cursor-iterator/v2"
+)
+
+func TestExecCurrentOf(t *testing.T) {
+	t.Parallel()
+
+	t.Run("update current row", func(t *testing.T) {
+		t.Parallel()
+		runTest(
+			t,
+			[]User{
+				{1, "Joe"},
+				{2, "Alice"},
+			},
+			func(pool *pgxpool.Pool) {
+				iter, err := cursoriterator.NewTypedCursorIterator[User](pool, 1, "SELECT * FROM users ORDER BY id")
+				require.NoError(t, err)
+
+				_, err = iter.ExecCurrentOf(context.Background(), "UPDATE users SET name = $1", "Bob")
+				require.EqualError(t, err, "iterator has no current row")
+
+				require.True(t, iter.Next(context.Background()))
+				require.True(t, iter.Next(context.Background()))
+				tag, err := iter.ExecCurrentOf(context.Background(), "UPDATE users SET name = $1", "Bob")
+				require.NoError(t, err)
+				require.Equal(t, int64(1), tag.RowsAffected())
+
+				require.NoError(t, iter.Close(context.Background()))
+			})
+	})
+
+	t.Run("capacity must be 1", func(t *testing.T) {
+		t.Parallel()
+		iter, err := cursoriterator.NewTypedCursorIterator[User](&pgxpool.Pool{}, 2, "SELECT * FROM users")
+		require.NoError(t, err)
+		_, err = iter.ExecCurrentOf(context.Background(), "DELETE FROM users")
+		require.EqualError(t, err, "WHERE CURRENT OF requires a capacity of 1")
+	})
+}
+
+func TestWithReadOnly(t *testing.T) {
+	t.Parallel()
+	runTest(
+		t,
+		[]User{
+			{1, "Joe"},
+			{2, "Alice"},
+		},
+		func(primary *pgxpool.Pool) {
+			replica, err := pgxpool.New(context.Background(), primary.Config().ConnString())
+			require.NoError(t, err)
+			defer replica.Close()
+
+			var writeErr error
+			iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+				replica,
+				1,
+				[]cursoriterator.Option{
+					cursoriterator.WithReadOnly(),
+					cursoriterator.WithConnectionInit(func(ctx context.Context, tx pgx.Tx) error {
+						// use a savepoint, so the failed write does not abort the transaction
+						savepoint, err := tx.Begin(ctx)
+						if err != nil {
+							return err
+						}
+						_, writeErr = savepoint.Exec(ctx, "UPDATE users SET name = 'Bob' WHERE id = 1")
+						return savepoint.Rollback(ctx)
+					}),
+				},
+				"SELECT * FROM users ORDER BY id",
+			)
+			require.NoError(t, err)
+
+			require.True(t, iter.Next(context.Background()))
+			_, err = iter.ExecCurrentOf(context.Background(), "UPDATE users SET name = $1", "Bob")
+			require.ErrorIs(t, err, cursoriterator.ErrReadOnly)
+			require.NoError(t, iter.Close(context.Background()))
+
+			// writes on the iterator's transaction are rejected by the database
+			var pgErr *pgconn.PgError
+			require.ErrorAs(t, writeErr, &pgErr)
+			// read_only_sql_transaction
+			require.Equal(t, "25006", pgErr.Code)
+
+			// writes happen on the primary
+			_, err = primary.Exec(context.Background(), "UPDATE users SET name = 'Bob' WHERE id = 1")
+			require.NoError(t, err)
+		})
+}
@@ -0,0 +1,64 @@
+package cursoriterator
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// CursorInfo contains the properties of the cursor as reported by pg_cursors.
+type CursorInfo struct {
+	// Name is the name of the cursor.
+	Name string
+	// Statement is the verbatim query string that declared the cursor.
+	Statement string
+	// IsHoldable is true if the cursor was declared WITH HOLD.
+	IsHoldable bool
+	// IsBinary is true if the cursor was declared BINARY.
+	IsBinary bool
+	// IsScrollable is true if the cursor allows to be moved backwards.
+	IsScrollable bool
+	// CreationTime is the time the cursor was declared.
+	CreationTime time.Time
+}
+
+// CursorInfo queries pg_cursors within the iterator's transaction and returns the properties of the cursor.
+// The cursor is declared with the first Next() call, and can only be queried as long as the iterator is not closed.
+func (iter *CursorIterator) CursorInfo(ctx context.Context) (CursorInfo, error) {
+	iter.mu.Lock()
+	defer iter.mu.Unlock()
+	if iter.bufferDepth > 0 {
+		return CursorInfo{}, errors.New("cursor info cannot be queried with buffer depth")
+	}
+	if iter.tx == nil {
+		return CursorInfo{}, errors.New("iterator has no open transaction")
+	}
+	var info CursorInfo
+	err := iter.tx.QueryRow(
+		ctx,
+		"SELECT name, statement, is_holdable, is_binary, is_scrollable, creation_time FROM pg_cursors WHERE name = $1",
+		iter.cursorName,
+	).Scan(&info.Name, &info.Statement, &info.IsHoldable, &info.IsBinary, &info.IsScrollable, &info.CreationTime)
+	if err != nil {
+		return CursorInfo{}, errors.Wrap(err, "unable to query cursor info")
+	}
+	return info, nil
+}
+
+// BackendPID returns the process id of the postgres backend that runs the cursor, it can be used to find the
+// session in pg_stat_activity or to cancel it with pg_cancel_backend().
+// The transaction is started with the first Next() call, the PID is available as long as the iterator is not
+// closed.
+func (iter *CursorIterator) BackendPID() (uint32, error) {
+	iter.mu.Lock()
+	defer iter.mu.Unlock()
+	if iter.tx == nil {
+		return 0, errors.New("iterator has no open transaction")
+	}
+	conn := iter.tx.Conn()
+	if conn == nil {
+		return 0, errors.New("connection of the transaction is not available")
+	}
+	return conn.PgConn().PID(), nil
+}
@@ -0,0 +1,145 @@
+package cursoriterator_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+
+	cursoriterator "github.com/Eun/go-pgx-cursor-iterator/v2"
+	"github.com/Eun/go-pgx-cursor-iterator/v2/cursoriteratortest"
+)
+
+func TestCursorInfo(t *testing.T) {
+	t.Parallel()
+
+	t.Run("not started", func(t *testing.T) {
+		t.Parallel()
+		iter, err := cursoriterator.NewTypedCursorIterator[User](
+			cursoriteratortest.NewConnector([]string{"id", "name"}),
+			2,
+			"SELECT * FROM users",
+		)
+		require.NoError(t, err)
+		_, err = iter.CursorInfo(context.Background())
+		require.EqualError(t, err, "iterator has no open transaction")
+	})
+
+	tests := []struct {
+		name       string
+		options    []cursoriterator.Option
+		scrollable bool
+		holdable   bool
+	}{
+		{"default", nil, false, false},
+		{"scroll", []cursoriterator.Option{cursoriterator.WithScroll()}, true, false},
+		{"hold", []cursoriterator.Option{cursoriterator.WithHold()}, false, true},
+		{"scroll and hold", []cursoriterator.Option{cursoriterator.WithScroll(), cursoriterator.WithHold()}, true, true},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			runTest(
+				t,
+				[]User{
+					{1, "Joe"},
+					{2, "Alice"},
+					{3, "Bob"},
+				},
+				func(pool *pgxpool.Pool) {
+					iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+						pool,
+						2,
+						test.options,
+						"SELECT * FROM users ORDER BY id",
+					)
+					require.NoError(t, err)
+					require.True(t, iter.Next(context.Background()))
+
+					info, err := iter.CursorInfo(context.Background())
+					require.NoError(t, err)
+					require.Equal(t, iter.CursorName(), info.Name)
+					require.True(t, strings.HasPrefix(info.Statement, "DECLARE"))
+					require.True(t, strings.HasSuffix(info.Statement, "FOR SELECT * FROM users ORDER BY id"))
+					if test.scrollable {
+						// without SCROLL postgres decides by the query plan whether the cursor is scrollable
+						require.True(t, info.IsScrollable)
+					}
+					require.Equal(t, test.holdable, info.IsHoldable)
+					require.False(t, info.IsBinary)
+					require.WithinDuration(t, time.Now(), info.CreationTime, time.Minute)
+
+					expectTypedValues(t, iter,
+						User{2, "Alice"},
+						User{3, "Bob"},
+					)
+					require.NoError(t, iter.Close(context.Background()))
+				})
+		})
+	}
+}
+
+func TestBackendPID(t *testing.T) {
+	t.Parallel()
+
+	t.Run("not started", func(t *testing.T) {
+		t.Parallel()
+		iter, err := cursoriterator.NewTypedCursorIterator[User](
+			cursoriteratortest.NewConnector([]string{"id", "name"}),
+			2,
+			"SELECT * FROM users",
+		)
+		require.NoError(t, err)
+		_, err = iter.BackendPID()
+		require.EqualError(t, err, "iterator has no open transaction")
+	})
+
+	t.Run("no connection", func(t *testing.T) {
+		t.Parallel()
+		connector, _ := newUsersConnector(3)
+		iter, err := cursoriterator.NewTypedCursorIterator[User](connector, 2, "SELECT * FROM users")
+		require.NoError(t, err)
+		require.True(t, iter.Next(context.Background()))
+		_, err = iter.BackendPID()
+		require.EqualError(t, err, "connection of the transaction is not available")
+		require.NoError(t, iter.Close(context.Background()))
+	})
+
+	t.Run("pg_stat_activity", func(t *testing.T) {
+		t.Parallel()
+		runTest(
+			t,
+			[]User{
+				{1, "Joe"},
+				{2, "Alice"},
+				{3, "Bob"},
+			},
+			func(pool *pgxpool.Pool) {
+				iter, err := cursoriterator.NewTypedCursorIterator[User](pool, 2, "SELECT * FROM users ORDER BY id")
+				require.NoError(t, err)
+				require.True(t, iter.Next(context.Background()))
+
+				pid, err := iter.BackendPID()
+				require.NoError(t, err)
+				require.NotZero(t, pid)
+
+				// the session is idle in the transaction of the cursor
+				var state string
+				err = pool.QueryRow(
+					context.Background(),
+					"SELECT state FROM pg_stat_activity WHERE pid = $1",
+					int32(pid),
+				).Scan(&state)
+				require.NoError(t, err)
+				require.Equal(t, "idle in transaction", state)
+
+				require.NoError(t, iter.Close(context.Background()))
+				_, err = iter.BackendPID()
+				require.EqualError(t, err, "iterator has no open transaction")
+			})
+	})
+}
@@ -4,12 +4,17 @@ package cursoriterator
 import (
 	"context"
 	"encoding/hex"
+	stderrors "errors"
 	"fmt"
 	"reflect"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/georgysavva/scany/v2/pgxscan"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
 
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
@@ -23,30 +28,145 @@ type CursorIterator struct {
 	query     string
 	args      []interface{}
 
-	fetchQuery string
+	fetchSize     int
+	resultFormats pgx.QueryResultFormats
+	fetchTemplate string
+	refCursor     bool
 
+	valuesRef    interface{}
 	values       []interface{}
 	valuesPos    int
 	valuesMaxPos int
+	scanMode     ScanMode
+	rawFields    []pgconn.FieldDescription
+	schema       []pgconn.FieldDescription
+	partialLen   int
+	snapshotLen  int
 
-	err error
+	scanConcurrency int
+	typeMaps        []*pgtype.Map
 
-	tx pgx.Tx
+	err            error
+	errorFormatter func(phase string, err error) error
+
+	tx             pgx.Tx
+	lastCommandTag pgconn.CommandTag
+
+	txOptions        *pgx.TxOptions
+	readOnly         bool
+	statementTimeout time.Duration
+	applicationName  string
+	snapshotID       string
+	connectionInit   func(ctx context.Context, tx pgx.Tx) error
+	typeRegistration func(ctx context.Context, conn *pgx.Conn) error
+
+	scroll      bool
+	hold        bool
+	insensitive bool
+
+	orderBy      string
+	wrapSubquery bool
+	resumeColumn string
+	resumeValue  interface{}
+	limit        int64
+
+	hasMore      bool
+	position     int64
+	cursorOffset int64
+	fetchedRows  int64
+
+	adaptiveMinFetchSize int
+	adaptiveMaxFetchSize int
+
+	afterFetch       func(n int) error
+	lockRows         func(ctx context.Context, n int) (int, error)
+	lockSkippedCount int64
+	reverseBuffers   bool
+
+	skipScanErrors func(rowIndex int64, err error)
+	skippedCount   int64
+
+	circuitBreaker CircuitBreaker
+
+	bufferDepth int
+	prefetch    *prefetcher
+
+	canceled   chan struct{}
+	cancelOnce sync.Once
+
+	stopChannel  string
+	stopListener *stopListener
+
+	observer      Observer
+	closeNotified bool
+	done          chan struct{}
+	leakReport    func(query string)
+
+	profiling bool
+	profile   Profile
+	latency   latencyState
+
+	noticeHandler func(notice *pgconn.Notice)
+	noticeConn    *pgconn.PgConn
+
+	batch           batchState
+	keyset          keysetState
+	commit          commitState
+	savepoint       savepointState
+	materialization materializationState
+	pool            bufferPoolState
+	retry           retryState
+
+	fastShutdownTimeout time.Duration
+	closed              bool
+
+	options              []Option
+	lazyInit             func(ctx context.Context) error
+	validateQueryTimeout time.Duration
 
 	mu         sync.Mutex
 	cursorName string
 }
 
+// batchState limits the batches of an iteration, see WithMaxBatches() and WithErrorOnEmpty().
+type batchState struct {
+	max int
+	// count is the amount of batches fetched in the current iteration.
+	count        int
+	errorOnEmpty bool
+}
+
+// reset resets the counter of the fetched batches.
+func (s *batchState) reset() {
+	s.count = 0
+}
+
 // PgxConnector implements the Begin() function from the pgx and pgxpool packages.
 type PgxConnector interface {
 	Begin(ctx context.Context) (pgx.Tx, error)
 }
 
+// PgxTxBeginner implements the BeginTx() function from the pgx and pgxpool packages.
+// It is required for options that configure the transaction, like WithDeferrable().
+type PgxTxBeginner interface {
+	BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error)
+}
+
+// ConnectorFunc is an adapter to use a function as PgxConnector, e.g. one that acquires a specific connection or
+// starts the transaction with custom options.
+type ConnectorFunc func(ctx context.Context) (pgx.Tx, error)
+
+// Begin calls f(ctx).
+func (f ConnectorFunc) Begin(ctx context.Context) (pgx.Tx, error) {
+	return f(ctx)
+}
+
 // NewCursorIterator can be used to create a new iterator.
 // Required parameters:
 //
 //	connector                 most likely a *pgx.Conn or *pgxpool.Pool, needed to start a transaction on the database
 //	values                    a slice where the fetched values should be stored in.
+//	                          slices of struct pointers (e.g. []*User) are supported, nil elements will be allocated.
 //	maxDatabaseExecutionTime  how long should one database operation be allowed to run.
 //	query                     the query to fetch the rows
 //	args                      arguments for the query
@@ -69,10 +189,41 @@ func NewCursorIterator(
 	connector PgxConnector,
 	values interface{},
 	query string, args ...interface{},
+) (*CursorIterator, error) {
+	return NewCursorIteratorWithOptions(connector, values, nil, query, args...)
+}
+
+// NewCursorIteratorWithOptions works like NewCursorIterator() but additionally accepts options
+// to configure the iterator.
+//
+// Example Usage:
+//
+//	values := make([]User, 1000)
+//	iter, err := NewCursorIteratorWithOptions(
+//		pool,
+//		values,
+//		[]Option{WithStatementTimeout(time.Minute)},
+//		"SELECT * FROM users WHERE role = $1", "Guest",
+//	)
+func NewCursorIteratorWithOptions(
+	connector PgxConnector,
+	values interface{},
+	options []Option,
+	query string, args ...interface{},
 ) (*CursorIterator, error) {
 	if connector == nil {
 		return nil, errors.New("connector cannot be nil")
 	}
+	valuesSlice, err := valuesDestinations(values)
+	if err != nil {
+		return nil, err
+	}
+	return newCursorIterator(connector, values, valuesSlice, false, options, query, args...)
+}
+
+// valuesDestinations validates the values passed to NewCursorIterator() and returns the pointers the rows will
+// be scanned into.
+func valuesDestinations(values interface{}) ([]interface{}, error) {
 	if values == nil {
 		return nil, errors.New("values cannot be nil")
 	}
@@ -81,8 +232,12 @@ func NewCursorIterator(
 		return nil, errors.New("values is invalid")
 	}
 
-	if rv.Kind() != reflect.Slice {
-		return nil, errors.New("values must be a slice")
+	switch rv.Kind() {
+	case reflect.Slice:
+	case reflect.Array:
+		return nil, errors.New("values must be a slice, got array (pass values[:] or create the slice with make)")
+	default:
+		return nil, errors.Errorf("values must be a slice, got %s", rv.Kind())
 	}
 
 	valuesCapacity := rv.Cap()
@@ -91,19 +246,67 @@ func NewCursorIterator(
 		return nil, errors.New("values must have a capacity bigger than 0")
 	}
 
-	valuesSlice := make([]interface{}, valuesCapacity)
-	for i := 0; i < valuesCapacity; i++ {
+	// use the full capacity of values
+	rv = rv.Slice(0, valuesCapacity)
+
+	// all elements share the same type, so it is enough to validate the first one
+	elem := rv.Index(0)
+	if !elem.CanAddr() {
+		return nil, errors.Errorf(
+			"unable to reference %s (kind %s): values must be a slice created with make, not an array or a copy of one",
+			elem.Type().String(), elem.Kind(),
+		)
+	}
+	if !elem.Addr().CanInterface() {
+		return nil, errors.Errorf("unable to get interface of %s", elem.Addr().Type().String())
+	}
+
+	return scanDestinations(rv), nil
+}
+
+// scanDestinations returns the pointers the rows will be scanned into for every element of the slice rv.
+// For slices of struct pointers (e.g. []*User) the pointers themselves are used, nil elements will be
+// allocated once and are reused for every batch.
+// All other elements are referenced, so pointers to primitives (e.g. []*int) can still be set to nil by NULL.
+func scanDestinations(rv reflect.Value) []interface{} {
+	destinations := make([]interface{}, rv.Len())
+	elemType := rv.Type().Elem()
+	structPointers := elemType.Kind() == reflect.Ptr && elemType.Elem().Kind() == reflect.Struct
+	for i := range destinations {
 		elem := rv.Index(i)
-		if !elem.CanAddr() {
-			return nil, errors.Errorf("unable to reference %s", elem.Type().String())
+		if !structPointers {
+			destinations[i] = elem.Addr().Interface()
+			continue
 		}
-		elem = elem.Addr()
-		if !elem.CanInterface() {
-			return nil, errors.Errorf("unable to get interface of %s", elem.Type().String())
+		if elem.IsNil() {
+			elem.Set(reflect.New(elemType.Elem()))
 		}
-		valuesSlice[i] = elem.Interface()
+		destinations[i] = elem.Interface()
+	}
+	return destinations
+}
+
+// newCursorIterator creates the iterator, valuesSlice must contain pointers to the elements of values
+// that should be scanned into. ownsValues reports whether values was allocated by the iterator itself.
+func newCursorIterator(
+	connector PgxConnector,
+	values interface{},
+	valuesSlice []interface{},
+	ownsValues bool,
+	options []Option,
+	query string, args ...interface{},
+) (*CursorIterator, error) {
+	iter := newUninitializedIterator(connector, query, args)
+	if err := iter.initialize(context.Background(), values, valuesSlice, ownsValues, options); err != nil {
+		return nil, err
 	}
+	return iter, nil
+}
 
+// newUninitializedIterator creates an iterator without values and options, initialize() must be called before it
+// can be used.
+func newUninitializedIterator(connector PgxConnector, query string, args []interface{}) *CursorIterator {
+	// use a random name by default, so multiple iterators can be used in the same session
 	cursorID := uuid.New()
 	cursorName := hex.EncodeToString(cursorID[:])
 	return &CursorIterator{
@@ -112,92 +315,598 @@ func NewCursorIterator(
 		args:       args,
 		cursorName: cursorName,
 
-		fetchQuery: fmt.Sprintf("FETCH %d IN %q", valuesCapacity, cursorName),
-
-		values:       valuesSlice,
-		valuesPos:    -2,
-		valuesMaxPos: valuesCapacity - 1,
+		valuesPos: -2,
 
 		err: nil,
 
 		tx: nil,
-	}, nil
+
+		observer: nopObserver{},
+		done:     make(chan struct{}),
+		canceled: make(chan struct{}),
+		hasMore:  true,
+	}
 }
 
-func (iter *CursorIterator) fetchNextRows(ctx context.Context) {
-	rows, err := iter.tx.Query(ctx, iter.fetchQuery)
-	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			iter.close(ctx)
-			return
+// initialize sets the values of the iterator and applies the options.
+// ctx is used to validate the query, see WithValidateQuery().
+func (iter *CursorIterator) initialize(
+	ctx context.Context,
+	values interface{},
+	valuesSlice []interface{},
+	ownsValues bool,
+	options []Option,
+) (err error) {
+	defer func() {
+		if err != nil {
+			// the iterator is not returned, so the buffer of WithBufferPool() would leak
+			iter.releaseBufferToPool()
+		}
+	}()
+	valuesCapacity := len(valuesSlice)
+	iter.fetchSize = valuesCapacity
+	iter.valuesRef = values
+	iter.values = valuesSlice
+	iter.pool.ownsValues = ownsValues
+	iter.valuesMaxPos = valuesCapacity - 1
+
+	for _, option := range options {
+		if err := option(iter); err != nil {
+			return err
+		}
+	}
+	if err := iter.validateOptions(); err != nil {
+		return err
+	}
+	if iter.validateQueryTimeout > 0 {
+		if err := iter.prepareQuery(ctx); err != nil {
+			return err
 		}
-		iter.err = err
-		return
 	}
+	iter.options = options
+	iter.setLeakFinalizer()
+	return nil
+}
 
-	scanner := pgxscan.NewRowScanner(rows)
+// validateOptions checks whether the options that were applied can be used together.
+func (iter *CursorIterator) validateOptions() error {
+	if err := iter.validateRefCursor(); err != nil {
+		return err
+	}
+	if err := iter.validateBufferDepth(); err != nil {
+		return err
+	}
+	if err := iter.validateScanMode(); err != nil {
+		return err
+	}
+	if err := iter.validateKeyset(); err != nil {
+		return err
+	}
+	if err := iter.validateMaterialize(); err != nil {
+		return err
+	}
+	if err := iter.validateCommitPerBatch(); err != nil {
+		return err
+	}
+	if err := iter.validateSavepoints(); err != nil {
+		return err
+	}
+	return iter.validateScanConcurrency()
+}
 
-	i := 0
-	for rows.Next() {
-		if i > iter.valuesMaxPos {
+// Clone returns a new, not yet started iterator with the same configuration (query, args, options)
+// as the current one. The clone uses its own transaction and its own values slice, which can be
+// accessed with Values().
+func (iter *CursorIterator) Clone() (*CursorIterator, error) {
+	if iter.refCursor {
+		return nil, errors.New("ref cursor iterator cannot be cloned")
+	}
+	iter.mu.Lock()
+	if iter.valuesRef == nil {
+		iter.mu.Unlock()
+		return nil, errNotInitialized
+	}
+	args := make([]interface{}, len(iter.args))
+	copy(args, iter.args)
+	valuesType := reflect.TypeOf(iter.valuesRef)
+	capacity := len(iter.values)
+	iter.mu.Unlock()
+
+	values := reflect.MakeSlice(valuesType, capacity, capacity).Interface()
+	return NewCursorIteratorWithOptions(iter.connector, values, iter.options, iter.query, args...)
+}
+
+// Rebind rolls back the current transaction (if any), replaces the arguments of the query and resets the
+// iterator, so the next Next() call declares the cursor again with the new arguments.
+// All other configuration (query, options, buffer) is kept. A closed iterator can not be rebound.
+// Rebind creates a new Done() channel.
+func (iter *CursorIterator) Rebind(ctx context.Context, args ...interface{}) error {
+	iter.mu.Lock()
+	defer iter.mu.Unlock()
+	if iter.closed {
+		return errors.New("iterator is closed")
+	}
+	if iter.refCursor {
+		return errors.New("ref cursor iterator cannot be rebound")
+	}
+
+	var err error
+	if iter.tx != nil {
+		err = iter.close(ctx)
+		iter.notifyClosed(ctx)
+	}
+	// the query is materialized again with the new arguments
+	if dropErr := iter.dropMaterialized(ctx); err == nil {
+		err = dropErr
+	}
+
+	iter.args = make([]interface{}, len(args))
+	copy(iter.args, args)
+	iter.reset()
+	return errors.Wrap(err, "unable to rollback transaction")
+}
+
+// ReopenFrom rolls back the current transaction (if any) and restarts the iteration right after the row with
+// lastKey in keyColumn, so an iteration can be continued after Close().
+// The query will be wrapped like WithResumeFrom() does, so keyColumn must be part of the query's result and should
+// be unique. The iteration only continues without gaps or duplicates if the rows were already ordered by keyColumn
+// before (e.g. with ORDER BY keyColumn in the query, WithOrderBy() or WithResumeFrom()), the reopened iteration is
+// ordered by keyColumn unless WithOrderBy() is used. keyColumn must be usable with the options of the iterator, e.g.
+// it must be the key column of WithCommitPerBatch().
+// Unlike Rebind() ReopenFrom can be used after Close(), all other configuration (query, args, options) is kept.
+// The next Next() call declares the cursor again.
+// ReopenFrom creates a new Done() channel.
+func (iter *CursorIterator) ReopenFrom(ctx context.Context, keyColumn string, lastKey interface{}) error {
+	iter.mu.Lock()
+	defer iter.mu.Unlock()
+	if keyColumn == "" {
+		return errors.New("key column cannot be empty")
+	}
+	if iter.refCursor {
+		return errors.New("ref cursor iterator cannot be reopened")
+	}
+	if iter.pool.released {
+		return errors.New("buffer was returned to the buffer pool, the iterator cannot be reopened")
+	}
+	resumeColumn, resumeValue := iter.resumeColumn, iter.resumeValue
+	iter.resumeColumn, iter.resumeValue = keyColumn, lastKey
+	if err := iter.validateOptions(); err != nil {
+		iter.resumeColumn, iter.resumeValue = resumeColumn, resumeValue
+		return err
+	}
+
+	var err error
+	if iter.tx != nil {
+		err = iter.close(ctx)
+		iter.notifyClosed(ctx)
+	}
+	// the query is materialized again after the key
+	if dropErr := iter.dropMaterialized(ctx); err == nil {
+		err = dropErr
+	}
+
+	iter.closed = false
+	iter.reset()
+	return errors.Wrap(err, "unable to rollback transaction")
+}
+
+// reset resets the state of the iteration, so the next Next() call declares the cursor again.
+func (iter *CursorIterator) reset() {
+	iter.valuesPos = -2
+	iter.valuesMaxPos = len(iter.values) - 1
+	iter.err = nil
+	iter.lastCommandTag = pgconn.CommandTag{}
+	iter.hasMore = true
+	iter.closeNotified = false
+	iter.done = make(chan struct{})
+	iter.fetchedRows = 0
+	iter.skippedCount = 0
+	iter.lockSkippedCount = 0
+	iter.batch.reset()
+	iter.keyset.reset()
+	iter.commit.reset()
+	iter.savepoint.reset()
+	iter.position = 0
+	iter.cursorOffset = 0
+	iter.partialLen = 0
+	iter.snapshotLen = 0
+	iter.schema = nil
+}
+
+// CursorName returns the name of the cursor the iterator declares.
+func (iter *CursorIterator) CursorName() string {
+	return iter.cursorName
+}
+
+// cursorIdentifier returns the quoted cursor name that can be used in sql statements.
+func (iter *CursorIterator) cursorIdentifier() string {
+	return pgx.Identifier{iter.cursorName}.Sanitize()
+}
+
+// fetchDirection is the direction a FETCH moves the cursor into.
+type fetchDirection int
+
+const (
+	fetchForward fetchDirection = iota
+	fetchBackward
+)
+
+func (d fetchDirection) String() string {
+	if d == fetchBackward {
+		return "BACKWARD"
+	}
+	return "FORWARD"
+}
+
+// buildFetchQuery returns the statement to fetch count rows in the given direction.
+// Notice that fetching backward requires a scrollable cursor.
+func (iter *CursorIterator) buildFetchQuery(direction fetchDirection, count int) string {
+	if iter.fetchTemplate != "" {
+		return iter.executeFetchTemplate(direction, count)
+	}
+	return fmt.Sprintf("FETCH %s %d IN %s", direction, count, iter.cursorIdentifier())
+}
+
+// HasMore reports whether the cursor may have more rows, it is a heuristic and does not query the database:
+// It returns true until a fetch returned less rows than requested (or no rows at all), because that signals
+// the end of the cursor. If the last fetch filled the buffer completely it returns true, even if there are
+// no more rows in the cursor.
+// Notice that the rows of the current batch may still be consumed with Next() after HasMore returned false.
+func (iter *CursorIterator) HasMore() bool {
+	iter.mu.Lock()
+	defer iter.mu.Unlock()
+	return iter.hasMore
+}
+
+// RowsReturned reports whether the iteration fetched at least one row, see WithErrorOnEmpty().
+func (iter *CursorIterator) RowsReturned() bool {
+	iter.mu.Lock()
+	defer iter.mu.Unlock()
+	return iter.fetchedRows > 0
+}
+
+// Query returns the query the iterator was created with.
+func (iter *CursorIterator) Query() string {
+	iter.mu.Lock()
+	query := iter.query
+	iter.mu.Unlock()
+	return query
+}
+
+// Args returns a copy of the arguments the iterator was created with.
+func (iter *CursorIterator) Args() []interface{} {
+	iter.mu.Lock()
+	args := make([]interface{}, len(iter.args))
+	copy(args, iter.args)
+	iter.mu.Unlock()
+	return args
+}
+
+// ExportSnapshot exports the snapshot of the iterators transaction with pg_export_snapshot(),
+// so other iterators can use the same snapshot with WithSnapshot().
+// The transaction is started with the first Next() call, and the snapshot is only valid
+// as long as the iterator is not closed. It can not be used with WithBufferDepth().
+func (iter *CursorIterator) ExportSnapshot(ctx context.Context) (string, error) {
+	iter.mu.Lock()
+	defer iter.mu.Unlock()
+	if iter.bufferDepth > 0 {
+		return "", errors.New("snapshot cannot be exported with buffer depth")
+	}
+	if iter.tx == nil {
+		return "", errors.New("iterator has no open transaction")
+	}
+	var snapshotID string
+	if err := iter.tx.QueryRow(ctx, "SELECT pg_export_snapshot()").Scan(&snapshotID); err != nil {
+		return "", errors.Wrap(err, "unable to export snapshot")
+	}
+	return snapshotID, nil
+}
+
+// Values returns the values slice the iterator stores the fetched rows in.
+func (iter *CursorIterator) Values() interface{} {
+	return iter.valuesRef
+}
+
+func (iter *CursorIterator) fetchNextRows(ctx context.Context) {
+	if iter.prefetch != nil {
+		iter.nextPrefetchedBatch(ctx)
+		return
+	}
+	if iter.keyset.column != "" {
+		iter.fetchKeysetRows(ctx)
+		return
+	}
+	for {
+		start := time.Now()
+		fetchSize := iter.fetchSize
+		if iter.limit > 0 {
+			// do not fetch more rows than allowed by WithLimit()
+			remaining := iter.limit - iter.fetchedRows
+			if remaining <= 0 {
+				iter.close(ctx)
+				return
+			}
+			if remaining < int64(fetchSize) {
+				fetchSize = int(remaining)
+			}
+		}
+		if err := iter.startSavepoint(ctx); err != nil {
 			iter.close(ctx)
-			iter.err = errors.New("database returned more rows than expected")
+			iter.err = err
+			return
+		}
+		rows, err := iter.tx.Query(ctx, iter.buildFetchQuery(fetchForward, fetchSize), iter.fetchArgs()...)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				iter.close(ctx)
+				return
+			}
+			if isContextError(err) {
+				// do not wrap context errors, the fetch can be retried with another context
+				iter.err = err
+				return
+			}
+			if isConnectionLost(err) {
+				iter.close(ctx)
+				iter.err = fmt.Errorf("%w: %w", ErrCursorLost, err)
+				return
+			}
+			if isTransactionAborted(err) {
+				iter.close(ctx)
+				iter.err = fmt.Errorf("%w: %w", ErrTransactionAborted, err)
+				return
+			}
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) {
+				// an error of the database aborts the transaction, further fetches would fail with 25P02
+				iter.close(ctx)
+				iter.err = err
+				return
+			}
+			iter.err = err
 			return
 		}
-		if err := scanner.Scan(iter.values[i]); err != nil {
+
+		i, skipped, err := iter.scanRows(ctx, rows, fetchSize)
+		if err != nil {
 			iter.close(ctx)
-			iter.err = errors.Wrap(err, "unable to scan into values element")
+			iter.err = err
 			return
 		}
-		i++
-	}
 
-	if err := rows.Err(); err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
+		if !skipped {
+			if err := rows.Err(); err != nil {
+				if errors.Is(err, pgx.ErrNoRows) {
+					iter.close(ctx)
+					return
+				}
+				iter.close(ctx)
+				if isContextError(err) {
+					// do not wrap context errors, so callers can distinguish timeouts from database errors
+					iter.err = err
+					return
+				}
+				if isConnectionLost(err) {
+					iter.err = fmt.Errorf("%w: %w", ErrCursorLost, err)
+					return
+				}
+				if isTransactionAborted(err) {
+					iter.err = fmt.Errorf("%w: %w", ErrTransactionAborted, err)
+					return
+				}
+				iter.err = errors.Wrap(err, "unable to fetch rows")
+				return
+			}
+			iter.lastCommandTag = rows.CommandTag()
+		}
+		if i == 0 {
+			if skipped {
+				// all fetched rows were skipped, continue with the next batch
+				continue
+			}
+			// the cursor only moves forward (skipRow() moves a scrollable cursor onto the skipped row, but never
+			// behind the current position), so a fetch without rows always means that the cursor is exhausted
 			iter.close(ctx)
 			return
 		}
-		iter.close(ctx)
-		iter.err = errors.Wrap(err, "unable to fetch rows")
+		iter.adaptFetchSize(i, time.Since(start))
+		fetched := i
+		if iter.lockRows != nil {
+			i, err = iter.lockRows(ctx, fetched)
+			if err != nil {
+				iter.close(ctx)
+				iter.err = errors.Wrap(err, "unable to acquire advisory locks")
+				return
+			}
+			if i == 0 {
+				// all rows are locked by someone else
+				iter.fetchedRows += int64(fetched)
+				if fetched < fetchSize {
+					iter.close(ctx)
+					return
+				}
+				continue
+			}
+		}
+		if iter.afterFetch != nil {
+			if err := iter.afterFetch(i); err != nil {
+				iter.close(ctx)
+				iter.err = errors.Wrap(err, "after fetch hook failed")
+				return
+			}
+		}
+		iter.fetchedRows += int64(fetched)
+		iter.hasMore = fetched >= fetchSize && (iter.limit == 0 || iter.fetchedRows < iter.limit)
+		iter.valuesPos = 0
+		iter.valuesMaxPos = i
 		return
 	}
-	if i == 0 {
-		iter.close(ctx)
+}
+
+// scanContextCheckInterval is the number of scanned rows after which the scan loops check whether the context
+// is done, so canceling a large batch does not have to wait until all of its rows were scanned.
+const scanContextCheckInterval = 256
+
+// scanRows scans the fetched rows into values and returns the amount of scanned rows.
+// skipped reports whether a row was skipped because of WithSkipScanErrors(), in that case
+// rows is closed and the cursor is positioned after the skipped row.
+func (iter *CursorIterator) scanRows(ctx context.Context, rows pgx.Rows, fetchSize int) (n int, skipped bool, err error) {
+	if iter.scanConcurrency > 1 {
+		n, err := iter.scanRowsConcurrently(ctx, rows, fetchSize)
+		return n, false, err
+	}
+	scanner := pgxscan.NewRowScanner(rows)
+	for rows.Next() {
+		// the fetch size can be smaller than the capacity of values, so guard against the requested amount
+		if n >= fetchSize {
+			return n, false, errors.New("database returned more rows than expected")
+		}
+		if n > 0 && n%scanContextCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return n, false, err
+			}
+		}
+		if n == 0 {
+			// the last batch gets overwritten
+			iter.snapshotLen = 0
+			if err := iter.checkSchema(rows.FieldDescriptions()); err != nil {
+				return n, false, err
+			}
+			if iter.scanMode == scanModeRaw {
+				iter.rawFields = append(iter.rawFields[:0], rows.FieldDescriptions()...)
+			}
+		}
+		if iter.commit.column != "" {
+			if err := iter.recordCommitKey(rows); err != nil {
+				return n, false, err
+			}
+		}
+		if err := scanRow(iter.scanMode, scanner, rows, iter.values[n]); err != nil {
+			if iter.skipScanErrors == nil {
+				iter.partialLen = n
+				return n, false, scanError(err, rows, n, iter.values[n])
+			}
+			return n, true, iter.skipRow(ctx, rows, n, err)
+		}
+		n++
+	}
+	iter.position += int64(n)
+	return n, false, nil
+}
+
+// skipRow skips the row at index n of the current batch that could not be scanned.
+// pgx closes the rows after a failed scan, so the remaining rows of the batch are lost.
+// To get them again the (scrollable) cursor will be moved onto the skipped row,
+// so the next fetch continues right after it.
+func (iter *CursorIterator) skipRow(ctx context.Context, rows pgx.Rows, n int, scanErr error) error {
+	rows.Close()
+	rowIndex := iter.position + int64(n)
+	iter.position = rowIndex + 1
+	iter.skippedCount++
+	iter.skipScanErrors(rowIndex, scanErr)
+
+	// the cursor might have been declared again after rows were fetched, see WithCommitPerBatch()
+	move := fmt.Sprintf("MOVE ABSOLUTE %d IN %s", iter.position-iter.cursorOffset, iter.cursorIdentifier())
+	if _, err := iter.tx.Exec(ctx, move); err != nil {
+		return errors.Wrap(err, "unable to move cursor after skipped row")
+	}
+	return nil
+}
+
+// adaptFetchSize adjusts the fetch size if WithAdaptiveBatch() is used.
+// The fetch size will be doubled if a fetch returned a full batch fast enough
+// and halved if a fetch took longer than adaptiveBatchTargetDuration.
+func (iter *CursorIterator) adaptFetchSize(rows int, d time.Duration) {
+	if iter.adaptiveMaxFetchSize == 0 {
 		return
 	}
-	iter.valuesPos = 0
-	iter.valuesMaxPos = i
+	switch {
+	case d > adaptiveBatchTargetDuration:
+		iter.fetchSize /= 2
+		if iter.fetchSize < iter.adaptiveMinFetchSize {
+			iter.fetchSize = iter.adaptiveMinFetchSize
+		}
+	case rows >= iter.fetchSize:
+		iter.fetchSize *= 2
+		if iter.fetchSize > iter.adaptiveMaxFetchSize {
+			iter.fetchSize = iter.adaptiveMaxFetchSize
+		}
+	}
 }
 
 // Next will return true if there is a next value available, false if there is no next value available.
 // Next will also fetch next values when all current values have been iterated.
+//
+// The iterator does not store a context, every call uses the ctx it was called with. So if a context becomes
+// unsuitable (e.g. the request ended) the iteration can be continued by passing another context to the
+// following calls. A fetch that failed because its context was already canceled or past its deadline when the fetch
+// started can be retried with another context. If the context ends while a fetch is running, pgx closes the
+// connection, so the cursor is lost and the iteration ends.
+// If ctx is already done on the first call, its error is returned without starting a transaction.
 func (iter *CursorIterator) Next(ctx context.Context) bool {
 	iter.mu.Lock()
 	defer iter.mu.Unlock()
-	// it is not the first row, and we already iterated over all rows: early exit
-	if iter.valuesPos == -1 {
+	return iter.next(ctx)
+}
+
+// NextErr works like Next() but additionally returns the error that occurred during this step,
+// so errors can be handled inline without calling Error().
+//
+// Example Usage:
+//
+//	for {
+//		ok, err := iter.NextErr(ctx)
+//		if err != nil {
+//			panic(err)
+//		}
+//		if !ok {
+//			break
+//		}
+//		fmt.Printf("Name: %s\n", values[iter.ValueIndex()].Name)
+//	}
+func (iter *CursorIterator) NextErr(ctx context.Context) (bool, error) {
+	iter.mu.Lock()
+	defer iter.mu.Unlock()
+	ok := iter.next(ctx)
+	return ok, iter.err
+}
+
+func (iter *CursorIterator) next(ctx context.Context) bool {
+	// it is not the first row, and we already iterated over all rows or the iterator was closed: early exit
+	if iter.valuesPos == -1 || iter.closed {
+		return false
+	}
+	if iter.abortIfCanceled(ctx) {
 		return false
 	}
 
 	if iter.valuesPos == -2 {
+		// do not touch the database if ctx is already done
+		if err := ctx.Err(); err != nil {
+			iter.err = err
+			return false
+		}
+		if err := iter.initializeLazy(ctx); err != nil {
+			iter.err = err
+			return false
+		}
 		// first call:
 		// start a transaction
 		// and declare the cursor
-		// start a transaction
-		iter.tx, iter.err = iter.connector.Begin(ctx)
-		if iter.err != nil {
-			iter.err = errors.Wrap(iter.err, "unable to start transaction")
+		opCtx, cancel := iter.cancelable(ctx)
+		defer cancel()
+		if err := iter.begin(opCtx); err != nil {
+			if iter.abortIfCanceled(ctx) {
+				return false
+			}
+			iter.err = err
 			return false
 		}
-
-		// declare cursor
-		query := fmt.Sprintf("DECLARE %q CURSOR FOR %s", iter.cursorName, iter.query)
-		if _, err := iter.tx.Exec(ctx, query, iter.args...); err != nil {
-			iter.err = errors.Wrap(err, "unable to declare cursor")
+		// fetch the initial rows
+		iter.fetch(opCtx)
+		if iter.err != nil && iter.abortIfCanceled(ctx) {
 			return false
 		}
-		// fetch the initial rows
-		iter.fetchNextRows(ctx)
 		// return true if we have rows
 		return iter.valuesPos == 0
 	}
@@ -208,18 +917,408 @@ func (iter *CursorIterator) Next(ctx context.Context) bool {
 		return true
 	}
 
-	// we hit the end: fetch the next chunk of rows
-	iter.fetchNextRows(ctx)
+	// do not touch the database if ctx is already done, so the fetch can be retried with another context
+	if err := ctx.Err(); err != nil {
+		iter.err = err
+		return false
+	}
+
+	// we hit the end: stop if requested with WithStopOnNotify() or fetch the next chunk of rows
+	if iter.stopRequested() {
+		iter.close(ctx)
+		iter.notifyClosed(ctx)
+		return false
+	}
+	opCtx, cancel := iter.cancelable(ctx)
+	defer cancel()
+	if iter.commit.column != "" {
+		if err := iter.commitBatch(opCtx); err != nil {
+			if iter.abortIfCanceled(ctx) {
+				return false
+			}
+			iter.close(ctx)
+			iter.err = err
+			iter.notifyClosed(ctx)
+			return false
+		}
+	}
+	iter.fetch(opCtx)
+	if iter.err != nil && iter.abortIfCanceled(ctx) {
+		return false
+	}
 	return iter.valuesPos == 0
 }
 
+// begin starts the transaction and declares the cursor, retrying if WithRetry() was used.
+func (iter *CursorIterator) begin(ctx context.Context) error {
+	for attempt := 1; ; attempt++ {
+		phase, err := iter.beginOnce(ctx)
+		if err == nil {
+			return nil
+		}
+		if !iter.shouldRetry(attempt, err) {
+			return iter.formatError(phase, err)
+		}
+		select {
+		case <-ctx.Done():
+			return iter.formatError(phase, err)
+		case <-time.After(iter.retry.backoff):
+		}
+	}
+}
+
+// beginOnce starts the transaction and declares the cursor, without retrying.
+// It returns the phase that failed, see WithErrorFormatter().
+func (iter *CursorIterator) beginOnce(ctx context.Context) (string, error) {
+	if iter.keyset.column != "" {
+		// keyset pagination starts a transaction for every batch, see fetchKeysetRows()
+		return "", nil
+	}
+	// start a transaction
+	if !iter.allowOperation() {
+		return PhaseBegin, errors.Wrap(ErrCircuitOpen, "unable to start transaction")
+	}
+	if iter.materialization.table != "" && !iter.materialization.done {
+		err := iter.materialize(ctx)
+		iter.recordOperation(err)
+		if err != nil {
+			return PhaseDeclare, err
+		}
+	}
+	tx, err := iter.beginTx(ctx)
+	iter.recordOperation(err)
+	if err != nil {
+		if isPoolExhausted(iter.connector, err) {
+			err = fmt.Errorf("%w: %w", ErrPoolExhausted, err)
+		}
+		return PhaseBegin, errors.Wrap(err, "unable to start transaction")
+	}
+	iter.tx = tx
+
+	err = iter.declare(ctx)
+	if err == nil {
+		err = iter.startStopListener(ctx)
+	}
+	if err != nil {
+		// rollback, so the next call can start over
+		_ = iter.tx.Rollback(ctx)
+		iter.unregisterNoticeHandler()
+		iter.tx = nil
+		return PhaseDeclare, err
+	}
+	iter.observer.Declared(ctx)
+	if iter.bufferDepth > 0 {
+		iter.startPrefetch()
+	}
+	return "", nil
+}
+
+// beginTx starts the transaction, using the transaction options if any were configured.
+func (iter *CursorIterator) beginTx(ctx context.Context) (pgx.Tx, error) {
+	// starting the transaction acquires the connection
+	defer iter.recordAcquire(time.Now())
+	connector := iter.sessionConnector()
+	if iter.txOptions == nil {
+		return connector.Begin(ctx)
+	}
+	beginner, ok := connector.(PgxTxBeginner)
+	if !ok {
+		return nil, errors.Errorf("connector %T does not support transaction options", connector)
+	}
+	return beginner.BeginTx(ctx, *iter.txOptions)
+}
+
+// declare prepares the transaction and declares the cursor.
+func (iter *CursorIterator) declare(ctx context.Context) error {
+	if iter.refCursor {
+		// the cursor was opened by the caller, see NewRefCursorIterator()
+		return nil
+	}
+	if !iter.allowOperation() {
+		return errors.Wrap(ErrCircuitOpen, "unable to declare cursor")
+	}
+
+	if err := iter.prepareTransaction(ctx); err != nil {
+		iter.recordOperation(err)
+		return err
+	}
+
+	query, args := iter.cursorQuery()
+	if iter.materialization.done {
+		// the result was stored by materialize(), the table does not keep the order of the rows
+		query, args = "SELECT * FROM "+iter.materializedIdentifier(), nil
+		if orderBy := iter.cursorOrder(); orderBy != "" {
+			query += " ORDER BY " + orderBy
+		}
+	}
+
+	// declare cursor
+	insensitive := ""
+	if iter.insensitive {
+		insensitive = "INSENSITIVE "
+	}
+	scroll := ""
+	if iter.scroll || iter.skipScanErrors != nil {
+		// skipping rows requires moving the cursor, see skipRow()
+		scroll = "SCROLL "
+	}
+	hold := ""
+	if iter.hold {
+		hold = "WITH HOLD "
+	}
+	declareQuery := fmt.Sprintf("DECLARE %s %s%sCURSOR %sFOR %s", iter.cursorIdentifier(), insensitive, scroll, hold, query)
+	_, err := iter.tx.Exec(ctx, declareQuery, args...)
+	iter.recordOperation(err)
+	if err != nil {
+		return errors.Wrap(err, "unable to declare cursor")
+	}
+	return nil
+}
+
+// cursorQuery returns the query and the arguments the cursor will be declared for.
+// If WithResumeFrom() is used the query will be wrapped to only return rows after the resume value,
+// if WithOrderBy() is used the query will be wrapped to order the rows.
+// WithWrapSubquery() wraps the query before the other options are applied.
+func (iter *CursorIterator) cursorQuery() (string, []interface{}) {
+	query, args := iter.query, iter.args
+	if iter.wrapSubquery {
+		query = fmt.Sprintf("SELECT * FROM (%s) AS _sub", strings.TrimRight(strings.TrimSpace(query), "; \t\n"))
+	}
+	orderBy := iter.cursorOrder()
+	resumeColumn, resumeValue := iter.resumeColumn, iter.resumeValue
+	if iter.commit.started {
+		resumeColumn, resumeValue = iter.commit.column, iter.commit.value
+	}
+	if resumeColumn != "" {
+		column := pgx.Identifier{resumeColumn}.Sanitize()
+		args = make([]interface{}, len(iter.args), len(iter.args)+1)
+		copy(args, iter.args)
+		args = append(args, resumeValue)
+		operator := ">"
+		if iter.keyset.column == "" && orderedDescending(orderBy, column) {
+			// the rows after the resume value have smaller values
+			operator = "<"
+		}
+		query = fmt.Sprintf("SELECT * FROM (%s) AS resume WHERE %s %s $%d", query, column, operator, len(args))
+	} else if orderBy != "" {
+		query = fmt.Sprintf("SELECT * FROM (%s) AS ordered", query)
+	}
+	if orderBy != "" {
+		query += " ORDER BY " + orderBy
+	}
+	return query, args
+}
+
+// cursorOrder returns the ORDER BY of the cursor query, see cursorQuery().
+func (iter *CursorIterator) cursorOrder() string {
+	switch {
+	case iter.orderBy != "":
+		return iter.orderBy
+	case iter.commit.column != "":
+		// every batch continues after the last key of the previous batch, see WithCommitPerBatch()
+		return pgx.Identifier{iter.commit.column}.Sanitize()
+	case iter.resumeColumn != "":
+		return pgx.Identifier{iter.resumeColumn}.Sanitize()
+	}
+	return ""
+}
+
+// orderedDescending reports whether orderBy (see WithOrderBy()) orders by the quoted column in descending order.
+func orderedDescending(orderBy, column string) bool {
+	for _, order := range strings.Split(orderBy, ", ") {
+		if order == column+" DESC" {
+			return true
+		}
+	}
+	return false
+}
+
+// prepareTransaction configures the transaction before the cursor gets declared.
+func (iter *CursorIterator) prepareTransaction(ctx context.Context) error {
+	if err := iter.registerNoticeHandler(); err != nil {
+		return err
+	}
+
+	// use the snapshot of another transaction, this must happen before any other query.
+	// A materialized table already contains the rows of the snapshot, see materialize()
+	if iter.snapshotID != "" && !iter.materialization.done {
+		if _, err := iter.tx.Exec(ctx, "SET TRANSACTION ISOLATION LEVEL REPEATABLE READ"); err != nil {
+			return errors.Wrap(err, "unable to set transaction isolation level")
+		}
+		query := fmt.Sprintf("SET TRANSACTION SNAPSHOT '%s'", strings.ReplaceAll(iter.snapshotID, "'", "''"))
+		if _, err := iter.tx.Exec(ctx, query); err != nil {
+			return errors.Wrap(err, "unable to set transaction snapshot")
+		}
+	}
+
+	if iter.readOnly {
+		if _, err := iter.tx.Exec(ctx, "SET TRANSACTION READ ONLY"); err != nil {
+			return errors.Wrap(err, "unable to set transaction read only")
+		}
+	}
+
+	// set the server side statement timeout
+	if iter.statementTimeout > 0 {
+		query := fmt.Sprintf("SET LOCAL statement_timeout = %d", iter.statementTimeout.Milliseconds())
+		if _, err := iter.tx.Exec(ctx, query); err != nil {
+			return errors.Wrap(err, "unable to set statement timeout")
+		}
+	}
+
+	// identify the session in pg_stat_activity, set_config() is used because SET does not support parameters
+	if iter.applicationName != "" {
+		if _, err := iter.tx.Exec(ctx, "SELECT set_config('application_name', $1, true)", iter.applicationName); err != nil {
+			return errors.Wrap(err, "unable to set application name")
+		}
+	}
+
+	if iter.connectionInit != nil {
+		if err := iter.connectionInit(ctx, iter.tx); err != nil {
+			return errors.Wrap(err, "connection init failed")
+		}
+	}
+
+	if iter.typeRegistration != nil {
+		conn := iter.tx.Conn()
+		if conn == nil {
+			return errors.New("type registration requires a pgx connection")
+		}
+		if err := iter.typeRegistration(ctx, conn); err != nil {
+			return errors.Wrap(err, "unable to register types")
+		}
+	}
+	return nil
+}
+
+// fetch fetches the next chunk of rows.
+func (iter *CursorIterator) fetch(ctx context.Context) {
+	if !iter.allowOperation() {
+		iter.err = errors.Wrap(ErrCircuitOpen, "unable to fetch rows")
+		return
+	}
+	if iter.batch.max > 0 && iter.batch.count >= iter.batch.max && iter.hasMore {
+		iter.close(ctx)
+		iter.err = errors.Wrapf(ErrMaxBatchesExceeded, "iteration stopped after %d batches", iter.batch.count)
+		iter.notifyClosed(ctx)
+		return
+	}
+	iter.batch.count++
+	iter.err = nil
+	iter.partialLen = 0
+	iter.observer.FetchStarted(ctx, iter.fetchSize)
+	start := time.Now()
+	iter.fetchNextRows(ctx)
+	iter.recordOperation(iter.err)
+	iter.err = iter.formatError(PhaseFetch, iter.err)
+	rows := 0
+	if iter.valuesPos == 0 {
+		rows = iter.valuesMaxPos
+		iter.snapshotLen = rows
+	}
+	elapsed := time.Since(start)
+	iter.recordProfile(rows, elapsed)
+	iter.recordLatency(elapsed)
+	iter.observer.FetchCompleted(ctx, rows, elapsed, iter.err)
+	if iter.valuesPos == -1 {
+		if iter.err == nil && iter.batch.errorOnEmpty && iter.fetchedRows == 0 {
+			iter.err = ErrNoRows
+		}
+		iter.notifyClosed(ctx)
+	}
+}
+
+// Explain runs EXPLAIN for the iterators query inside a separate transaction and returns the plan.
+// It does not declare the cursor and does not change the state of the iterator,
+// so it can be used to validate a query before iterating over it.
+func (iter *CursorIterator) Explain(ctx context.Context) (string, error) {
+	if iter.refCursor {
+		return "", errors.New("ref cursor iterator cannot be explained")
+	}
+	tx, err := iter.connector.Begin(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to start transaction")
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	query, args := iter.cursorQuery()
+	rows, err := tx.Query(ctx, "EXPLAIN "+query, args...)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to explain query")
+	}
+	defer rows.Close()
+
+	var plan []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return "", errors.Wrap(err, "unable to scan query plan")
+		}
+		plan = append(plan, line)
+	}
+	if err := rows.Err(); err != nil {
+		return "", errors.Wrap(err, "unable to explain query")
+	}
+	return strings.Join(plan, "\n"), nil
+}
+
+// Validate checks whether the iterators query can be planned by the database.
+// It uses Explain() and therefore does not consume the iterator.
+func (iter *CursorIterator) Validate(ctx context.Context) error {
+	_, err := iter.Explain(ctx)
+	return err
+}
+
 // ValueIndex will return the current value index that can be used to fetch the current value.
 // Notice that it will return values below 0 when there is no next value available or the iteration didn't started yet.
 func (iter *CursorIterator) ValueIndex() int {
 	iter.mu.Lock()
-	i := iter.valuesPos
+	defer iter.mu.Unlock()
+	if iter.reverseBuffers && iter.valuesPos >= 0 {
+		return iter.valuesMaxPos - 1 - iter.valuesPos
+	}
+	return iter.valuesPos
+}
+
+// BatchLen returns the number of valid values in the current batch.
+// It returns 0 if the iteration did not start yet or is finished.
+func (iter *CursorIterator) BatchLen() int {
+	iter.mu.Lock()
+	defer iter.mu.Unlock()
+	if iter.valuesPos < 0 {
+		return 0
+	}
+	return iter.valuesMaxPos
+}
+
+// Capacity returns the size of the buffer that is used to store the fetched values.
+// It returns an error for an iterator of NewCursorIteratorLazy() that was not initialized by Next() yet.
+func (iter *CursorIterator) Capacity() (int, error) {
+	iter.mu.Lock()
+	defer iter.mu.Unlock()
+	if iter.valuesRef == nil {
+		return 0, errNotInitialized
+	}
+	return len(iter.values), nil
+}
+
+// SkippedCount returns the amount of rows that were skipped because they could not be scanned,
+// see WithSkipScanErrors().
+func (iter *CursorIterator) SkippedCount() int64 {
+	iter.mu.Lock()
+	n := iter.skippedCount
+	iter.mu.Unlock()
+	return n
+}
+
+// LastCommandTag returns the command tag that was reported by the database for the last fetch,
+// it can be used to read the amount of rows that were fetched.
+func (iter *CursorIterator) LastCommandTag() pgconn.CommandTag {
+	iter.mu.Lock()
+	tag := iter.lastCommandTag
 	iter.mu.Unlock()
-	return i
+	return tag
 }
 
 // Error will return the last error that appeared during fetching.
@@ -230,22 +1329,76 @@ func (iter *CursorIterator) Error() error {
 	return err
 }
 
-func (iter *CursorIterator) close(ctx context.Context) {
-	if iter.tx == nil {
-		iter.err = nil
-		return
+// close rolls back the transaction, drops the table of WithMaterialize() and returns the error of the rollback.
+// The rollback error becomes the error of the iteration, unless an error was already recorded: close never
+// clears a previous error.
+func (iter *CursorIterator) close(ctx context.Context) error {
+	// end the iteration even without a transaction, keyset pagination only has one while a batch is fetched
+	iter.hasMore = false
+	iter.valuesPos = -1
+	var err error
+	if iter.tx != nil {
+		// the prefetcher must not use the transaction anymore
+		iter.stopPrefetch(ctx)
+		iter.stopStopListener()
+		err = iter.rollback(ctx)
+		iter.unregisterNoticeHandler()
+		iter.tx = nil
+	}
+	if dropErr := iter.dropMaterialized(ctx); err == nil {
+		err = dropErr
+	}
+	if iter.err == nil {
+		iter.err = err
 	}
+	return err
+}
 
-	iter.err = iter.tx.Rollback(ctx)
-	iter.tx = nil
-	iter.valuesPos = -1
+// rollback rolls back the transaction.
+// If WithFastShutdown() is used and ctx is already done, the rollback will be attempted with a short timeout
+// and its error will be ignored.
+func (iter *CursorIterator) rollback(ctx context.Context) error {
+	if iter.fastShutdownTimeout <= 0 || ctx.Err() == nil {
+		return iter.tx.Rollback(ctx)
+	}
+	rollbackCtx, cancel := context.WithTimeout(context.Background(), iter.fastShutdownTimeout)
+	defer cancel()
+	// best effort, pgx closes the connection if the rollback fails
+	_ = iter.tx.Rollback(rollbackCtx)
+	return nil
+}
+
+// Done returns a channel that is closed when the iterator finished (Next() returned false because there are no
+// more rows or because of an error that ended the iteration) or was closed with Close().
+func (iter *CursorIterator) Done() <-chan struct{} {
+	iter.mu.Lock()
+	defer iter.mu.Unlock()
+	return iter.done
 }
 
 // Close will close the iterator and all Next() calls will return false.
-// After Close the iterator is unusable and can not be used again.
+// After Close the iterator can not be used again, unless the iteration is continued with ReopenFrom().
+// The values are not cleared, they contain whatever was scanned last (see TypedCursorIterator.Snapshot()).
+// Close returns the error of the iteration (see Error()) joined with the error of the rollback,
+// so an unchecked iteration error is not dropped.
 func (iter *CursorIterator) Close(ctx context.Context) error {
 	iter.mu.Lock()
 	defer iter.mu.Unlock()
-	iter.close(ctx)
-	return iter.err
+	iter.closed = true
+	iterationErr := iter.err
+	rollbackErr := iter.formatError(PhaseClose, iter.close(ctx))
+	iter.err = rollbackErr
+	if iterationErr != nil {
+		// keep the iteration error for Error()
+		iter.err = iterationErr
+	}
+	iter.notifyClosed(ctx)
+	iter.releaseBufferToPool()
+	if iterationErr == nil {
+		return rollbackErr
+	}
+	if rollbackErr == nil {
+		return iterationErr
+	}
+	return stderrors.Join(iterationErr, rollbackErr)
 }
@@ -2,14 +2,18 @@ package cursoriterator_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/stretchr/testify/require"
 
 	cursoriterator "github.com/Eun/go-pgx-cursor-iterator/v2"
+	"github.com/Eun/go-pgx-cursor-iterator/v2/cursoriteratortest"
 )
 
 // make sure PgxConnector implements pgxpool.Pool.
@@ -23,7 +27,7 @@ type User struct {
 	Name string `db:"name"`
 }
 
-func runTest(t *testing.T, usersToInsert []User, fn func(pool *pgxpool.Pool)) {
+func runTest(t testing.TB, usersToInsert []User, fn func(pool *pgxpool.Pool)) {
 	testDB := NewTestDatabase(t)
 	defer testDB.Close(t)
 
@@ -46,16 +50,22 @@ CREATE TABLE users (
 	fn(pool)
 }
 
-func expectValues(t *testing.T, iter *cursoriterator.CursorIterator, values []User, expected ...User) {
-	for _, user := range expected {
+func expectValues[T any](t *testing.T, iter *cursoriterator.CursorIterator, values []T, expected ...T) {
+	for _, value := range expected {
 		require.True(t, iter.Next(context.Background()))
 		require.NoError(t, iter.Error())
-		require.Equal(t, user, values[iter.ValueIndex()])
+		require.Equal(t, value, values[iter.ValueIndex()])
 	}
 	require.False(t, iter.Next(context.Background()))
 	require.NoError(t, iter.Error())
 }
 
+func requireCapacity(t *testing.T, iter *cursoriterator.CursorIterator, expected int) {
+	capacity, err := iter.Capacity()
+	require.NoError(t, err)
+	require.Equal(t, expected, capacity)
+}
+
 func TestValueIndex(t *testing.T) {
 	t.Parallel()
 	runTest(
@@ -195,14 +205,1005 @@ func TestInvalidConstructorParameters(t *testing.T) {
 	t.Run("vales must be a slice", func(t *testing.T) {
 		t.Parallel()
 		iter, err := cursoriterator.NewCursorIterator(&pgxpool.Pool{}, User{}, "SELECT * FROM users")
-		require.EqualError(t, err, "values must be a slice")
+		require.EqualError(t, err, "values must be a slice, got struct")
+		require.Nil(t, iter)
+	})
+
+	t.Run("values must not be an array", func(t *testing.T) {
+		t.Parallel()
+		var values [10]User
+		iter, err := cursoriterator.NewCursorIterator(&pgxpool.Pool{}, values, "SELECT * FROM users")
+		require.EqualError(t, err, "values must be a slice, got array (pass values[:] or create the slice with make)")
 		require.Nil(t, iter)
 	})
 
+	t.Run("values must not be a pointer", func(t *testing.T) {
+		t.Parallel()
+		values := make([]User, 10)
+		iter, err := cursoriterator.NewCursorIterator(&pgxpool.Pool{}, &values, "SELECT * FROM users")
+		require.EqualError(t, err, "values must be a slice, got ptr")
+		require.Nil(t, iter)
+	})
+
+	t.Run("slice of an array", func(t *testing.T) {
+		t.Parallel()
+		var values [10]User
+		iter, err := cursoriterator.NewCursorIterator(&pgxpool.Pool{}, values[:], "SELECT * FROM users")
+		require.NoError(t, err)
+		requireCapacity(t, iter, 10)
+	})
+
 	t.Run("values must have a capacity bigger than 0", func(t *testing.T) {
 		t.Parallel()
 		iter, err := cursoriterator.NewCursorIterator(&pgxpool.Pool{}, make([]User, 0), "SELECT * FROM users")
 		require.EqualError(t, err, "values must have a capacity bigger than 0")
 		require.Nil(t, iter)
 	})
+
+	t.Run("values with a length smaller than the capacity", func(t *testing.T) {
+		t.Parallel()
+		iter, err := cursoriterator.NewCursorIterator(&pgxpool.Pool{}, make([]User, 0, 3), "SELECT * FROM users")
+		require.NoError(t, err)
+		requireCapacity(t, iter, 3)
+	})
+}
+
+func TestExplain(t *testing.T) {
+	t.Parallel()
+
+	t.Run("valid query", func(t *testing.T) {
+		t.Parallel()
+		runTest(
+			t,
+			[]User{
+				{1, "Joe"},
+				{2, "Alice"},
+			},
+			func(pool *pgxpool.Pool) {
+				values := make([]User, 3)
+				iter, err := cursoriterator.NewCursorIterator(pool, values, "SELECT * FROM users WHERE id > $1", 0)
+				require.NoError(t, err)
+
+				require.NoError(t, iter.Validate(context.Background()))
+				plan, err := iter.Explain(context.Background())
+				require.NoError(t, err)
+				require.Contains(t, plan, "users")
+
+				// the iterator must not be consumed
+				require.Equal(t, -2, iter.ValueIndex())
+				expectValues(t, iter, values,
+					User{1, "Joe"},
+					User{2, "Alice"},
+				)
+				require.NoError(t, iter.Close(context.Background()))
+			})
+	})
+
+	t.Run("malformed query", func(t *testing.T) {
+		t.Parallel()
+		runTest(
+			t,
+			[]User{},
+			func(pool *pgxpool.Pool) {
+				values := make([]User, 3)
+				iter, err := cursoriterator.NewCursorIterator(pool, values, "SELECT * FORM users")
+				require.NoError(t, err)
+
+				err = iter.Validate(context.Background())
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "unable to explain query")
+				require.Equal(t, -2, iter.ValueIndex())
+				require.NoError(t, iter.Close(context.Background()))
+			})
+	})
+}
+
+func TestFetchTimeout(t *testing.T) {
+	t.Parallel()
+	runTest(
+		t,
+		[]User{
+			{1, "Joe"},
+			{2, "Alice"},
+		},
+		func(pool *pgxpool.Pool) {
+			values := make([]User, 3)
+			iter, err := cursoriterator.NewCursorIterator(pool, values, "SELECT id, name FROM users, pg_sleep($1) ORDER BY id", 5)
+			require.NoError(t, err)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			require.False(t, iter.Next(ctx))
+			require.True(t, errors.Is(iter.Error(), context.DeadlineExceeded))
+			require.False(t, errors.Is(iter.Error(), cursoriterator.ErrCursorLost))
+			require.NotContains(t, iter.Error().Error(), "unable to fetch rows")
+
+			// pgx closed the connection of the timed out fetch, but the iterator can still be used
+			require.NoError(t, iter.Rebind(context.Background(), 0))
+			expectValues(t, iter, values,
+				User{1, "Joe"},
+				User{2, "Alice"},
+			)
+			require.NoError(t, iter.Close(context.Background()))
+		})
+}
+
+func TestLastCommandTag(t *testing.T) {
+	t.Parallel()
+	runTest(
+		t,
+		[]User{
+			{1, "Joe"},
+			{2, "Alice"},
+			{3, "Bob"},
+		},
+		func(pool *pgxpool.Pool) {
+			values := make([]User, 2)
+			iter, err := cursoriterator.NewCursorIterator(pool, values, "SELECT * FROM users")
+			require.NoError(t, err)
+			require.Equal(t, "", iter.LastCommandTag().String())
+
+			require.True(t, iter.Next(context.Background()))
+			require.Equal(t, int64(2), iter.LastCommandTag().RowsAffected())
+			require.True(t, iter.Next(context.Background()))
+			require.Equal(t, int64(2), iter.LastCommandTag().RowsAffected())
+
+			require.True(t, iter.Next(context.Background()))
+			require.Equal(t, "FETCH 1", iter.LastCommandTag().String())
+			require.Equal(t, int64(1), iter.LastCommandTag().RowsAffected())
+			require.NoError(t, iter.Close(context.Background()))
+		})
+}
+
+func TestCTEWithMultipleArgs(t *testing.T) {
+	t.Parallel()
+	runTest(
+		t,
+		[]User{
+			{1, "Joe"},
+			{2, "Alice"},
+			{3, "Bob"},
+			{4, "Mike"},
+			{5, "Maria"},
+			{6, "Tom"},
+		},
+		func(pool *pgxpool.Pool) {
+			values := make([]User, 2)
+			iter, err := cursoriterator.NewCursorIterator(pool, values, `
+WITH lower_bound AS (
+	SELECT * FROM users WHERE id >= $1
+), upper_bound AS (
+	SELECT * FROM lower_bound WHERE id <= $2
+)
+SELECT * FROM upper_bound WHERE name <> $3 ORDER BY id`, 2, 5, "Bob")
+			require.NoError(t, err)
+
+			expectValues(t, iter, values,
+				User{2, "Alice"},
+				User{4, "Mike"},
+				User{5, "Maria"},
+			)
+			require.NoError(t, iter.Close(context.Background()))
+		})
+}
+
+func TestRecursiveCTE(t *testing.T) {
+	t.Parallel()
+	runTest(
+		t,
+		nil,
+		func(pool *pgxpool.Pool) {
+			const rowCount = 5000
+			iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+				pool,
+				128,
+				[]cursoriterator.Option{cursoriterator.WithProfiling()},
+				`
+WITH RECURSIVE series(id) AS (
+	SELECT $1::integer
+	UNION ALL
+	SELECT id + 1 FROM series WHERE id < $2
+)
+SELECT id, 'user' || id AS name FROM series`, 1, rowCount)
+			require.NoError(t, err)
+
+			id := 0
+			for iter.Next(context.Background()) {
+				id++
+				require.Equal(t, User{id, fmt.Sprintf("user%d", id)}, iter.Value())
+			}
+			require.NoError(t, iter.Error())
+			require.Equal(t, rowCount, id)
+			require.NoError(t, iter.Close(context.Background()))
+
+			// the rows are produced while fetching, so no fetch has to compute the whole result
+			profile := iter.Profile()
+			// 39 full fetches, one with the remaining 8 rows and the final fetch without rows
+			require.Equal(t, rowCount/128+2, profile.Fetches)
+			require.Less(t, profile.MaxFetchLatency, 10*profile.AvgFetchLatency+100*time.Millisecond)
+		})
+}
+
+func TestClone(t *testing.T) {
+	t.Parallel()
+	runTest(
+		t,
+		[]User{
+			{1, "Joe"},
+			{2, "Alice"},
+			{3, "Bob"},
+		},
+		func(pool *pgxpool.Pool) {
+			values := make([]User, 2)
+			iter, err := cursoriterator.NewCursorIterator(pool, values, "SELECT * FROM users WHERE id > $1 ORDER BY id", 1)
+			require.NoError(t, err)
+
+			clone1, err := iter.Clone()
+			require.NoError(t, err)
+			clone2, err := iter.Clone()
+			require.NoError(t, err)
+
+			values1 := clone1.Values().([]User)
+			values2 := clone2.Values().([]User)
+			require.Len(t, values1, 2)
+			require.Len(t, values2, 2)
+			require.NotSame(t, &values[0], &values1[0])
+			require.NotSame(t, &values1[0], &values2[0])
+
+			require.True(t, clone1.Next(context.Background()))
+			require.Equal(t, User{2, "Alice"}, values1[clone1.ValueIndex()])
+			expectValues(t, clone2, values2,
+				User{2, "Alice"},
+				User{3, "Bob"},
+			)
+			require.True(t, clone1.Next(context.Background()))
+			require.Equal(t, User{3, "Bob"}, values1[clone1.ValueIndex()])
+			require.False(t, clone1.Next(context.Background()))
+			require.NoError(t, clone1.Error())
+
+			// the original iterator was not touched
+			require.Equal(t, -2, iter.ValueIndex())
+			require.NoError(t, clone1.Close(context.Background()))
+			require.NoError(t, clone2.Close(context.Background()))
+			require.NoError(t, iter.Close(context.Background()))
+		})
+}
+
+func TestBatchLenAndCapacity(t *testing.T) {
+	t.Parallel()
+	runTest(
+		t,
+		[]User{
+			{1, "Joe"},
+			{2, "Alice"},
+			{3, "Bob"},
+			{4, "Mike"},
+			{5, "Maria"},
+		},
+		func(pool *pgxpool.Pool) {
+			values := make([]User, 2)
+			iter, err := cursoriterator.NewCursorIterator(pool, values, "SELECT * FROM users")
+			require.NoError(t, err)
+			requireCapacity(t, iter, 2)
+			require.Equal(t, 0, iter.BatchLen())
+
+			for _, expectedBatchLen := range []int{2, 2, 2, 2, 1} {
+				require.True(t, iter.Next(context.Background()))
+				require.Equal(t, expectedBatchLen, iter.BatchLen())
+				requireCapacity(t, iter, 2)
+			}
+			require.False(t, iter.Next(context.Background()))
+			require.NoError(t, iter.Error())
+			require.Equal(t, 0, iter.BatchLen())
+			requireCapacity(t, iter, 2)
+			require.NoError(t, iter.Close(context.Background()))
+		})
+}
+
+func BenchmarkNewCursorIterator(b *testing.B) {
+	values := make([]User, 1_000_000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := cursoriterator.NewCursorIterator(&pgxpool.Pool{}, values, "SELECT * FROM users")
+		require.NoError(b, err)
+	}
+}
+
+func TestQueryAndArgs(t *testing.T) {
+	t.Parallel()
+	iter, err := cursoriterator.NewCursorIterator(&pgxpool.Pool{}, make([]User, 3), "SELECT * FROM users WHERE id > $1 AND name = $2", 1, "Joe")
+	require.NoError(t, err)
+
+	require.Equal(t, "SELECT * FROM users WHERE id > $1 AND name = $2", iter.Query())
+	args := iter.Args()
+	require.Equal(t, []interface{}{1, "Joe"}, args)
+
+	// modifying the returned args must not affect the iterator
+	args[0] = 2
+	require.Equal(t, []interface{}{1, "Joe"}, iter.Args())
+}
+
+func TestUniqueCursorNames(t *testing.T) {
+	t.Parallel()
+	users := []User{
+		{1, "Joe"},
+		{2, "Alice"},
+		{3, "Bob"},
+	}
+	runTest(
+		t,
+		users,
+		func(pool *pgxpool.Pool) {
+			// use one transaction as connector, so both iterators declare their cursors in the same session
+			tx, err := pool.Begin(context.Background())
+			require.NoError(t, err)
+			defer func() {
+				_ = tx.Rollback(context.Background())
+			}()
+
+			// a batch size of 1 fetches from both cursors alternately
+			ascValues := make([]User, 1)
+			asc, err := cursoriterator.NewCursorIterator(tx, ascValues, "SELECT * FROM users ORDER BY id")
+			require.NoError(t, err)
+			// an iterator rolls back its savepoint when it ends, which would close the cursor of the second iterator,
+			// so the second iterator returns fewer rows and ends first
+			descValues := make([]User, 1)
+			desc, err := cursoriterator.NewCursorIterator(tx, descValues, "SELECT * FROM users WHERE id > 1 ORDER BY id DESC")
+			require.NoError(t, err)
+
+			var ascResult, descResult []User
+			for {
+				ascNext := asc.Next(context.Background())
+				require.NoError(t, asc.Error())
+				if ascNext {
+					ascResult = append(ascResult, ascValues[asc.ValueIndex()])
+				}
+				descNext := desc.Next(context.Background())
+				require.NoError(t, desc.Error())
+				if descNext {
+					descResult = append(descResult, descValues[desc.ValueIndex()])
+				}
+				if !ascNext && !descNext {
+					break
+				}
+			}
+			require.NotEqual(t, asc.CursorName(), desc.CursorName())
+			require.Equal(t, users, ascResult)
+			require.Equal(t, []User{users[2], users[1]}, descResult)
+			require.NoError(t, desc.Close(context.Background()))
+			require.NoError(t, asc.Close(context.Background()))
+		})
+}
+
+func TestEmptyFetchEndsIteration(t *testing.T) {
+	t.Parallel()
+
+	users := []User{
+		{1, "Joe"},
+		{2, "Alice"},
+		{3, "Bob"},
+		{4, "Mike"},
+	}
+
+	t.Run("row count is a multiple of the batch size", func(t *testing.T) {
+		t.Parallel()
+		runTest(t, users, func(pool *pgxpool.Pool) {
+			values := make([]User, 2)
+			iter, err := cursoriterator.NewCursorIterator(pool, values, "SELECT * FROM users ORDER BY id")
+			require.NoError(t, err)
+
+			expectValues(t, iter, values, users...)
+			require.Equal(t, "FETCH 0", iter.LastCommandTag().String())
+			require.NoError(t, iter.Close(context.Background()))
+		})
+	})
+
+	for _, size := range []int{2, 3} {
+		size := size
+		t.Run(fmt.Sprintf("scroll cursor skipping the last row with batch size %d", size), func(t *testing.T) {
+			t.Parallel()
+			runTest(t, users, func(pool *pgxpool.Pool) {
+				values := make([]User, size)
+				iter, err := cursoriterator.NewCursorIteratorWithOptions(
+					pool,
+					values,
+					[]cursoriterator.Option{cursoriterator.WithSkipScanErrors(func(int64, error) {})},
+					"SELECT id, CASE WHEN id = 4 THEN NULL ELSE name END AS name FROM users ORDER BY id",
+				)
+				require.NoError(t, err)
+
+				expectValues(t, iter, values, users[:3]...)
+				require.Equal(t, int64(1), iter.SkippedCount())
+				require.NoError(t, iter.Close(context.Background()))
+			})
+		})
+	}
+}
+
+func TestNextErr(t *testing.T) {
+	t.Parallel()
+	runTest(
+		t,
+		[]User{
+			{1, "Joe"},
+			{2, "Alice"},
+			{3, "Bob"},
+		},
+		func(pool *pgxpool.Pool) {
+			errInjected := errors.New("injected error")
+			batches := 0
+			values := make([]User, 2)
+			iter, err := cursoriterator.NewCursorIteratorWithOptions(
+				pool,
+				values,
+				[]cursoriterator.Option{cursoriterator.WithAfterFetch(func([]User) error {
+					batches++
+					if batches == 2 {
+						return errInjected
+					}
+					return nil
+				})},
+				"SELECT * FROM users ORDER BY id",
+			)
+			require.NoError(t, err)
+
+			var users []User
+			for {
+				ok, err := iter.NextErr(context.Background())
+				if err != nil {
+					require.False(t, ok)
+					require.True(t, errors.Is(err, errInjected))
+					break
+				}
+				require.True(t, ok, "iteration must end with the injected error")
+				users = append(users, values[iter.ValueIndex()])
+			}
+			require.Equal(t, []User{{1, "Joe"}, {2, "Alice"}}, users)
+		})
+}
+
+func TestPointerSlice(t *testing.T) {
+	t.Parallel()
+	runTest(
+		t,
+		[]User{
+			{1, "Joe"},
+			{2, "Alice"},
+			{3, "Bob"},
+			{4, "Mike"},
+			{5, "Maria"},
+		},
+		func(pool *pgxpool.Pool) {
+			existing := &User{}
+			values := make([]*User, 3)
+			values[1] = existing
+			iter, err := cursoriterator.NewCursorIterator(pool, values, "SELECT * FROM users ORDER BY id")
+			require.NoError(t, err)
+
+			// nil elements are allocated, existing pointers are reused
+			require.NotNil(t, values[0])
+			require.Same(t, existing, values[1])
+			require.NotNil(t, values[2])
+			pointers := append([]*User{}, values...)
+
+			var users []User
+			for iter.Next(context.Background()) {
+				require.Same(t, pointers[iter.ValueIndex()], values[iter.ValueIndex()])
+				users = append(users, *values[iter.ValueIndex()])
+			}
+			require.NoError(t, iter.Error())
+			require.Equal(t, []User{{1, "Joe"}, {2, "Alice"}, {3, "Bob"}, {4, "Mike"}, {5, "Maria"}}, users)
+			require.NoError(t, iter.Close(context.Background()))
+		})
+}
+
+func TestBuildFetchQuery(t *testing.T) {
+	t.Parallel()
+	iter, err := cursoriterator.NewCursorIteratorWithOptions(
+		&pgxpool.Pool{},
+		make([]User, 2),
+		[]cursoriterator.Option{cursoriterator.WithCursorName("users_cursor")},
+		"SELECT * FROM users",
+	)
+	require.NoError(t, err)
+
+	require.Equal(t, `FETCH FORWARD 1 IN "users_cursor"`, iter.BuildFetchQuery(cursoriterator.FetchForward, 1))
+	require.Equal(t, `FETCH FORWARD 100 IN "users_cursor"`, iter.BuildFetchQuery(cursoriterator.FetchForward, 100))
+	require.Equal(t, `FETCH BACKWARD 1 IN "users_cursor"`, iter.BuildFetchQuery(cursoriterator.FetchBackward, 1))
+	require.Equal(t, `FETCH BACKWARD 100 IN "users_cursor"`, iter.BuildFetchQuery(cursoriterator.FetchBackward, 100))
+}
+
+func TestHasMore(t *testing.T) {
+	t.Parallel()
+
+	newConnector := func(n int) *cursoriteratortest.Connector {
+		rows := make([][]interface{}, n)
+		for i := range rows {
+			rows[i] = []interface{}{i + 1, fmt.Sprint("user", i+1)}
+		}
+		return cursoriteratortest.NewConnector([]string{"id", "name"}, rows...)
+	}
+
+	t.Run("short batch", func(t *testing.T) {
+		t.Parallel()
+		iter, err := cursoriterator.NewTypedCursorIterator[User](newConnector(5), 2, "SELECT * FROM users")
+		require.NoError(t, err)
+		require.True(t, iter.HasMore())
+
+		var hasMore []bool
+		for iter.Next(context.Background()) {
+			hasMore = append(hasMore, iter.HasMore())
+		}
+		require.NoError(t, iter.Error())
+		require.Equal(t, []bool{true, true, true, true, false}, hasMore)
+		require.False(t, iter.HasMore())
+	})
+
+	t.Run("empty batch", func(t *testing.T) {
+		t.Parallel()
+		iter, err := cursoriterator.NewTypedCursorIterator[User](newConnector(4), 2, "SELECT * FROM users")
+		require.NoError(t, err)
+
+		var hasMore []bool
+		for iter.Next(context.Background()) {
+			hasMore = append(hasMore, iter.HasMore())
+		}
+		require.NoError(t, iter.Error())
+		require.Equal(t, []bool{true, true, true, true}, hasMore)
+		require.False(t, iter.HasMore())
+	})
+}
+
+func TestSwapContext(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		// done returns a context that is already done
+		done func() (context.Context, context.CancelFunc)
+		err  error
+	}{
+		{
+			"canceled",
+			func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx, cancel
+			},
+			context.Canceled,
+		},
+		{
+			"deadline exceeded",
+			func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+				<-ctx.Done()
+				return ctx, cancel
+			},
+			context.DeadlineExceeded,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			runTest(
+				t,
+				[]User{
+					{1, "Joe"},
+					{2, "Alice"},
+					{3, "Bob"},
+					{4, "Mike"},
+				},
+				func(pool *pgxpool.Pool) {
+					iter, err := cursoriterator.NewTypedCursorIterator[User](pool, 2, "SELECT * FROM users ORDER BY id")
+					require.NoError(t, err)
+
+					require.True(t, iter.Next(context.Background()))
+					require.Equal(t, User{1, "Joe"}, iter.Value())
+					require.True(t, iter.Next(context.Background()))
+					require.Equal(t, User{2, "Alice"}, iter.Value())
+
+					// the next fetch fails with the done context
+					requestCtx, cancel := test.done()
+					defer cancel()
+					require.False(t, iter.Next(requestCtx))
+					require.ErrorIs(t, iter.Error(), test.err)
+					require.False(t, errors.Is(iter.Error(), cursoriterator.ErrCursorLost))
+
+					// and can be continued with a fresh context
+					expectTypedValues(t, iter,
+						User{3, "Bob"},
+						User{4, "Mike"},
+					)
+					require.NoError(t, iter.Close(context.Background()))
+				})
+		})
+	}
+}
+
+func TestSwapContextWithoutDatabase(t *testing.T) {
+	t.Parallel()
+	connector, users := newUsersConnector(4)
+	iter, err := cursoriterator.NewTypedCursorIterator[User](connector, 2, "SELECT * FROM users")
+	require.NoError(t, err)
+	require.True(t, iter.Next(context.Background()))
+	require.True(t, iter.Next(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	require.False(t, iter.Next(ctx))
+	require.ErrorIs(t, iter.Error(), context.DeadlineExceeded)
+
+	expectTypedValues(t, iter, users[2:]...)
+	require.NoError(t, iter.Close(context.Background()))
+}
+
+func TestSetReturningFunction(t *testing.T) {
+	t.Parallel()
+	runTest(
+		t,
+		[]User{
+			{1, "Joe"},
+			{2, "Alice"},
+			{3, "Bob"},
+			{4, "Mike"},
+			{5, "Maria"},
+		},
+		func(pool *pgxpool.Pool) {
+			_, err := pool.Exec(context.Background(), `
+CREATE FUNCTION users_between(min_id integer, max_id integer) RETURNS SETOF users AS $$
+BEGIN
+	RETURN QUERY SELECT * FROM users WHERE id BETWEEN min_id AND max_id ORDER BY id;
+END
+$$ LANGUAGE plpgsql;`)
+			require.NoError(t, err)
+
+			values := make([]User, 2)
+			iter, err := cursoriterator.NewCursorIterator(pool, values, "SELECT * FROM users_between($1, $2)", 2, 4)
+			require.NoError(t, err)
+
+			expectValues(t, iter, values,
+				User{2, "Alice"},
+				User{3, "Bob"},
+				User{4, "Mike"},
+			)
+			require.NoError(t, iter.Close(context.Background()))
+		})
+}
+
+func TestCloseJoinsErrors(t *testing.T) {
+	t.Parallel()
+
+	newConnector := func() *cursoriteratortest.Connector {
+		return cursoriteratortest.NewConnector(
+			[]string{"id", "name"},
+			[]interface{}{1, "Joe"},
+			[]interface{}{2, "Alice"},
+			[]interface{}{3, "Bob"},
+		)
+	}
+	errFetch := errors.New("fetch failed")
+	errRollback := errors.New("rollback failed")
+
+	t.Run("iteration and rollback error", func(t *testing.T) {
+		t.Parallel()
+		connector := newConnector()
+		connector.FetchErr = func(fetch int) error {
+			if fetch == 2 {
+				return errFetch
+			}
+			return nil
+		}
+		connector.RollbackErr = errRollback
+		iter, err := cursoriterator.NewTypedCursorIterator[User](connector, 2, "SELECT * FROM users")
+		require.NoError(t, err)
+
+		require.True(t, iter.Next(context.Background()))
+		require.True(t, iter.Next(context.Background()))
+		require.False(t, iter.Next(context.Background()))
+
+		err = iter.Close(context.Background())
+		require.ErrorIs(t, err, errFetch)
+		require.ErrorIs(t, err, errRollback)
+		require.Equal(t, errFetch, iter.Error())
+	})
+
+	t.Run("iteration error", func(t *testing.T) {
+		t.Parallel()
+		connector := newConnector()
+		connector.FetchErr = func(int) error {
+			return errFetch
+		}
+		iter, err := cursoriterator.NewTypedCursorIterator[User](connector, 2, "SELECT * FROM users")
+		require.NoError(t, err)
+
+		require.False(t, iter.Next(context.Background()))
+		require.Equal(t, errFetch, iter.Close(context.Background()))
+		require.Equal(t, errFetch, iter.Error())
+	})
+
+	t.Run("rollback error", func(t *testing.T) {
+		t.Parallel()
+		connector := newConnector()
+		connector.RollbackErr = errRollback
+		iter, err := cursoriterator.NewTypedCursorIterator[User](connector, 2, "SELECT * FROM users")
+		require.NoError(t, err)
+
+		require.True(t, iter.Next(context.Background()))
+		require.Equal(t, errRollback, iter.Close(context.Background()))
+		require.Equal(t, errRollback, iter.Error())
+	})
+
+	t.Run("begin error", func(t *testing.T) {
+		t.Parallel()
+		errBegin := errors.New("begin failed")
+		connector := newConnector()
+		connector.BeginErr = errBegin
+		iter, err := cursoriterator.NewTypedCursorIterator[User](connector, 2, "SELECT * FROM users")
+		require.NoError(t, err)
+
+		require.False(t, iter.Next(context.Background()))
+		require.ErrorIs(t, iter.Error(), errBegin)
+		beginErr := iter.Error()
+
+		// there is no transaction to roll back, the begin error must be kept
+		require.Equal(t, beginErr, iter.Close(context.Background()))
+		require.Equal(t, beginErr, iter.Error())
+		require.Equal(t, beginErr, iter.Close(context.Background()))
+		require.Equal(t, beginErr, iter.Error())
+	})
+}
+
+func TestRebind(t *testing.T) {
+	t.Parallel()
+
+	t.Run("closed iterator", func(t *testing.T) {
+		t.Parallel()
+		iter, err := cursoriterator.NewTypedCursorIterator[User](
+			cursoriteratortest.NewConnector([]string{"id", "name"}),
+			2,
+			"SELECT * FROM users",
+		)
+		require.NoError(t, err)
+		require.NoError(t, iter.Close(context.Background()))
+		require.EqualError(t, iter.Rebind(context.Background(), 1), "iterator is closed")
+	})
+
+	t.Run("rebind with new args", func(t *testing.T) {
+		t.Parallel()
+		runTest(
+			t,
+			[]User{
+				{1, "Joe"},
+				{2, "Alice"},
+				{3, "Bob"},
+				{4, "Mike"},
+				{5, "Maria"},
+			},
+			func(pool *pgxpool.Pool) {
+				iter, err := cursoriterator.NewTypedCursorIterator[User](
+					pool,
+					2,
+					"SELECT * FROM users WHERE id BETWEEN $1 AND $2 ORDER BY id",
+					1, 3,
+				)
+				require.NoError(t, err)
+
+				expectTypedValues(t, iter,
+					User{1, "Joe"},
+					User{2, "Alice"},
+					User{3, "Bob"},
+				)
+
+				require.NoError(t, iter.Rebind(context.Background(), 4, 5))
+				require.Equal(t, []interface{}{4, 5}, iter.Args())
+				expectTypedValues(t, iter,
+					User{4, "Mike"},
+					User{5, "Maria"},
+				)
+
+				// rebind in the middle of an iteration
+				require.NoError(t, iter.Rebind(context.Background(), 2, 4))
+				require.True(t, iter.Next(context.Background()))
+				require.Equal(t, User{2, "Alice"}, iter.Value())
+				require.NoError(t, iter.Rebind(context.Background(), 5, 5))
+				expectTypedValues(t, iter,
+					User{5, "Maria"},
+				)
+				require.NoError(t, iter.Close(context.Background()))
+			})
+	})
+}
+
+func TestReopenFrom(t *testing.T) {
+	t.Parallel()
+
+	t.Run("declares the resumed query", func(t *testing.T) {
+		t.Parallel()
+		connector, _ := newUsersConnector(5)
+		iter, err := cursoriterator.NewTypedCursorIterator[User](connector, 2, "SELECT * FROM users")
+		require.NoError(t, err)
+		require.True(t, iter.Next(context.Background()))
+		require.NoError(t, iter.Close(context.Background()))
+
+		require.EqualError(t, iter.ReopenFrom(context.Background(), "", 1), "key column cannot be empty")
+		require.NoError(t, iter.ReopenFrom(context.Background(), "id", 1))
+		require.True(t, iter.Next(context.Background()))
+		require.NoError(t, iter.Close(context.Background()))
+
+		statements := connector.Statements()
+		require.Equal(t,
+			`DECLARE "`+iter.CursorName()+`" CURSOR FOR SELECT * FROM (SELECT * FROM users) AS resume WHERE "id" > $1 ORDER BY "id"`,
+			statements[len(statements)-3],
+		)
+	})
+
+	t.Run("commit per batch", func(t *testing.T) {
+		t.Parallel()
+		connector, _ := newUsersConnector(5)
+		iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+			connector,
+			2,
+			[]cursoriterator.Option{cursoriterator.WithCommitPerBatch("id")},
+			"SELECT * FROM users",
+		)
+		require.NoError(t, err)
+		require.True(t, iter.Next(context.Background()))
+		require.NoError(t, iter.Close(context.Background()))
+
+		require.EqualError(t, iter.ReopenFrom(context.Background(), "name", "user1"),
+			"commit per batch requires the resume column to be the key column")
+		require.NoError(t, iter.ReopenFrom(context.Background(), "id", 1))
+		require.True(t, iter.Next(context.Background()))
+		require.NoError(t, iter.Close(context.Background()))
+
+		statements := connector.Statements()
+		require.Equal(t,
+			`DECLARE "`+iter.CursorName()+`" CURSOR FOR SELECT * FROM (SELECT * FROM users) AS resume WHERE "id" > $1 ORDER BY "id"`,
+			statements[len(statements)-3],
+		)
+	})
+
+	t.Run("buffer pool", func(t *testing.T) {
+		t.Parallel()
+		connector, _ := newUsersConnector(5)
+		iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+			connector,
+			2,
+			[]cursoriterator.Option{cursoriterator.WithBufferPool[User](&sync.Pool{})},
+			"SELECT * FROM users",
+		)
+		require.NoError(t, err)
+		require.NoError(t, iter.Close(context.Background()))
+		require.EqualError(t, iter.ReopenFrom(context.Background(), "id", 1),
+			"buffer was returned to the buffer pool, the iterator cannot be reopened")
+	})
+
+	t.Run("database", func(t *testing.T) {
+		t.Parallel()
+		users := []User{{1, "Joe"}, {2, "Alice"}, {3, "Bob"}, {4, "Mike"}, {5, "Maria"}, {6, "Tom"}, {7, "Anna"}}
+		runTest(t, users, func(pool *pgxpool.Pool) {
+			iter, err := cursoriterator.NewTypedCursorIterator[User](pool, 3, "SELECT * FROM users WHERE id > $1 ORDER BY id", 0)
+			require.NoError(t, err)
+
+			// close in the middle of a batch
+			var result []User
+			for len(result) < 4 && iter.Next(context.Background()) {
+				result = append(result, iter.Value())
+			}
+			require.NoError(t, iter.Close(context.Background()))
+
+			require.NoError(t, iter.ReopenFrom(context.Background(), "id", result[len(result)-1].ID))
+			for iter.Next(context.Background()) {
+				result = append(result, iter.Value())
+			}
+			require.NoError(t, iter.Error())
+			require.NoError(t, iter.Close(context.Background()))
+			require.Equal(t, users, result)
+		})
+	})
+}
+
+func TestConnectorFunc(t *testing.T) {
+	t.Parallel()
+
+	t.Run("fake", func(t *testing.T) {
+		t.Parallel()
+		connector, users := newUsersConnector(3)
+		calls := 0
+		values := make([]User, 2)
+		iter, err := cursoriterator.NewCursorIterator(
+			cursoriterator.ConnectorFunc(func(ctx context.Context) (pgx.Tx, error) {
+				calls++
+				return connector.Begin(ctx)
+			}),
+			values,
+			"SELECT * FROM users",
+		)
+		require.NoError(t, err)
+		expectValues(t, iter, values, users...)
+		require.NoError(t, iter.Close(context.Background()))
+		require.Equal(t, 1, calls)
+	})
+
+	t.Run("database", func(t *testing.T) {
+		t.Parallel()
+		runTest(t, nil, func(pool *pgxpool.Pool) {
+			type setting struct {
+				Isolation string `db:"isolation"`
+				ReadOnly  string `db:"read_only"`
+			}
+			values := make([]setting, 1)
+			iter, err := cursoriterator.NewCursorIterator(
+				cursoriterator.ConnectorFunc(func(ctx context.Context) (pgx.Tx, error) {
+					return pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+				}),
+				values,
+				"SELECT current_setting('transaction_isolation') AS isolation, current_setting('transaction_read_only') AS read_only",
+			)
+			require.NoError(t, err)
+			expectValues(t, iter, values, setting{Isolation: "repeatable read", ReadOnly: "on"})
+			require.NoError(t, iter.Close(context.Background()))
+		})
+	})
+}
+
+func TestDone(t *testing.T) {
+	t.Parallel()
+
+	newIterator := func(t *testing.T) *cursoriterator.TypedCursorIterator[User] {
+		iter, err := cursoriterator.NewTypedCursorIterator[User](
+			cursoriteratortest.NewConnector(
+				[]string{"id", "name"},
+				[]interface{}{1, "Joe"},
+				[]interface{}{2, "Alice"},
+				[]interface{}{3, "Bob"},
+			),
+			2,
+			"SELECT * FROM users",
+		)
+		require.NoError(t, err)
+		return iter
+	}
+	isDone := func(iter *cursoriterator.TypedCursorIterator[User]) bool {
+		select {
+		case <-iter.Done():
+			return true
+		case <-time.After(10 * time.Millisecond):
+			return false
+		}
+	}
+
+	t.Run("finished", func(t *testing.T) {
+		t.Parallel()
+		iter := newIterator(t)
+		for iter.Next(context.Background()) {
+			require.False(t, isDone(iter))
+		}
+		require.NoError(t, iter.Error())
+		require.True(t, isDone(iter))
+
+		// closing a finished iterator must not close the channel again
+		require.NoError(t, iter.Close(context.Background()))
+		require.True(t, isDone(iter))
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		t.Parallel()
+		iter := newIterator(t)
+		require.True(t, iter.Next(context.Background()))
+		require.False(t, isDone(iter))
+		require.NoError(t, iter.Close(context.Background()))
+		require.True(t, isDone(iter))
+	})
+
+	t.Run("rebind", func(t *testing.T) {
+		t.Parallel()
+		iter := newIterator(t)
+		for iter.Next(context.Background()) {
+		}
+		require.True(t, isDone(iter))
+		require.NoError(t, iter.Rebind(context.Background()))
+		require.False(t, isDone(iter))
+	})
 }
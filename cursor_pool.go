@@ -0,0 +1,166 @@
+package cursoriterator
+
+import (
+	"context"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+)
+
+// cursorPoolSavepoint is the savepoint every pooled transaction is rolled back to before it is reused.
+const cursorPoolSavepoint = "cursor_pool"
+
+// CursorPool reuses transactions for iterators, which saves starting and rolling back a transaction (and
+// acquiring a connection) for every iterator in services with a high iterator churn.
+// The pool is a PgxConnector: when an iterator closes its transaction, the transaction is rolled back to a savepoint
+// that was created when the transaction was started, which closes the cursor and undoes everything the iterator did,
+// and the transaction is kept for the next iterator. Transactions that can not be rolled back to the savepoint
+// are rolled back and discarded.
+//
+// The pooled transactions stay open while they are idle, so idle_in_transaction_session_timeout must not be
+// exceeded and every iterator sees the data as of its own DECLARE only with the READ COMMITTED isolation level.
+// Iterators of the pool can not use WithHold(), WithCommitPerBatch(), WithSnapshot() and options that configure the
+// transaction (like WithDeferrable()), because they require a transaction of their own.
+//
+// Example Usage:
+//
+//	pool, err := NewCursorPool(pgxPool, 10)
+//	if err != nil {
+//		panic(err)
+//	}
+//	defer pool.Close(ctx)
+//	values := make([]User, 1000)
+//	iter, err := pool.NewCursorIterator(values, nil, "SELECT * FROM users WHERE role = $1", "Guest")
+type CursorPool struct {
+	connector PgxConnector
+	maxIdle   int
+
+	mu     sync.Mutex
+	idle   []pgx.Tx
+	closed bool
+}
+
+// NewCursorPool creates a pool that keeps up to maxIdle idle transactions of connector.
+func NewCursorPool(connector PgxConnector, maxIdle int) (*CursorPool, error) {
+	if connector == nil {
+		return nil, errors.New("connector cannot be nil")
+	}
+	if maxIdle <= 0 {
+		return nil, errors.New("max idle must be bigger than 0")
+	}
+	return &CursorPool{connector: connector, maxIdle: maxIdle}, nil
+}
+
+// NewCursorIterator works like NewCursorIteratorWithOptions() but uses the transactions of the pool.
+func (p *CursorPool) NewCursorIterator(
+	values interface{},
+	options []Option,
+	query string, args ...interface{},
+) (*CursorIterator, error) {
+	iter, err := NewCursorIteratorWithOptions(p, values, options, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case iter.hold:
+		return nil, errors.New("cursor pool cannot be used with hold")
+	case iter.commit.column != "":
+		return nil, errors.New("cursor pool cannot be used with commit per batch")
+	case iter.snapshotID != "":
+		return nil, errors.New("cursor pool cannot be used with snapshot")
+	case iter.txOptions != nil:
+		return nil, errors.New("cursor pool cannot be used with transaction options")
+	}
+	return iter, nil
+}
+
+// Begin returns an idle transaction of the pool or starts a new one.
+func (p *CursorPool) Begin(ctx context.Context) (pgx.Tx, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, errors.New("cursor pool is closed")
+	}
+	if n := len(p.idle); n > 0 {
+		tx := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mu.Unlock()
+		return &pooledTx{Tx: tx, pool: p}, nil
+	}
+	p.mu.Unlock()
+
+	tx, err := p.connector.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, "SAVEPOINT "+cursorPoolSavepoint); err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, errors.Wrap(err, "unable to create savepoint")
+	}
+	return &pooledTx{Tx: tx, pool: p}, nil
+}
+
+// Idle returns the amount of idle transactions in the pool.
+func (p *CursorPool) Idle() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.idle)
+}
+
+// Close rolls back the idle transactions, transactions that are still in use are rolled back when their iterator
+// is closed.
+func (p *CursorPool) Close(ctx context.Context) error {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.closed = true
+	p.mu.Unlock()
+
+	var err error
+	for _, tx := range idle {
+		if rollbackErr := tx.Rollback(ctx); rollbackErr != nil && err == nil {
+			err = rollbackErr
+		}
+	}
+	return err
+}
+
+// release resets tx and keeps it for the next iterator if the pool has room for it.
+func (p *CursorPool) release(ctx context.Context, tx pgx.Tx) error {
+	if _, err := tx.Exec(ctx, "ROLLBACK TO SAVEPOINT "+cursorPoolSavepoint); err != nil {
+		// e.g. the connection was lost, discard the transaction
+		return tx.Rollback(ctx)
+	}
+	p.mu.Lock()
+	if !p.closed && len(p.idle) < p.maxIdle {
+		p.idle = append(p.idle, tx)
+		p.mu.Unlock()
+		return nil
+	}
+	p.mu.Unlock()
+	return tx.Rollback(ctx)
+}
+
+// pooledTx is the transaction returned by CursorPool.Begin(), its Rollback() returns it to the pool.
+type pooledTx struct {
+	pgx.Tx
+	pool     *CursorPool
+	released bool
+}
+
+func (tx *pooledTx) Rollback(ctx context.Context) error {
+	if tx.released {
+		return pgx.ErrTxClosed
+	}
+	tx.released = true
+	return tx.pool.release(ctx, tx.Tx)
+}
+
+func (tx *pooledTx) Commit(ctx context.Context) error {
+	if tx.released {
+		return pgx.ErrTxClosed
+	}
+	tx.released = true
+	return tx.Tx.Commit(ctx)
+}
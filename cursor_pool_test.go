@@ -0,0 +1,172 @@
+package cursoriterator_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+
+	cursoriterator "github.com/Eun/go-pgx-cursor-iterator/v2"
+	"github.com/Eun/go-pgx-cursor-iterator/v2/cursoriteratortest"
+)
+
+// countStatements counts how often the statement was executed on the connector.
+func countStatements(connector *cursoriteratortest.Connector, statement string) int {
+	n := 0
+	for _, s := range connector.Statements() {
+		if s == statement {
+			n++
+		}
+	}
+	return n
+}
+
+func TestCursorPool(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reuses transactions", func(t *testing.T) {
+		t.Parallel()
+		connector, users := newUsersConnector(5)
+		pool, err := cursoriterator.NewCursorPool(connector, 2)
+		require.NoError(t, err)
+
+		for i := 0; i < 5; i++ {
+			values := make([]User, 2)
+			iter, err := pool.NewCursorIterator(values, nil, "SELECT * FROM users")
+			require.NoError(t, err)
+			expectValues(t, iter, values, users...)
+			require.NoError(t, iter.Close(context.Background()))
+			require.Equal(t, 1, pool.Idle())
+		}
+		require.Equal(t, 1, countStatements(connector, "BEGIN"))
+		require.Equal(t, 1, countStatements(connector, "SAVEPOINT cursor_pool"))
+		require.Equal(t, 5, countStatements(connector, "ROLLBACK TO SAVEPOINT cursor_pool"))
+		require.Equal(t, 0, countStatements(connector, "ROLLBACK"))
+
+		require.NoError(t, pool.Close(context.Background()))
+		require.Equal(t, 0, pool.Idle())
+		require.Equal(t, 1, countStatements(connector, "ROLLBACK"))
+
+		_, err = pool.Begin(context.Background())
+		require.EqualError(t, err, "cursor pool is closed")
+	})
+
+	t.Run("concurrent iterators", func(t *testing.T) {
+		t.Parallel()
+		connector, users := newUsersConnector(5)
+		pool, err := cursoriterator.NewCursorPool(connector, 1)
+		require.NoError(t, err)
+
+		var iterators []*cursoriterator.CursorIterator
+		var valuesList [][]User
+		for i := 0; i < 3; i++ {
+			values := make([]User, 2)
+			iter, err := pool.NewCursorIterator(values, nil, "SELECT * FROM users")
+			require.NoError(t, err)
+			require.True(t, iter.Next(context.Background()))
+			iterators = append(iterators, iter)
+			valuesList = append(valuesList, values)
+		}
+		require.Equal(t, 3, countStatements(connector, "BEGIN"))
+		for i, iter := range iterators {
+			require.Equal(t, users[0], valuesList[i][iter.ValueIndex()])
+			require.NoError(t, iter.Close(context.Background()))
+		}
+		// only one transaction is kept, the others are rolled back
+		require.Equal(t, 1, pool.Idle())
+		require.Equal(t, 2, countStatements(connector, "ROLLBACK"))
+		require.NoError(t, pool.Close(context.Background()))
+	})
+
+	t.Run("recovers failed transactions", func(t *testing.T) {
+		t.Parallel()
+		connector, users := newUsersConnector(3)
+		connector.FetchErr = func(fetch int) error {
+			if fetch == 1 {
+				return &pgconn.PgError{Code: "22012", Message: "division by zero"}
+			}
+			return nil
+		}
+		pool, err := cursoriterator.NewCursorPool(connector, 1)
+		require.NoError(t, err)
+
+		values := make([]User, 2)
+		iter, err := pool.NewCursorIterator(values, nil, "SELECT * FROM users")
+		require.NoError(t, err)
+		require.False(t, iter.Next(context.Background()))
+		var pgErr *pgconn.PgError
+		require.True(t, errors.As(iter.Error(), &pgErr))
+		require.Equal(t, 1, pool.Idle())
+
+		iter, err = pool.NewCursorIterator(values, nil, "SELECT * FROM users")
+		require.NoError(t, err)
+		expectValues(t, iter, values, users...)
+		require.NoError(t, iter.Close(context.Background()))
+		require.Equal(t, 1, countStatements(connector, "BEGIN"))
+		require.NoError(t, pool.Close(context.Background()))
+	})
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		t.Parallel()
+		_, err := cursoriterator.NewCursorPool(nil, 1)
+		require.EqualError(t, err, "connector cannot be nil")
+		connector, _ := newUsersConnector(1)
+		_, err = cursoriterator.NewCursorPool(connector, 0)
+		require.EqualError(t, err, "max idle must be bigger than 0")
+
+		pool, err := cursoriterator.NewCursorPool(connector, 1)
+		require.NoError(t, err)
+		tests := []struct {
+			option cursoriterator.Option
+			err    string
+		}{
+			{cursoriterator.WithHold(), "cursor pool cannot be used with hold"},
+			{cursoriterator.WithCommitPerBatch("id"), "cursor pool cannot be used with commit per batch"},
+			{cursoriterator.WithSnapshot("00000003-0000001B-1"), "cursor pool cannot be used with snapshot"},
+			{cursoriterator.WithDeferrable(), "cursor pool cannot be used with transaction options"},
+		}
+		for _, test := range tests {
+			_, err := pool.NewCursorIterator(make([]User, 2), []cursoriterator.Option{test.option}, "SELECT * FROM users")
+			require.EqualError(t, err, test.err)
+		}
+	})
+
+	t.Run("database", func(t *testing.T) {
+		t.Parallel()
+		runTest(t, []User{{1, "Joe"}, {2, "Alice"}, {3, "Bob"}}, func(pgxPool *pgxpool.Pool) {
+			pool, err := cursoriterator.NewCursorPool(pgxPool, 2)
+			require.NoError(t, err)
+			defer pool.Close(context.Background())
+
+			for i := 0; i < 3; i++ {
+				values := make([]User, 2)
+				iter, err := pool.NewCursorIterator(
+					values,
+					[]cursoriterator.Option{cursoriterator.WithStatementTimeout(time.Minute)},
+					"SELECT * FROM users WHERE id > $1", 1,
+				)
+				require.NoError(t, err)
+				expectValues(t, iter, values, User{2, "Alice"}, User{3, "Bob"})
+				require.NoError(t, iter.Close(context.Background()))
+			}
+
+			// a failed iterator does not break the pooled transaction
+			iter, err := pool.NewCursorIterator(make([]User, 2), nil, "SELECT 1 / 0")
+			require.NoError(t, err)
+			require.False(t, iter.Next(context.Background()))
+			require.Error(t, iter.Error())
+			_ = iter.Close(context.Background())
+
+			values := make([]User, 2)
+			iter, err = pool.NewCursorIterator(values, nil, "SELECT * FROM users")
+			require.NoError(t, err)
+			expectValues(t, iter, values, User{1, "Joe"}, User{2, "Alice"}, User{3, "Bob"})
+			require.NoError(t, iter.Close(context.Background()))
+			require.Equal(t, 1, pool.Idle())
+		})
+	})
+}
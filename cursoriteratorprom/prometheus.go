@@ -0,0 +1,146 @@
+// Package cursoriteratorprom reports the fetches of cursoriterator iterators as Prometheus metrics.
+// It is a separate package, so the cursoriterator package does not depend on the Prometheus client.
+//
+// Example Usage:
+//
+//	iter, err := cursoriterator.NewCursorIteratorWithOptions(
+//		pool,
+//		values,
+//		[]cursoriterator.Option{cursoriteratorprom.WithPrometheus(prometheus.DefaultRegisterer, "app", "export_users")},
+//		"SELECT * FROM users",
+//	)
+package cursoriteratorprom
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	cursoriterator "github.com/Eun/go-pgx-cursor-iterator/v2"
+)
+
+// WithPrometheus registers the metrics of the iterator with reg and updates them after every fetch:
+//
+//	<namespace>_cursor_batches_total          counter of the fetched batches
+//	<namespace>_cursor_rows_total             counter of the fetched rows
+//	<namespace>_cursor_fetch_duration_seconds histogram of the fetch durations
+//	<namespace>_cursor_errors_total           counter of the failed fetches
+//
+// Every metric has the label job, which distinguishes the iterators that share the metrics. The cursor name is not
+// used as label, because it is random by default and would create a new time series for every iterator.
+// Iterators can use the same reg and namespace, the metrics are registered once.
+// WithPrometheus sets the Observer of the iterator, so it can not be combined with WithObserver().
+func WithPrometheus(reg prometheus.Registerer, namespace, job string) cursoriterator.Option {
+	return func(iter *cursoriterator.CursorIterator) error {
+		if reg == nil {
+			return errors.New("registerer cannot be nil")
+		}
+		if job == "" {
+			return errors.New("job cannot be empty")
+		}
+		m, err := registerMetrics(reg, namespace)
+		if err != nil {
+			return err
+		}
+		return cursoriterator.WithObserver(&observer{
+			batches:  m.batches.WithLabelValues(job),
+			rows:     m.rows.WithLabelValues(job),
+			duration: m.duration.WithLabelValues(job),
+			errors:   m.errors.WithLabelValues(job),
+		})(iter)
+	}
+}
+
+// metrics are the collectors that are shared by all iterators of a registry and namespace.
+type metrics struct {
+	batches  *prometheus.CounterVec
+	rows     *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+}
+
+// registerMetrics registers the collectors with reg, or returns the collectors that were registered before.
+func registerMetrics(reg prometheus.Registerer, namespace string) (*metrics, error) {
+	labels := []string{"job"}
+	m := &metrics{
+		batches: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "cursor",
+			Name:      "batches_total",
+			Help:      "Total number of batches fetched by the cursor iterators.",
+		}, labels),
+		rows: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "cursor",
+			Name:      "rows_total",
+			Help:      "Total number of rows fetched by the cursor iterators.",
+		}, labels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "cursor",
+			Name:      "fetch_duration_seconds",
+			Help:      "Duration of the fetches of the cursor iterators.",
+			Buckets:   prometheus.DefBuckets,
+		}, labels),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "cursor",
+			Name:      "errors_total",
+			Help:      "Total number of failed fetches of the cursor iterators.",
+		}, labels),
+	}
+	var err error
+	if m.batches, err = register(reg, m.batches); err != nil {
+		return nil, err
+	}
+	if m.rows, err = register(reg, m.rows); err != nil {
+		return nil, err
+	}
+	if m.duration, err = register(reg, m.duration); err != nil {
+		return nil, err
+	}
+	if m.errors, err = register(reg, m.errors); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// register registers c with reg, if an equal collector was registered before that collector is returned.
+func register[T prometheus.Collector](reg prometheus.Registerer, c T) (T, error) {
+	if err := reg.Register(c); err != nil {
+		var registered prometheus.AlreadyRegisteredError
+		if errors.As(err, &registered) {
+			if existing, ok := registered.ExistingCollector.(T); ok {
+				return existing, nil
+			}
+		}
+		return c, errors.Wrap(err, "unable to register metric")
+	}
+	return c, nil
+}
+
+// observer updates the metrics of a job.
+type observer struct {
+	batches  prometheus.Counter
+	rows     prometheus.Counter
+	duration prometheus.Observer
+	errors   prometheus.Counter
+}
+
+func (o *observer) Declared(context.Context) {}
+
+func (o *observer) FetchStarted(context.Context, int) {}
+
+func (o *observer) FetchCompleted(_ context.Context, rows int, d time.Duration, err error) {
+	o.duration.Observe(d.Seconds())
+	if err != nil {
+		o.errors.Inc()
+		return
+	}
+	o.batches.Inc()
+	o.rows.Add(float64(rows))
+}
+
+func (o *observer) Closed(context.Context, error) {}
@@ -0,0 +1,109 @@
+package cursoriteratorprom_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+
+	cursoriterator "github.com/Eun/go-pgx-cursor-iterator/v2"
+	"github.com/Eun/go-pgx-cursor-iterator/v2/cursoriteratorprom"
+	"github.com/Eun/go-pgx-cursor-iterator/v2/cursoriteratortest"
+)
+
+type User struct {
+	ID   int    `db:"id"`
+	Name string `db:"name"`
+}
+
+func newConnector() *cursoriteratortest.Connector {
+	return cursoriteratortest.NewConnector(
+		[]string{"id", "name"},
+		[]interface{}{1, "Joe"},
+		[]interface{}{2, "Alice"},
+		[]interface{}{3, "Bob"},
+	)
+}
+
+func iterate(t *testing.T, connector cursoriterator.PgxConnector, option cursoriterator.Option) error {
+	iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+		connector, 2, []cursoriterator.Option{option}, "SELECT * FROM users",
+	)
+	require.NoError(t, err)
+	for iter.Next(context.Background()) {
+	}
+	_ = iter.Close(context.Background())
+	return iter.Error()
+}
+
+// gather returns the values of the counters and the sample counts of the histograms by metric name and job.
+func gather(t *testing.T, reg *prometheus.Registry) map[string]map[string]float64 {
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	result := make(map[string]map[string]float64)
+	for _, family := range families {
+		values := make(map[string]float64)
+		for _, metric := range family.GetMetric() {
+			job := metric.GetLabel()[0].GetValue()
+			if histogram := metric.GetHistogram(); histogram != nil {
+				values[job] = float64(histogram.GetSampleCount())
+				continue
+			}
+			values[job] = metric.GetCounter().GetValue()
+		}
+		result[family.GetName()] = values
+	}
+	return result
+}
+
+func TestWithPrometheus(t *testing.T) {
+	t.Parallel()
+
+	t.Run("metrics", func(t *testing.T) {
+		t.Parallel()
+		reg := prometheus.NewRegistry()
+		require.NoError(t, iterate(t, newConnector(), cursoriteratorprom.WithPrometheus(reg, "app", "users")))
+		require.NoError(t, iterate(t, newConnector(), cursoriteratorprom.WithPrometheus(reg, "app", "users")))
+		require.NoError(t, iterate(t, newConnector(), cursoriteratorprom.WithPrometheus(reg, "app", "admins")))
+
+		failing := newConnector()
+		failing.FetchErr = func(fetch int) error {
+			if fetch == 2 {
+				return errors.New("connection reset")
+			}
+			return nil
+		}
+		require.Error(t, iterate(t, failing, cursoriteratorprom.WithPrometheus(reg, "app", "admins")))
+
+		// every iteration fetches 2 + 1 + 0 rows
+		require.Equal(t, map[string]map[string]float64{
+			"app_cursor_batches_total":          {"users": 6, "admins": 4},
+			"app_cursor_rows_total":             {"users": 6, "admins": 5},
+			"app_cursor_fetch_duration_seconds": {"users": 6, "admins": 5},
+			"app_cursor_errors_total":           {"users": 0, "admins": 1},
+		}, gather(t, reg))
+	})
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		t.Parallel()
+		_, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+			newConnector(), 2, []cursoriterator.Option{cursoriteratorprom.WithPrometheus(nil, "app", "users")}, "SELECT * FROM users",
+		)
+		require.EqualError(t, err, "registerer cannot be nil")
+		_, err = cursoriterator.NewTypedCursorIteratorWithOptions[User](
+			newConnector(), 2, []cursoriterator.Option{cursoriteratorprom.WithPrometheus(prometheus.NewRegistry(), "app", "")},
+			"SELECT * FROM users",
+		)
+		require.EqualError(t, err, "job cannot be empty")
+
+		// a different metric with the same name
+		reg := prometheus.NewRegistry()
+		reg.MustRegister(prometheus.NewCounter(prometheus.CounterOpts{Name: "app_cursor_batches_total", Help: "other"}))
+		_, err = cursoriterator.NewTypedCursorIteratorWithOptions[User](
+			newConnector(), 2, []cursoriterator.Option{cursoriteratorprom.WithPrometheus(reg, "app", "users")}, "SELECT * FROM users",
+		)
+		require.ErrorContains(t, err, "unable to register metric")
+	})
+}
@@ -0,0 +1,277 @@
+// Package cursoriteratortest provides an in-memory connector that can be used to test code using the
+// cursoriterator package without a database.
+//
+// Example Usage:
+//
+//	connector := cursoriteratortest.NewConnector(
+//		[]string{"id", "name"},
+//		[]interface{}{1, "Joe"},
+//		[]interface{}{2, "Alice"},
+//	)
+//	iter, err := cursoriterator.NewTypedCursorIterator[User](connector, 10, "SELECT id, name FROM users")
+package cursoriteratortest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pkg/errors"
+
+	cursoriterator "github.com/Eun/go-pgx-cursor-iterator/v2"
+)
+
+var _ cursoriterator.PgxConnector = (*Connector)(nil)
+
+// Connector is an in-memory connector that serves scripted rows to the cursor of the iterator.
+// The query passed to the iterator is ignored, every cursor returns the configured rows.
+// It is safe for concurrent use.
+type Connector struct {
+	columns []string
+	rows    [][]interface{}
+
+	// BeginErr, if set, will be returned by Begin().
+	BeginErr error
+	// FetchErr, if set, will be called before every FETCH with the number of the fetch (starting with 1),
+	// a returned error will be returned by the query.
+	FetchErr func(fetch int) error
+	// RollbackErr, if set, will be returned by the rollback of the transactions.
+	RollbackErr error
+
+	// The hooks intercept the transactions of the connector, e.g. to delay or fail single statements.
+	// They are called without holding a lock, so they must synchronize access to shared state themselves.
+
+	// OnBegin, if set, will be called before every Begin(), a returned error will be returned by Begin().
+	OnBegin func(ctx context.Context) error
+	// OnQuery, if set, will be called for every query after it was recorded, next runs the in-memory query.
+	// OnQuery can delay or fail the query, wrap the returned rows or answer the query itself (see NewRows()).
+	OnQuery func(ctx context.Context, next QueryFunc, sql string, args ...interface{}) (pgx.Rows, error)
+	// OnExec, if set, will be called for every statement after it was recorded, next runs the in-memory statement.
+	OnExec func(ctx context.Context, next ExecFunc, sql string, args ...interface{}) (pgconn.CommandTag, error)
+	// OnDeclare, if set, returns the rows of every declared cursor instead of the rows of the connector,
+	// e.g. to filter the rows by the arguments of the query.
+	OnDeclare func(sql string, args ...interface{}) [][]interface{}
+	// OnPrepare, if set, answers Prepare(), which is not supported otherwise.
+	OnPrepare func(ctx context.Context, name, sql string) (*pgconn.StatementDescription, error)
+	// OnRollback, if set, will be called before a transaction is rolled back, a returned error will be returned by
+	// Rollback() and the transaction stays open.
+	OnRollback func(ctx context.Context) error
+
+	mu         sync.Mutex
+	statements []string
+}
+
+// QueryFunc runs a query on a transaction, see Connector.OnQuery.
+type QueryFunc func(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+
+// ExecFunc executes a statement on a transaction, see Connector.OnExec.
+type ExecFunc func(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+
+// NewConnector creates a new Connector that returns the rows with the given columns.
+// Every row must have as many values as columns.
+func NewConnector(columns []string, rows ...[]interface{}) *Connector {
+	return &Connector{
+		columns: columns,
+		rows:    rows,
+	}
+}
+
+// Begin starts a new in-memory transaction.
+func (c *Connector) Begin(ctx context.Context) (pgx.Tx, error) {
+	if c.BeginErr != nil {
+		return nil, c.BeginErr
+	}
+	if c.OnBegin != nil {
+		if err := c.OnBegin(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.record("BEGIN")
+	return &tx{connector: c, rows: c.rows}, nil
+}
+
+// Statements returns all statements that were executed on the transactions of this connector.
+func (c *Connector) Statements() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	statements := make([]string, len(c.statements))
+	copy(statements, c.statements)
+	return statements
+}
+
+func (c *Connector) record(statement string) {
+	c.mu.Lock()
+	c.statements = append(c.statements, statement)
+	c.mu.Unlock()
+}
+
+// tx is the transaction returned by Connector.Begin().
+type tx struct {
+	connector *Connector
+	// rows are the rows of the declared cursor, see Connector.OnDeclare.
+	rows    [][]interface{}
+	pos     int
+	fetches int
+	closed  bool
+}
+
+func (t *tx) Begin(context.Context) (pgx.Tx, error) {
+	return nil, errors.New("nested transactions are not supported")
+}
+
+func (t *tx) Commit(context.Context) error {
+	if t.closed {
+		return pgx.ErrTxClosed
+	}
+	t.connector.record("COMMIT")
+	t.closed = true
+	return nil
+}
+
+func (t *tx) Rollback(ctx context.Context) error {
+	if t.closed {
+		return pgx.ErrTxClosed
+	}
+	if t.connector.OnRollback != nil {
+		if err := t.connector.OnRollback(ctx); err != nil {
+			return err
+		}
+	}
+	t.connector.record("ROLLBACK")
+	t.closed = true
+	return t.connector.RollbackErr
+}
+
+func (t *tx) CopyFrom(context.Context, pgx.Identifier, []string, pgx.CopyFromSource) (int64, error) {
+	return 0, errors.New("copy from is not supported")
+}
+
+func (t *tx) SendBatch(context.Context, *pgx.Batch) pgx.BatchResults {
+	return errBatchResults{}
+}
+
+func (t *tx) LargeObjects() pgx.LargeObjects {
+	return pgx.LargeObjects{}
+}
+
+func (t *tx) Prepare(ctx context.Context, name, sql string) (*pgconn.StatementDescription, error) {
+	if t.connector.OnPrepare != nil {
+		return t.connector.OnPrepare(ctx, name, sql)
+	}
+	return nil, errors.New("prepare is not supported")
+}
+
+// Exec accepts every statement, DECLARE starts the cursor at the first row, MOVE ABSOLUTE moves the cursor
+// to the given position.
+func (t *tx) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	if t.closed {
+		return pgconn.CommandTag{}, pgx.ErrTxClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	t.connector.record(sql)
+	if t.connector.OnExec != nil {
+		return t.connector.OnExec(ctx, t.exec, sql, args...)
+	}
+	return t.exec(ctx, sql, args...)
+}
+
+// exec runs the in-memory statement.
+func (t *tx) exec(_ context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	if strings.HasPrefix(sql, "DECLARE ") {
+		t.pos = 0
+		t.rows = t.connector.rows
+		if t.connector.OnDeclare != nil {
+			t.rows = t.connector.OnDeclare(sql, args...)
+		}
+	}
+	var pos int
+	if _, err := fmt.Sscanf(sql, "MOVE ABSOLUTE %d IN", &pos); err == nil {
+		t.pos = pos
+		return pgconn.NewCommandTag("MOVE 1"), nil
+	}
+	return pgconn.NewCommandTag(strings.SplitN(sql, " ", 2)[0]), nil
+}
+
+// Query supports FETCH [FORWARD] n IN cursor, it returns the next n rows.
+func (t *tx) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	if t.closed {
+		return nil, pgx.ErrTxClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	t.connector.record(sql)
+	if t.connector.OnQuery != nil {
+		return t.connector.OnQuery(ctx, t.fetch, sql, args...)
+	}
+	return t.fetch(ctx, sql, args...)
+}
+
+// fetch runs the in-memory FETCH.
+func (t *tx) fetch(_ context.Context, sql string, _ ...interface{}) (pgx.Rows, error) {
+	var n int
+	if _, err := fmt.Sscanf(strings.Replace(sql, "FETCH FORWARD ", "FETCH ", 1), "FETCH %d IN", &n); err != nil {
+		return nil, errors.Errorf("unsupported query %q", sql)
+	}
+	t.fetches++
+	if t.connector.FetchErr != nil {
+		if err := t.connector.FetchErr(t.fetches); err != nil {
+			return nil, err
+		}
+	}
+
+	end := t.pos + n
+	if end > len(t.rows) {
+		end = len(t.rows)
+	}
+	if t.pos > end {
+		t.pos = end
+	}
+	r := &rows{columns: t.connector.columns, rows: t.rows[t.pos:end], index: -1}
+	t.pos = end
+	return r, nil
+}
+
+func (t *tx) QueryRow(context.Context, string, ...interface{}) pgx.Row {
+	return errRow{err: errors.New("query row is not supported")}
+}
+
+func (t *tx) Conn() *pgx.Conn {
+	return nil
+}
+
+// errBatchResults is returned by tx.SendBatch, since batches are not supported.
+type errBatchResults struct{}
+
+func (errBatchResults) Exec() (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, errors.New("batches are not supported")
+}
+
+func (errBatchResults) Query() (pgx.Rows, error) {
+	return nil, errors.New("batches are not supported")
+}
+
+func (errBatchResults) QueryRow() pgx.Row {
+	return errRow{err: errors.New("batches are not supported")}
+}
+
+func (errBatchResults) Close() error {
+	return nil
+}
+
+// errRow is a pgx.Row that always returns err.
+type errRow struct {
+	err error
+}
+
+func (r errRow) Scan(...interface{}) error {
+	return r.err
+}
@@ -0,0 +1,179 @@
+package cursoriteratortest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+
+	cursoriterator "github.com/Eun/go-pgx-cursor-iterator/v2"
+	"github.com/Eun/go-pgx-cursor-iterator/v2/cursoriteratortest"
+)
+
+type User struct {
+	ID   int    `db:"id"`
+	Name string `db:"name"`
+}
+
+func newConnector() *cursoriteratortest.Connector {
+	return cursoriteratortest.NewConnector(
+		[]string{"id", "name"},
+		[]interface{}{1, "Joe"},
+		[]interface{}{2, "Alice"},
+		[]interface{}{3, "Bob"},
+	)
+}
+
+func TestConnector(t *testing.T) {
+	t.Parallel()
+	connector := newConnector()
+	iter, err := cursoriterator.NewTypedCursorIterator[User](connector, 2, "SELECT * FROM users")
+	require.NoError(t, err)
+
+	require.Equal(t, -2, iter.ValueIndex())
+	var users []User
+	for iter.Next(context.Background()) {
+		users = append(users, iter.Value())
+	}
+	require.NoError(t, iter.Error())
+	require.Equal(t, []User{{1, "Joe"}, {2, "Alice"}, {3, "Bob"}}, users)
+	require.Equal(t, -1, iter.ValueIndex())
+	require.NoError(t, iter.Close(context.Background()))
+
+	name := `"` + iter.CursorName() + `"`
+	require.Equal(t, []string{
+		"BEGIN",
+		"DECLARE " + name + " CURSOR FOR SELECT * FROM users",
+		"FETCH FORWARD 2 IN " + name,
+		"FETCH FORWARD 2 IN " + name,
+		"FETCH FORWARD 2 IN " + name,
+		"ROLLBACK",
+	}, connector.Statements())
+}
+
+func TestConnectorBeginError(t *testing.T) {
+	t.Parallel()
+	connector := newConnector()
+	connector.BeginErr = errors.New("no connection")
+	iter, err := cursoriterator.NewTypedCursorIterator[User](connector, 2, "SELECT * FROM users")
+	require.NoError(t, err)
+
+	require.False(t, iter.Next(context.Background()))
+	require.ErrorIs(t, iter.Error(), connector.BeginErr)
+}
+
+func TestConnectorFetchError(t *testing.T) {
+	t.Parallel()
+	connector := newConnector()
+	errFetch := errors.New("fetch failed")
+	connector.FetchErr = func(fetch int) error {
+		if fetch == 2 {
+			return errFetch
+		}
+		return nil
+	}
+	iter, err := cursoriterator.NewTypedCursorIterator[User](connector, 2, "SELECT * FROM users")
+	require.NoError(t, err)
+
+	require.True(t, iter.Next(context.Background()))
+	require.True(t, iter.Next(context.Background()))
+	require.False(t, iter.Next(context.Background()))
+	require.ErrorIs(t, iter.Error(), errFetch)
+	require.ErrorIs(t, iter.Close(context.Background()), errFetch)
+}
+
+func TestConnectorScanError(t *testing.T) {
+	t.Parallel()
+	connector := cursoriteratortest.NewConnector(
+		[]string{"id", "name"},
+		[]interface{}{1, "Joe"},
+		[]interface{}{2, nil},
+	)
+	iter, err := cursoriterator.NewTypedCursorIterator[User](connector, 2, "SELECT * FROM users")
+	require.NoError(t, err)
+
+	require.False(t, iter.Next(context.Background()))
+	require.ErrorContains(t, iter.Error(), "cannot scan NULL into string")
+	require.Equal(t, []User{{1, "Joe"}}, iter.PartialBatch())
+	require.ErrorContains(t, iter.Close(context.Background()), "cannot scan NULL into string")
+}
+
+func TestConnectorRollbackError(t *testing.T) {
+	t.Parallel()
+	connector := newConnector()
+	connector.RollbackErr = errors.New("rollback failed")
+	iter, err := cursoriterator.NewTypedCursorIterator[User](connector, 5, "SELECT * FROM users")
+	require.NoError(t, err)
+
+	for iter.Next(context.Background()) {
+	}
+	require.ErrorIs(t, iter.Error(), connector.RollbackErr)
+}
+
+func TestConnectorHooks(t *testing.T) {
+	t.Parallel()
+	connector := newConnector()
+	var execs, queries []string
+	connector.OnExec = func(ctx context.Context, next cursoriteratortest.ExecFunc, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+		execs = append(execs, sql)
+		return next(ctx, sql, args...)
+	}
+	connector.OnQuery = func(ctx context.Context, next cursoriteratortest.QueryFunc, sql string, args ...interface{}) (pgx.Rows, error) {
+		queries = append(queries, sql)
+		return next(ctx, sql, args...)
+	}
+	// the declared cursor only returns the rows with an id bigger than the argument
+	connector.OnDeclare = func(_ string, args ...interface{}) [][]interface{} {
+		return [][]interface{}{{2, "Alice"}, {3, "Bob"}}[args[0].(int)-1:]
+	}
+	iter, err := cursoriterator.NewTypedCursorIterator[User](connector, 5, "SELECT * FROM users WHERE id > $1", 2)
+	require.NoError(t, err)
+
+	var users []User
+	for iter.Next(context.Background()) {
+		users = append(users, iter.Value())
+	}
+	require.NoError(t, iter.Error())
+	require.NoError(t, iter.Close(context.Background()))
+	require.Equal(t, []User{{3, "Bob"}}, users)
+
+	name := `"` + iter.CursorName() + `"`
+	require.Equal(t, []string{"DECLARE " + name + " CURSOR FOR SELECT * FROM users WHERE id > $1"}, execs)
+	require.Equal(t, []string{"FETCH FORWARD 5 IN " + name, "FETCH FORWARD 5 IN " + name}, queries)
+}
+
+func TestConnectorHookErrors(t *testing.T) {
+	t.Parallel()
+	errBegin := errors.New("begin failed")
+	connector := newConnector()
+	connector.OnBegin = func(context.Context) error {
+		return errBegin
+	}
+	iter, err := cursoriterator.NewTypedCursorIterator[User](connector, 2, "SELECT * FROM users")
+	require.NoError(t, err)
+	require.False(t, iter.Next(context.Background()))
+	require.ErrorIs(t, iter.Error(), errBegin)
+	require.Empty(t, connector.Statements())
+
+	errRollback := errors.New("rollback failed")
+	connector = newConnector()
+	connector.OnRollback = func(context.Context) error {
+		return errRollback
+	}
+	tx, err := connector.Begin(context.Background())
+	require.NoError(t, err)
+	require.ErrorIs(t, tx.Rollback(context.Background()), errRollback)
+	_, err = tx.Prepare(context.Background(), "stmt", "SELECT 1")
+	require.EqualError(t, err, "prepare is not supported")
+
+	connector.OnPrepare = func(_ context.Context, name, sql string) (*pgconn.StatementDescription, error) {
+		return &pgconn.StatementDescription{Name: name, SQL: sql}, nil
+	}
+	description, err := tx.Prepare(context.Background(), "stmt", "SELECT 1")
+	require.NoError(t, err)
+	require.Equal(t, "SELECT 1", description.SQL)
+	require.Equal(t, []string{"BEGIN"}, connector.Statements())
+}
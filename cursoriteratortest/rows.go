@@ -0,0 +1,139 @@
+package cursoriteratortest
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pkg/errors"
+)
+
+// NewRows returns pgx.Rows with the given columns and rows, like the rows of a FETCH of the Connector.
+// It can be used to answer queries in Connector.OnQuery.
+func NewRows(columns []string, values ...[]interface{}) pgx.Rows {
+	return &rows{columns: columns, rows: values, index: -1}
+}
+
+// rows is the pgx.Rows implementation returned for a FETCH.
+type rows struct {
+	columns []string
+	rows    [][]interface{}
+	index   int
+	closed  bool
+	err     error
+}
+
+func (r *rows) Close() {
+	r.closed = true
+}
+
+func (r *rows) Err() error {
+	return r.err
+}
+
+func (r *rows) CommandTag() pgconn.CommandTag {
+	return pgconn.NewCommandTag(fmt.Sprintf("FETCH %d", len(r.rows)))
+}
+
+func (r *rows) FieldDescriptions() []pgconn.FieldDescription {
+	fields := make([]pgconn.FieldDescription, len(r.columns))
+	for i, column := range r.columns {
+		fields[i].Name = column
+	}
+	return fields
+}
+
+func (r *rows) Next() bool {
+	if r.closed {
+		return false
+	}
+	r.index++
+	if r.index >= len(r.rows) {
+		r.closed = true
+		return false
+	}
+	return true
+}
+
+// Scan assigns the values of the current row to dest.
+// Like pgx it closes the rows if a value can not be scanned.
+func (r *rows) Scan(dest ...interface{}) error {
+	if r.index < 0 || r.index >= len(r.rows) {
+		return errors.New("no current row")
+	}
+	row := r.rows[r.index]
+	if len(dest) != len(row) {
+		r.fail(errors.Errorf("number of field descriptions must equal number of destinations, got %d and %d",
+			len(row), len(dest)))
+		return r.err
+	}
+	for i := range dest {
+		if err := assign(dest[i], row[i]); err != nil {
+			r.fail(pgx.ScanArgError{ColumnIndex: i, Err: err})
+			return r.err
+		}
+	}
+	return nil
+}
+
+func (r *rows) fail(err error) {
+	r.err = err
+	r.closed = true
+}
+
+func (r *rows) Values() ([]interface{}, error) {
+	if r.index < 0 || r.index >= len(r.rows) {
+		return nil, errors.New("no current row")
+	}
+	return r.rows[r.index], nil
+}
+
+func (r *rows) RawValues() [][]byte {
+	if r.index < 0 || r.index >= len(r.rows) {
+		return nil
+	}
+	values := make([][]byte, len(r.rows[r.index]))
+	for i, v := range r.rows[r.index] {
+		if v != nil {
+			values[i] = []byte(fmt.Sprint(v))
+		}
+	}
+	return values
+}
+
+func (r *rows) Conn() *pgx.Conn {
+	return nil
+}
+
+// assign stores src in the value dest points to.
+func assign(dest, src interface{}) error {
+	dv := reflect.ValueOf(dest)
+	if dv.Kind() != reflect.Ptr || dv.IsNil() {
+		return errors.Errorf("destination must be a non nil pointer, got %T", dest)
+	}
+	dv = dv.Elem()
+	if src == nil {
+		switch dv.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+			dv.Set(reflect.Zero(dv.Type()))
+			return nil
+		default:
+			return errors.Errorf("cannot scan NULL into %s", dv.Type())
+		}
+	}
+	sv := reflect.ValueOf(src)
+	switch {
+	case sv.Type().AssignableTo(dv.Type()):
+		dv.Set(sv)
+	case dv.Kind() == reflect.Ptr && sv.Type().AssignableTo(dv.Type().Elem()):
+		p := reflect.New(dv.Type().Elem())
+		p.Elem().Set(sv)
+		dv.Set(p)
+	case sv.Type().ConvertibleTo(dv.Type()) && sv.Kind() != reflect.String && dv.Kind() != reflect.String:
+		dv.Set(sv.Convert(dv.Type()))
+	default:
+		return errors.Errorf("cannot scan %s into %s", sv.Type(), dv.Type())
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package cursoriterator
+
+import (
+	"github.com/pkg/errors"
+)
+
+// The phases of the iteration, they will be passed to the formatter of WithErrorFormatter().
+const (
+	// PhaseBegin is the phase that starts the transaction.
+	PhaseBegin = "begin"
+	// PhaseDeclare is the phase that prepares the transaction and declares the cursor.
+	PhaseDeclare = "declare"
+	// PhaseFetch is the phase that fetches the rows.
+	PhaseFetch = "fetch"
+	// PhaseClose is the phase that rolls back the transaction.
+	PhaseClose = "close"
+)
+
+// WithErrorFormatter transforms the errors of the iterator before they are returned by Error() or Close(),
+// e.g. to convert them into application specific error types or to localize them.
+// formatter receives the phase the error occurred in (see PhaseBegin, PhaseDeclare, PhaseFetch and PhaseClose)
+// and the error as it would be returned without a formatter. If formatter returns nil the error is kept.
+// WithRetry() and WithCircuitBreaker() receive the errors before they are formatted.
+func WithErrorFormatter(formatter func(phase string, err error) error) Option {
+	return func(iter *CursorIterator) error {
+		if formatter == nil {
+			return errors.New("error formatter cannot be nil")
+		}
+		iter.errorFormatter = formatter
+		return nil
+	}
+}
+
+// formatError formats err with the formatter of WithErrorFormatter().
+func (iter *CursorIterator) formatError(phase string, err error) error {
+	if err == nil || iter.errorFormatter == nil {
+		return err
+	}
+	if formatted := iter.errorFormatter(phase, err); formatted != nil {
+		return formatted
+	}
+	return err
+}
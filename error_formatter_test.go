@@ -0,0 +1,116 @@
+package cursoriterator_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+
+	cursoriterator "github.com/Eun/go-pgx-cursor-iterator/v2"
+)
+
+type DeclareError struct {
+	Err error
+}
+
+func (e *DeclareError) Error() string {
+	return "cursor could not be declared"
+}
+
+func (e *DeclareError) Unwrap() error {
+	return e.Err
+}
+
+func declareErrorFormatter(phase string, err error) error {
+	if phase == cursoriterator.PhaseDeclare {
+		return &DeclareError{Err: err}
+	}
+	return nil
+}
+
+func TestWithErrorFormatter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("declare", func(t *testing.T) {
+		t.Parallel()
+		connector, _ := newUsersConnector(3)
+		errInit := errors.New("unknown schema")
+		iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+			connector,
+			2,
+			[]cursoriterator.Option{
+				cursoriterator.WithConnectionInit(func(context.Context, pgx.Tx) error {
+					return errInit
+				}),
+				cursoriterator.WithErrorFormatter(declareErrorFormatter),
+			},
+			"SELECT * FROM users",
+		)
+		require.NoError(t, err)
+
+		require.False(t, iter.Next(context.Background()))
+		var declareErr *DeclareError
+		require.ErrorAs(t, iter.Error(), &declareErr)
+		require.ErrorIs(t, iter.Error(), errInit)
+		require.EqualError(t, declareErr.Err, "connection init failed: unknown schema")
+		require.ErrorAs(t, iter.Close(context.Background()), &declareErr)
+	})
+
+	t.Run("begin", func(t *testing.T) {
+		t.Parallel()
+		connector, _ := newUsersConnector(3)
+		connector.BeginErr = errors.New("no connection")
+		iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+			connector,
+			2,
+			[]cursoriterator.Option{cursoriterator.WithErrorFormatter(declareErrorFormatter)},
+			"SELECT * FROM users",
+		)
+		require.NoError(t, err)
+
+		// the formatter returned nil, so the error is kept
+		require.False(t, iter.Next(context.Background()))
+		require.EqualError(t, iter.Error(), "unable to start transaction: no connection")
+	})
+
+	t.Run("phases", func(t *testing.T) {
+		t.Parallel()
+		connector, _ := newUsersConnector(3)
+		connector.FetchErr = func(fetch int) error {
+			return errors.New("connection reset")
+		}
+		connector.RollbackErr = errors.New("rollback failed")
+		var phases []string
+		iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+			connector,
+			2,
+			[]cursoriterator.Option{
+				cursoriterator.WithErrorFormatter(func(phase string, err error) error {
+					phases = append(phases, phase)
+					return errors.New(phase + " failed")
+				}),
+			},
+			"SELECT * FROM users",
+		)
+		require.NoError(t, err)
+
+		require.False(t, iter.Next(context.Background()))
+		require.EqualError(t, iter.Error(), "fetch failed")
+		require.EqualError(t, iter.Close(context.Background()), "fetch failed\nclose failed")
+		require.Equal(t, []string{cursoriterator.PhaseFetch, cursoriterator.PhaseClose}, phases)
+	})
+
+	t.Run("nil formatter", func(t *testing.T) {
+		t.Parallel()
+		connector, _ := newUsersConnector(3)
+		_, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+			connector,
+			2,
+			[]cursoriterator.Option{cursoriterator.WithErrorFormatter(nil)},
+			"SELECT * FROM users",
+		)
+		require.EqualError(t, err, "error formatter cannot be nil")
+	})
+}
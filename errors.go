@@ -0,0 +1,94 @@
+package cursoriterator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pkg/errors"
+)
+
+// ErrCursorLost will be returned when the connection the cursor was declared on was lost during the iteration.
+// Cursors are bound to their session, so the iteration cannot be resumed and must be restarted.
+var ErrCursorLost = errors.New("cursor lost, the connection to the database was closed")
+
+// ErrPoolExhausted will be returned when no connection could be acquired from the pool
+// because all connections are in use.
+var ErrPoolExhausted = errors.New("connection pool exhausted, increase the pool size or close unused iterators")
+
+// ErrTransactionAborted will be returned when a fetch failed because the transaction of the iterator was already
+// aborted by a previous error (SQLSTATE 25P02), e.g. by a failed statement executed with ExecCurrentOf().
+var ErrTransactionAborted = errors.New("transaction aborted by a previous error, the iteration cannot be continued")
+
+// ErrMaxBatchesExceeded will be returned when the iteration fetched more batches than allowed by WithMaxBatches().
+var ErrMaxBatchesExceeded = errors.New("maximum number of batches exceeded")
+
+// ErrNoRows will be returned when the iteration returned no rows and WithErrorOnEmpty() is used.
+// It wraps pgx.ErrNoRows, so errors.Is(err, pgx.ErrNoRows) works like for pgx.Row.Scan().
+var ErrNoRows = fmt.Errorf("iteration returned no rows: %w", pgx.ErrNoRows)
+
+// ErrCanceled will be returned when the iteration was canceled with Cancel().
+var ErrCanceled = errors.New("iteration canceled")
+
+// SQLState returns the SQLSTATE code of the *pgconn.PgError in the chain of err.
+// The second return value is false if err does not contain a *pgconn.PgError.
+func SQLState(err error) (string, bool) {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return "", false
+	}
+	return pgErr.Code, true
+}
+
+// isContextError reports whether err was caused by a canceled context or an exceeded deadline.
+func isContextError(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled)
+}
+
+// isConnectionLost reports whether err was caused by a lost connection to the database.
+func isConnectionLost(err error) bool {
+	// context errors (and the errors of pgconn for a done context) satisfy net.Error, but the connection is fine
+	if isContextError(err) {
+		return false
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, net.ErrClosed) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// class 08 - connection exception
+		// 57P01 - admin_shutdown, 57P02 - crash_shutdown, 57P03 - cannot_connect_now
+		return strings.HasPrefix(pgErr.Code, "08") ||
+			pgErr.Code == "57P01" || pgErr.Code == "57P02" || pgErr.Code == "57P03"
+	}
+	return false
+}
+
+// isTransactionAborted reports whether err was caused by a statement in an aborted transaction.
+func isTransactionAborted(err error) bool {
+	code, ok := SQLState(err)
+	// in_failed_sql_transaction
+	return ok && code == "25P02"
+}
+
+// isPoolExhausted reports whether err was caused by waiting for a connection of an exhausted pool.
+func isPoolExhausted(connector PgxConnector, err error) bool {
+	if !isContextError(err) {
+		return false
+	}
+	pool, ok := connector.(*pgxpool.Pool)
+	if !ok {
+		return false
+	}
+	stat := pool.Stat()
+	return stat.AcquiredConns() >= stat.MaxConns()
+}
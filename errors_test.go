@@ -0,0 +1,212 @@
+package cursoriterator_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+
+	cursoriterator "github.com/Eun/go-pgx-cursor-iterator/v2"
+	"github.com/Eun/go-pgx-cursor-iterator/v2/cursoriteratortest"
+)
+
+func TestErrCursorLost(t *testing.T) {
+	t.Parallel()
+	runTest(
+		t,
+		[]User{
+			{1, "Joe"},
+			{2, "Alice"},
+			{3, "Bob"},
+			{4, "Mike"},
+			{5, "Maria"},
+		},
+		func(pool *pgxpool.Pool) {
+			values := make([]User, 2)
+			iter, err := cursoriterator.NewCursorIterator(pool, values, "SELECT * FROM users ORDER BY id")
+			require.NoError(t, err)
+
+			require.True(t, iter.Next(context.Background()))
+
+			// terminate the connection of the iterator
+			_, err = pool.Exec(context.Background(), `
+SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE query LIKE 'FETCH%' AND pid <> pg_backend_pid()`)
+			require.NoError(t, err)
+
+			require.True(t, iter.Next(context.Background()))
+			require.False(t, iter.Next(context.Background()))
+			require.True(t, errors.Is(iter.Error(), cursoriterator.ErrCursorLost))
+			require.Equal(t, -1, iter.ValueIndex())
+		})
+}
+
+func TestErrCursorLostContextError(t *testing.T) {
+	t.Parallel()
+	connector := cursoriteratortest.NewConnector([]string{"id", "name"},
+		[]interface{}{1, "Joe"},
+		[]interface{}{2, "Alice"},
+		[]interface{}{3, "Bob"},
+	)
+	connector.FetchErr = func(fetch int) error {
+		if fetch == 2 {
+			// context.DeadlineExceeded satisfies net.Error, but does not mean that the connection was lost
+			return context.DeadlineExceeded
+		}
+		return nil
+	}
+	iter, err := cursoriterator.NewTypedCursorIterator[User](connector, 2, "SELECT * FROM users")
+	require.NoError(t, err)
+	require.True(t, iter.Next(context.Background()))
+	require.True(t, iter.Next(context.Background()))
+
+	require.False(t, iter.Next(context.Background()))
+	require.ErrorIs(t, iter.Error(), context.DeadlineExceeded)
+	require.False(t, errors.Is(iter.Error(), cursoriterator.ErrCursorLost))
+
+	expectTypedValues(t, iter, User{3, "Bob"})
+	require.NoError(t, iter.Close(context.Background()))
+}
+
+func TestErrPoolExhausted(t *testing.T) {
+	t.Parallel()
+	runTest(
+		t,
+		[]User{
+			{1, "Joe"},
+		},
+		func(pool *pgxpool.Pool) {
+			config := pool.Config().Copy()
+			config.MaxConns = 1
+			smallPool, err := pgxpool.NewWithConfig(context.Background(), config)
+			require.NoError(t, err)
+			defer smallPool.Close()
+
+			// hold the only connection
+			conn, err := smallPool.Acquire(context.Background())
+			require.NoError(t, err)
+			defer conn.Release()
+
+			iter, err := cursoriterator.NewCursorIterator(smallPool, make([]User, 2), "SELECT * FROM users")
+			require.NoError(t, err)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+			require.False(t, iter.Next(ctx))
+			require.True(t, errors.Is(iter.Error(), cursoriterator.ErrPoolExhausted))
+			require.True(t, errors.Is(iter.Error(), context.DeadlineExceeded))
+		})
+}
+
+func TestSQLState(t *testing.T) {
+	t.Parallel()
+
+	connector := cursoriteratortest.NewConnector([]string{"id", "name"}, []interface{}{1, "Joe"})
+	connector.FetchErr = func(int) error {
+		return &pgconn.PgError{Code: "42P01", Message: `relation "users" does not exist`}
+	}
+	iter, err := cursoriterator.NewTypedCursorIterator[User](connector, 2, "SELECT * FROM users")
+	require.NoError(t, err)
+	require.False(t, iter.Next(context.Background()))
+
+	code, ok := cursoriterator.SQLState(iter.Error())
+	require.True(t, ok)
+	require.Equal(t, "42P01", code)
+
+	_, ok = cursoriterator.SQLState(errors.New("some error"))
+	require.False(t, ok)
+	_, ok = cursoriterator.SQLState(nil)
+	require.False(t, ok)
+}
+
+func TestErrTransactionAborted(t *testing.T) {
+	t.Parallel()
+
+	fetchCount := func(connector *cursoriteratortest.Connector) int {
+		n := 0
+		for _, statement := range connector.Statements() {
+			if strings.HasPrefix(statement, "FETCH ") {
+				n++
+			}
+		}
+		return n
+	}
+
+	t.Run("aborted transaction", func(t *testing.T) {
+		t.Parallel()
+		connector, users := newUsersConnector(6)
+		connector.FetchErr = func(fetch int) error {
+			if fetch == 2 {
+				return &pgconn.PgError{Code: "25P02", Message: "current transaction is aborted"}
+			}
+			return nil
+		}
+		iter, err := cursoriterator.NewTypedCursorIterator[User](connector, 2, "SELECT * FROM users")
+		require.NoError(t, err)
+
+		var result []User
+		for iter.Next(context.Background()) {
+			result = append(result, iter.Value())
+		}
+		require.Equal(t, users[:2], result)
+		require.True(t, errors.Is(iter.Error(), cursoriterator.ErrTransactionAborted))
+		require.Equal(t, -1, iter.ValueIndex())
+
+		// no further fetches are issued
+		require.False(t, iter.Next(context.Background()))
+		require.Equal(t, 2, fetchCount(connector))
+		require.True(t, errors.Is(iter.Close(context.Background()), cursoriterator.ErrTransactionAborted))
+	})
+
+	t.Run("statement error", func(t *testing.T) {
+		t.Parallel()
+		connector, _ := newUsersConnector(6)
+		connector.FetchErr = func(fetch int) error {
+			if fetch == 2 {
+				return &pgconn.PgError{Code: "22012", Message: "division by zero"}
+			}
+			return nil
+		}
+		iter, err := cursoriterator.NewTypedCursorIterator[User](connector, 2, "SELECT * FROM users")
+		require.NoError(t, err)
+		for iter.Next(context.Background()) {
+		}
+		code, _ := cursoriterator.SQLState(iter.Error())
+		require.Equal(t, "22012", code)
+
+		// the transaction is aborted, so it was closed instead of fetching again
+		require.False(t, iter.Next(context.Background()))
+		require.Equal(t, 2, fetchCount(connector))
+		code, _ = cursoriterator.SQLState(iter.Error())
+		require.Equal(t, "22012", code)
+	})
+
+	t.Run("aborted by ExecCurrentOf", func(t *testing.T) {
+		t.Parallel()
+		runTest(
+			t,
+			[]User{
+				{1, "Joe"},
+				{2, "Alice"},
+				{3, "Bob"},
+			},
+			func(pool *pgxpool.Pool) {
+				iter, err := cursoriterator.NewTypedCursorIterator[User](pool, 1, "SELECT * FROM users ORDER BY id FOR UPDATE")
+				require.NoError(t, err)
+				require.True(t, iter.Next(context.Background()))
+
+				// a failing statement aborts the transaction
+				_, err = iter.ExecCurrentOf(context.Background(), "UPDATE users SET id = 1 / 0")
+				require.Error(t, err)
+
+				require.False(t, iter.Next(context.Background()))
+				require.True(t, errors.Is(iter.Error(), cursoriterator.ErrTransactionAborted))
+				require.False(t, iter.Next(context.Background()))
+				require.True(t, errors.Is(iter.Close(context.Background()), cursoriterator.ErrTransactionAborted))
+			})
+	})
+}
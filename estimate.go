@@ -0,0 +1,76 @@
+package cursoriterator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// EstimateTotal returns the number of rows the planner expects the query of the iterator to return, e.g. to show
+// the progress of an iteration. It uses EXPLAIN (FORMAT JSON) inside a separate transaction, so the query is not
+// executed and the estimate is cheap even for huge results.
+//
+// The estimate is only as accurate as the statistics of the tables: it can be far off for outdated statistics
+// (run ANALYZE), complex predicates, joins and set returning functions. Use CountTotal() if the exact number
+// of rows is needed.
+// If WithLimit() is used the estimate will not be bigger than the limit.
+func (iter *CursorIterator) EstimateTotal(ctx context.Context) (int64, error) {
+	if iter.refCursor {
+		return 0, errors.New("ref cursor iterator cannot be estimated")
+	}
+	var plan []byte
+	if err := iter.queryTotal(ctx, "EXPLAIN (FORMAT JSON) %s", &plan); err != nil {
+		return 0, errors.Wrap(err, "unable to explain query")
+	}
+	var plans []struct {
+		Plan struct {
+			Rows float64 `json:"Plan Rows"`
+		} `json:"Plan"`
+	}
+	if err := json.Unmarshal(plan, &plans); err != nil {
+		return 0, errors.Wrap(err, "unable to parse query plan")
+	}
+	if len(plans) == 0 {
+		return 0, errors.New("query plan is empty")
+	}
+	return iter.limitTotal(int64(math.Round(plans[0].Plan.Rows))), nil
+}
+
+// CountTotal returns the exact number of rows of the query of the iterator, using SELECT COUNT(*) inside a
+// separate transaction. This executes the query, so it can take as long as the query itself.
+// The rows that are inserted or deleted after the count are not considered.
+// If WithLimit() is used the count will not be bigger than the limit.
+func (iter *CursorIterator) CountTotal(ctx context.Context) (int64, error) {
+	if iter.refCursor {
+		return 0, errors.New("ref cursor iterator cannot be counted")
+	}
+	var count int64
+	if err := iter.queryTotal(ctx, "SELECT COUNT(*) FROM (%s) AS total", &count); err != nil {
+		return 0, errors.Wrap(err, "unable to count rows")
+	}
+	return iter.limitTotal(count), nil
+}
+
+// queryTotal scans the single value returned by format, with the query of the iterator as %s, into dest.
+func (iter *CursorIterator) queryTotal(ctx context.Context, format string, dest interface{}) error {
+	tx, err := iter.connector.Begin(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to start transaction")
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+	query, args := iter.cursorQuery()
+	return tx.QueryRow(ctx, fmt.Sprintf(format, query), args...).Scan(dest)
+}
+
+// limitTotal caps total to the limit of WithLimit().
+func (iter *CursorIterator) limitTotal(total int64) int64 {
+	if iter.limit > 0 && total > iter.limit {
+		return iter.limit
+	}
+	return total
+}
@@ -0,0 +1,102 @@
+package cursoriterator_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+
+	cursoriterator "github.com/Eun/go-pgx-cursor-iterator/v2"
+)
+
+func TestEstimateTotal(t *testing.T) {
+	t.Parallel()
+
+	t.Run("count small table", func(t *testing.T) {
+		t.Parallel()
+		runTest(
+			t,
+			[]User{
+				{1, "Joe"},
+				{2, "Alice"},
+				{3, "Bob"},
+			},
+			func(pool *pgxpool.Pool) {
+				values := make([]User, 2)
+				iter, err := cursoriterator.NewCursorIterator(pool, values, "SELECT * FROM users WHERE id > $1", 1)
+				require.NoError(t, err)
+
+				count, err := iter.CountTotal(context.Background())
+				require.NoError(t, err)
+				require.Equal(t, int64(2), count)
+
+				// the iterator must not be consumed
+				require.Equal(t, -2, iter.ValueIndex())
+				expectValues(t, iter, values,
+					User{2, "Alice"},
+					User{3, "Bob"},
+				)
+				require.NoError(t, iter.Close(context.Background()))
+			})
+	})
+
+	t.Run("estimate large table", func(t *testing.T) {
+		t.Parallel()
+		runTest(t, nil, func(pool *pgxpool.Pool) {
+			_, err := pool.Exec(context.Background(), "INSERT INTO users SELECT i, 'user' || i FROM generate_series(1, 10000) i")
+			require.NoError(t, err)
+			_, err = pool.Exec(context.Background(), "ANALYZE users")
+			require.NoError(t, err)
+
+			values := make([]User, 100)
+			iter, err := cursoriterator.NewCursorIterator(pool, values, "SELECT * FROM users WHERE id > $1", 5000)
+			require.NoError(t, err)
+
+			estimate, err := iter.EstimateTotal(context.Background())
+			require.NoError(t, err)
+			require.InDelta(t, 5000, estimate, 1000)
+
+			count, err := iter.CountTotal(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, int64(5000), count)
+			require.NoError(t, iter.Close(context.Background()))
+		})
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		t.Parallel()
+		runTest(t, nil, func(pool *pgxpool.Pool) {
+			_, err := pool.Exec(context.Background(), "INSERT INTO users SELECT i, 'user' || i FROM generate_series(1, 1000) i")
+			require.NoError(t, err)
+
+			iter, err := cursoriterator.NewCursorIteratorWithOptions(
+				pool,
+				make([]User, 100),
+				[]cursoriterator.Option{cursoriterator.WithLimit(10)},
+				"SELECT * FROM users",
+			)
+			require.NoError(t, err)
+
+			estimate, err := iter.EstimateTotal(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, int64(10), estimate)
+			count, err := iter.CountTotal(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, int64(10), count)
+		})
+	})
+
+	t.Run("malformed query", func(t *testing.T) {
+		t.Parallel()
+		runTest(t, nil, func(pool *pgxpool.Pool) {
+			iter, err := cursoriterator.NewCursorIterator(pool, make([]User, 2), "SELECT * FROM unknown")
+			require.NoError(t, err)
+
+			_, err = iter.EstimateTotal(context.Background())
+			require.ErrorContains(t, err, "unable to explain query")
+			_, err = iter.CountTotal(context.Background())
+			require.ErrorContains(t, err, "unable to count rows")
+		})
+	})
+}
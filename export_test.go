@@ -0,0 +1,12 @@
+package cursoriterator
+
+// this file exports internals for the tests in the cursoriterator_test package.
+
+const (
+	FetchForward  = fetchForward
+	FetchBackward = fetchBackward
+)
+
+func (iter *CursorIterator) BuildFetchQuery(direction fetchDirection, count int) string {
+	return iter.buildFetchQuery(direction, count)
+}
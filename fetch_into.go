@@ -0,0 +1,101 @@
+package cursoriterator
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/pkg/errors"
+)
+
+// FetchInto fetches the next len(dest) rows of the cursor with one FETCH and scans them into dest.
+// It returns the number of scanned rows, 0 (and no error) means there are no more rows.
+// The transaction is started with the first call, if needed.
+//
+// FetchInto is a lower level alternative to Next() that bypasses the internal buffer, so the batch size of the
+// iterator is not used and Value() does not return the fetched rows. Do not mix FetchInto with Next().
+// If the fetch fails the iterator will be closed and the rows that were scanned before the error are in dest.
+// FetchInto can not be used with WithBufferDepth(), WithPgBouncerCompat(), WithCommitPerBatch() and
+// WithSavepoints().
+func (iter *TypedCursorIterator[T]) FetchInto(ctx context.Context, dest []T) (int, error) {
+	if len(dest) == 0 {
+		return 0, errors.New("dest cannot be empty")
+	}
+	iter.mu.Lock()
+	defer iter.mu.Unlock()
+	return iter.fetchInto(ctx, scanDestinations(reflect.ValueOf(dest)))
+}
+
+// fetchInto fetches len(destinations) rows into destinations, see FetchInto().
+func (iter *CursorIterator) fetchInto(ctx context.Context, destinations []interface{}) (int, error) {
+	switch {
+	case iter.bufferDepth > 0:
+		return 0, errors.New("fetch into cannot be used with buffer depth")
+	case iter.keyset.column != "":
+		return 0, errors.New("fetch into cannot be used with pgbouncer compat")
+	case iter.commit.column != "":
+		return 0, errors.New("fetch into cannot be used with commit per batch")
+	case iter.savepoint.enabled:
+		return 0, errors.New("fetch into cannot be used with savepoints")
+	case iter.valuesPos == -1:
+		return 0, iter.err
+	}
+	if iter.valuesPos == -2 {
+		if err := iter.begin(ctx); err != nil {
+			iter.err = err
+			return 0, err
+		}
+		// the internal buffer is not filled, a Next() call must not return its values
+		iter.valuesPos = 0
+		iter.valuesMaxPos = 0
+	}
+
+	n, err := iter.scanInto(ctx, destinations)
+	if err != nil {
+		err = iter.formatError(PhaseFetch, err)
+		iter.close(ctx)
+		iter.err = err
+		iter.notifyClosed(ctx)
+		return n, err
+	}
+	if n == 0 {
+		iter.close(ctx)
+		iter.notifyClosed(ctx)
+		return 0, iter.err
+	}
+	iter.position += int64(n)
+	iter.fetchedRows += int64(n)
+	iter.hasMore = n >= len(destinations)
+	return n, nil
+}
+
+// scanInto fetches len(destinations) rows and scans them into destinations.
+func (iter *CursorIterator) scanInto(ctx context.Context, destinations []interface{}) (int, error) {
+	rows, err := iter.tx.Query(ctx, iter.buildFetchQuery(fetchForward, len(destinations)), iter.fetchArgs()...)
+	if err != nil {
+		return 0, fetchError(err)
+	}
+	defer rows.Close()
+
+	n := 0
+	scanner := pgxscan.NewRowScanner(rows)
+	for rows.Next() {
+		if n >= len(destinations) {
+			return n, errors.New("database returned more rows than expected")
+		}
+		if n > 0 && n%scanContextCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return n, err
+			}
+		}
+		if err := scanRow(iter.scanMode, scanner, rows, destinations[n]); err != nil {
+			return n, scanError(err, rows, n, destinations[n])
+		}
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return n, fetchError(err)
+	}
+	iter.lastCommandTag = rows.CommandTag()
+	return n, nil
+}
@@ -0,0 +1,121 @@
+package cursoriterator_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	cursoriterator "github.com/Eun/go-pgx-cursor-iterator/v2"
+)
+
+func TestFetchInto(t *testing.T) {
+	t.Parallel()
+
+	for _, size := range []int{1, 2, 3, 5, 20} {
+		size := size
+		t.Run(fmt.Sprint(size), func(t *testing.T) {
+			t.Parallel()
+			connector, users := newUsersConnector(10)
+			// the batch size of the iterator is not used by FetchInto
+			iter, err := cursoriterator.NewTypedCursorIterator[User](connector, 4, "SELECT * FROM users")
+			require.NoError(t, err)
+
+			var result []User
+			dest := make([]User, size)
+			for {
+				n, err := iter.FetchInto(context.Background(), dest)
+				require.NoError(t, err)
+				if n == 0 {
+					break
+				}
+				require.LessOrEqual(t, n, size)
+				result = append(result, dest[:n]...)
+			}
+			require.Equal(t, users, result)
+			require.False(t, iter.HasMore())
+			require.False(t, iter.Next(context.Background()))
+
+			n, err := iter.FetchInto(context.Background(), dest)
+			require.NoError(t, err)
+			require.Equal(t, 0, n)
+			require.NoError(t, iter.Close(context.Background()))
+		})
+	}
+}
+
+func TestFetchIntoVaryingSizes(t *testing.T) {
+	t.Parallel()
+	connector, users := newUsersConnector(10)
+	iter, err := cursoriterator.NewTypedCursorIterator[*User](connector, 2, "SELECT * FROM users")
+	require.NoError(t, err)
+	defer iter.Close(context.Background())
+
+	var result []User
+	for _, size := range []int{3, 1, 4, 5} {
+		dest := make([]*User, size)
+		n, err := iter.FetchInto(context.Background(), dest)
+		require.NoError(t, err)
+		for _, user := range dest[:n] {
+			result = append(result, *user)
+		}
+	}
+	require.Equal(t, users, result)
+	require.Equal(t, 0, iter.ValueIndex())
+}
+
+func TestFetchIntoErrors(t *testing.T) {
+	t.Parallel()
+
+	t.Run("empty dest", func(t *testing.T) {
+		t.Parallel()
+		connector, _ := newUsersConnector(1)
+		iter, err := cursoriterator.NewTypedCursorIterator[User](connector, 2, "SELECT * FROM users")
+		require.NoError(t, err)
+		_, err = iter.FetchInto(context.Background(), nil)
+		require.EqualError(t, err, "dest cannot be empty")
+	})
+
+	t.Run("buffer depth", func(t *testing.T) {
+		t.Parallel()
+		connector, _ := newUsersConnector(1)
+		iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+			connector,
+			2,
+			[]cursoriterator.Option{cursoriterator.WithBufferDepth(1)},
+			"SELECT * FROM users",
+		)
+		require.NoError(t, err)
+		_, err = iter.FetchInto(context.Background(), make([]User, 1))
+		require.EqualError(t, err, "fetch into cannot be used with buffer depth")
+	})
+
+	t.Run("fetch error", func(t *testing.T) {
+		t.Parallel()
+		connector, users := newUsersConnector(10)
+		connector.FetchErr = func(fetch int) error {
+			if fetch == 2 {
+				return errors.New("connection reset")
+			}
+			return nil
+		}
+		iter, err := cursoriterator.NewTypedCursorIterator[User](connector, 2, "SELECT * FROM users")
+		require.NoError(t, err)
+
+		dest := make([]User, 3)
+		n, err := iter.FetchInto(context.Background(), dest)
+		require.NoError(t, err)
+		require.Equal(t, users[:3], dest[:n])
+
+		_, err = iter.FetchInto(context.Background(), dest)
+		require.EqualError(t, err, "unable to fetch rows: connection reset")
+		require.EqualError(t, iter.Error(), "unable to fetch rows: connection reset")
+
+		// the iterator is closed
+		_, err = iter.FetchInto(context.Background(), dest)
+		require.EqualError(t, err, "unable to fetch rows: connection reset")
+		require.Error(t, iter.Close(context.Background()))
+	})
+}
@@ -0,0 +1,48 @@
+package cursoriterator
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Placeholders of the template of WithFetchTemplate().
+const (
+	fetchTemplateCount     = "{{.Count}}"
+	fetchTemplateCursor    = "{{.Cursor}}"
+	fetchTemplateDirection = "{{.Direction}}"
+)
+
+// WithFetchTemplate replaces the statement that fetches the rows (FETCH FORWARD n IN cursor), which can be used as an
+// escape hatch for databases that are compatible with PostgreSQL but use a slightly different cursor syntax.
+// The template must contain the placeholders {{.Count}} (the amount of rows to fetch) and {{.Cursor}} (the quoted name
+// of the cursor), the optional placeholder {{.Direction}} is replaced with FORWARD or BACKWARD.
+// The placeholders are replaced as they are, the template is not a text/template.
+//
+// Example Usage:
+//
+//	iter, err := NewCursorIteratorWithOptions(pool, values, []Option{WithFetchTemplate("FETCH {{.Count}} FROM {{.Cursor}}")}, query)
+func WithFetchTemplate(tmpl string) Option {
+	return func(iter *CursorIterator) error {
+		if tmpl == "" {
+			return errors.New("fetch template cannot be empty")
+		}
+		for _, placeholder := range []string{fetchTemplateCount, fetchTemplateCursor} {
+			if !strings.Contains(tmpl, placeholder) {
+				return errors.Errorf("fetch template must contain %s", placeholder)
+			}
+		}
+		iter.fetchTemplate = tmpl
+		return nil
+	}
+}
+
+// executeFetchTemplate returns the statement of WithFetchTemplate() to fetch count rows in the given direction.
+func (iter *CursorIterator) executeFetchTemplate(direction fetchDirection, count int) string {
+	return strings.NewReplacer(
+		fetchTemplateCount, strconv.Itoa(count),
+		fetchTemplateCursor, iter.cursorIdentifier(),
+		fetchTemplateDirection, direction.String(),
+	).Replace(iter.fetchTemplate)
+}
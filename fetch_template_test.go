@@ -0,0 +1,71 @@
+package cursoriterator_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+
+	cursoriterator "github.com/Eun/go-pgx-cursor-iterator/v2"
+)
+
+func TestWithFetchTemplate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("custom template", func(t *testing.T) {
+		t.Parallel()
+		connector, users := newUsersConnector(3)
+		values := make([]User, 2)
+		iter, err := cursoriterator.NewCursorIteratorWithOptions(
+			connector,
+			values,
+			[]cursoriterator.Option{cursoriterator.WithFetchTemplate("FETCH {{.Count}} IN {{.Cursor}}")},
+			"SELECT * FROM users",
+		)
+		require.NoError(t, err)
+		expectValues(t, iter, values, users...)
+		require.NoError(t, iter.Close(context.Background()))
+
+		fetch := `FETCH 2 IN "` + iter.CursorName() + `"`
+		require.Equal(t, []string{fetch, fetch, fetch}, connector.Statements()[2:5])
+	})
+
+	t.Run("direction", func(t *testing.T) {
+		t.Parallel()
+		iter, err := cursoriterator.NewCursorIteratorWithOptions(
+			&pgxpool.Pool{},
+			make([]User, 2),
+			[]cursoriterator.Option{
+				cursoriterator.WithCursorName("users_cursor"),
+				cursoriterator.WithFetchTemplate("FETCH {{.Direction}} {{.Count}} FROM {{.Cursor}} /* {{.Count}} */"),
+			},
+			"SELECT * FROM users",
+		)
+		require.NoError(t, err)
+		require.Equal(t, `FETCH FORWARD 10 FROM "users_cursor" /* 10 */`, iter.BuildFetchQuery(cursoriterator.FetchForward, 10))
+		require.Equal(t, `FETCH BACKWARD 1 FROM "users_cursor" /* 1 */`, iter.BuildFetchQuery(cursoriterator.FetchBackward, 1))
+	})
+
+	t.Run("invalid template", func(t *testing.T) {
+		t.Parallel()
+		tests := []struct {
+			tmpl string
+			err  string
+		}{
+			{"", "fetch template cannot be empty"},
+			{"FETCH 10 IN {{.Cursor}}", "fetch template must contain {{.Count}}"},
+			{"FETCH {{.Count}} IN users_cursor", "fetch template must contain {{.Cursor}}"},
+			{"FETCH {{ .Count }} IN {{.Cursor}}", "fetch template must contain {{.Count}}"},
+		}
+		for _, test := range tests {
+			_, err := cursoriterator.NewCursorIteratorWithOptions(
+				&pgxpool.Pool{},
+				make([]User, 2),
+				[]cursoriterator.Option{cursoriterator.WithFetchTemplate(test.tmpl)},
+				"SELECT * FROM users",
+			)
+			require.EqualError(t, err, test.err)
+		}
+	})
+}
@@ -0,0 +1,58 @@
+package cursoriterator_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	cursoriterator "github.com/Eun/go-pgx-cursor-iterator/v2"
+	"github.com/Eun/go-pgx-cursor-iterator/v2/cursoriteratortest"
+)
+
+func FuzzCursorIterator(f *testing.F) {
+	f.Add(uint8(0), uint8(1))
+	f.Add(uint8(5), uint8(1))
+	f.Add(uint8(5), uint8(2))
+	f.Add(uint8(5), uint8(5))
+	f.Add(uint8(5), uint8(6))
+	f.Add(uint8(100), uint8(7))
+
+	f.Fuzz(func(t *testing.T, rowCount, batchSize uint8) {
+		if batchSize == 0 {
+			t.Skip()
+		}
+		users := make([]User, rowCount)
+		rows := make([][]interface{}, rowCount)
+		for i := range users {
+			users[i] = User{ID: i + 1, Name: fmt.Sprint("user", i+1)}
+			rows[i] = []interface{}{users[i].ID, users[i].Name}
+		}
+		connector := cursoriteratortest.NewConnector([]string{"id", "name"}, rows...)
+		ctx := context.Background()
+
+		values := make([]User, batchSize)
+		iter, err := cursoriterator.NewCursorIterator(connector, values, "SELECT * FROM users")
+		require.NoError(t, err)
+		got := make([]User, 0, rowCount)
+		for iter.Next(ctx) {
+			got = append(got, values[iter.ValueIndex()])
+		}
+		require.NoError(t, iter.Error())
+		require.False(t, iter.Next(ctx))
+		require.Equal(t, -1, iter.ValueIndex())
+		require.NoError(t, iter.Close(ctx))
+		require.Equal(t, users, got)
+
+		typed, err := cursoriterator.NewTypedCursorIterator[User](connector, int(batchSize), "SELECT * FROM users")
+		require.NoError(t, err)
+		got = got[:0]
+		for typed.Next(ctx) {
+			got = append(got, typed.Value())
+		}
+		require.NoError(t, typed.Error())
+		require.NoError(t, typed.Close(ctx))
+		require.Equal(t, users, got)
+	})
+}
@@ -0,0 +1,209 @@
+package cursoriterator
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+)
+
+// keysetState is the state of WithPgBouncerCompat().
+type keysetState struct {
+	// column is the key column, the keyset pagination is used if it is set.
+	column string
+	// started reports whether a batch was fetched, value is the key of the last row of the batch.
+	started bool
+	value   interface{}
+}
+
+// reset forgets the position of the keyset pagination, so the next batch starts at the first row.
+func (s *keysetState) reset() {
+	s.started = false
+	s.value = nil
+}
+
+// WithPgBouncerCompat replaces the cursor with keyset pagination, for connection poolers like PgBouncer in
+// transaction pooling mode, where a cursor can not be used across statements.
+// Every batch is fetched with a separate short transaction:
+//
+//	SELECT * FROM (query) AS keyset WHERE keyColumn > $n ORDER BY keyColumn LIMIT batchSize
+//
+// keyColumn must be part of the query's result and must be unique, otherwise rows will be skipped. The rows are
+// ordered by keyColumn, an ORDER BY of the query or WithOrderBy() has no effect. Since every batch uses its own
+// transaction the result is not a consistent snapshot, and options that configure the cursor (e.g. WithScroll())
+// are not applied.
+// WithPgBouncerCompat can not be combined with WithBufferDepth(), WithSkipScanErrors(), WithAdvisoryLock(),
+// WithMaterialize(), WithStopOnNotify(), WithSnapshot() and WithStatementTimeout().
+func WithPgBouncerCompat(keyColumn string) Option {
+	return func(iter *CursorIterator) error {
+		if keyColumn == "" {
+			return errors.New("key column cannot be empty")
+		}
+		iter.keyset.column = keyColumn
+		return nil
+	}
+}
+
+// validateKeyset checks whether the options that were applied can be used together with WithPgBouncerCompat().
+func (iter *CursorIterator) validateKeyset() error {
+	if iter.keyset.column == "" {
+		return nil
+	}
+	switch {
+	case iter.bufferDepth > 0:
+		return errors.New("pgbouncer compat cannot be used with buffer depth")
+	case iter.skipScanErrors != nil:
+		return errors.New("pgbouncer compat cannot be used with skip scan errors")
+	case iter.lockRows != nil:
+		return errors.New("pgbouncer compat cannot be used with advisory locks")
+	case iter.insensitive:
+		return errors.New("pgbouncer compat cannot be used with insensitive")
+	case iter.materialization.table != "":
+		return errors.New("pgbouncer compat cannot be used with materialize")
+	case iter.stopChannel != "":
+		return errors.New("pgbouncer compat cannot be used with stop on notify")
+	case iter.snapshotID != "":
+		return errors.New("pgbouncer compat cannot be used with snapshot")
+	case iter.statementTimeout > 0:
+		return errors.New("pgbouncer compat cannot be used with statement timeout")
+	}
+	return nil
+}
+
+// keysetQuery returns the query and the arguments to fetch the next count rows with keyset pagination.
+func (iter *CursorIterator) keysetQuery(count int) (string, []interface{}) {
+	query, args := iter.cursorQuery()
+	column := pgx.Identifier{iter.keyset.column}.Sanitize()
+	where := ""
+	if iter.keyset.started {
+		args = append(args[:len(args):len(args)], iter.keyset.value)
+		where = fmt.Sprintf(" WHERE %s > $%d", column, len(args))
+	}
+	return fmt.Sprintf("SELECT * FROM (%s) AS keyset%s ORDER BY %s LIMIT %d", query, where, column, count), args
+}
+
+// fetchKeysetRows fetches the next chunk of rows with keyset pagination, see WithPgBouncerCompat().
+func (iter *CursorIterator) fetchKeysetRows(ctx context.Context) {
+	fetchSize := iter.fetchSize
+	if iter.limit > 0 {
+		// do not fetch more rows than allowed by WithLimit()
+		remaining := iter.limit - iter.fetchedRows
+		if remaining <= 0 {
+			iter.finishKeyset()
+			return
+		}
+		if remaining < int64(fetchSize) {
+			fetchSize = int(remaining)
+		}
+	}
+
+	tx, err := iter.beginTx(ctx)
+	if err != nil {
+		if isPoolExhausted(iter.connector, err) {
+			err = fmt.Errorf("%w: %w", ErrPoolExhausted, err)
+		}
+		iter.err = errors.Wrap(err, "unable to start transaction")
+		return
+	}
+	// the transaction only reads, so rolling back is fine
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	query, args := iter.keysetQuery(fetchSize)
+	rows, err := tx.Query(ctx, query, append(iter.fetchArgs(), args...)...)
+	if err != nil {
+		iter.err = errors.Wrap(err, "unable to fetch rows")
+		return
+	}
+	defer rows.Close()
+
+	keyIndex := -1
+	for i, field := range rows.FieldDescriptions() {
+		if field.Name == iter.keyset.column {
+			keyIndex = i
+			break
+		}
+	}
+	if keyIndex == -1 {
+		iter.finishKeyset()
+		iter.err = errors.Errorf("key column %q is not part of the result", iter.keyset.column)
+		return
+	}
+
+	n := 0
+	var lastKey interface{}
+	scanner := pgxscan.NewRowScanner(rows)
+	for rows.Next() {
+		if n >= fetchSize {
+			iter.finishKeyset()
+			iter.err = errors.New("database returned more rows than expected")
+			return
+		}
+		if n > 0 && n%scanContextCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				iter.err = err
+				return
+			}
+		}
+		values, err := rows.Values()
+		if err != nil {
+			iter.finishKeyset()
+			iter.err = errors.Wrap(err, "unable to read key column")
+			return
+		}
+		lastKey = values[keyIndex]
+		if n == 0 {
+			// the last batch gets overwritten
+			iter.snapshotLen = 0
+			if err := iter.checkSchema(rows.FieldDescriptions()); err != nil {
+				iter.finishKeyset()
+				iter.err = err
+				return
+			}
+		}
+		if err := scanRow(iter.scanMode, scanner, rows, iter.values[n]); err != nil {
+			iter.partialLen = n
+			iter.finishKeyset()
+			iter.err = scanError(err, rows, n, iter.values[n])
+			return
+		}
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		if isContextError(err) {
+			iter.err = err
+			return
+		}
+		iter.err = errors.Wrap(err, "unable to fetch rows")
+		return
+	}
+	iter.lastCommandTag = rows.CommandTag()
+
+	if n == 0 {
+		iter.finishKeyset()
+		return
+	}
+	iter.keyset.started = true
+	iter.keyset.value = lastKey
+	iter.position += int64(n)
+	if iter.afterFetch != nil {
+		if err := iter.afterFetch(n); err != nil {
+			iter.finishKeyset()
+			iter.err = errors.Wrap(err, "after fetch hook failed")
+			return
+		}
+	}
+	iter.fetchedRows += int64(n)
+	iter.hasMore = n >= fetchSize && (iter.limit == 0 || iter.fetchedRows < iter.limit)
+	iter.valuesPos = 0
+	iter.valuesMaxPos = n
+}
+
+// finishKeyset ends a keyset pagination, there is no transaction that needs to be closed.
+func (iter *CursorIterator) finishKeyset() {
+	iter.hasMore = false
+	iter.valuesPos = -1
+}
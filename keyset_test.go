@@ -0,0 +1,197 @@
+package cursoriterator_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+
+	cursoriterator "github.com/Eun/go-pgx-cursor-iterator/v2"
+	"github.com/Eun/go-pgx-cursor-iterator/v2/cursoriteratortest"
+)
+
+// newKeysetConnector simulates a database without cursors, it answers the keyset queries of WithPgBouncerCompat()
+// with the rows that have a bigger id (the first column) than the key argument.
+func newKeysetConnector(columns []string, rows [][]interface{}) *cursoriteratortest.Connector {
+	connector := cursoriteratortest.NewConnector(columns)
+	connector.OnQuery = func(_ context.Context, _ cursoriteratortest.QueryFunc, sql string, args ...interface{}) (pgx.Rows, error) {
+		var limit int
+		if _, err := fmt.Sscanf(sql[strings.LastIndex(sql, " LIMIT "):], " LIMIT %d", &limit); err != nil {
+			return nil, err
+		}
+		after := 0
+		if strings.Contains(sql, " WHERE ") {
+			after = args[len(args)-1].(int)
+		}
+		var result [][]interface{}
+		for _, row := range rows {
+			if row[0].(int) > after && len(result) < limit {
+				result = append(result, row)
+			}
+		}
+		return cursoriteratortest.NewRows(columns, result...), nil
+	}
+	return connector
+}
+
+// keysetQueries returns the keyset queries that were run on the connector.
+func keysetQueries(connector *cursoriteratortest.Connector) []string {
+	var queries []string
+	for _, sql := range connector.Statements() {
+		if strings.HasPrefix(sql, "SELECT ") {
+			queries = append(queries, sql)
+		}
+	}
+	return queries
+}
+
+func TestWithPgBouncerCompat(t *testing.T) {
+	t.Parallel()
+
+	columns := []string{"id", "name"}
+	var rows [][]interface{}
+	for i := 1; i <= 7; i++ {
+		rows = append(rows, []interface{}{i, fmt.Sprintf("user%d", i)})
+	}
+
+	iterate := func(t *testing.T, connector cursoriterator.PgxConnector, options ...cursoriterator.Option) []User {
+		iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](connector, 3, options, "SELECT * FROM users")
+		require.NoError(t, err)
+		var users []User
+		for iter.Next(context.Background()) {
+			users = append(users, iter.Value())
+		}
+		require.NoError(t, iter.Error())
+		require.False(t, iter.HasMore())
+		require.NoError(t, iter.Close(context.Background()))
+		return users
+	}
+
+	t.Run("same result as cursor", func(t *testing.T) {
+		t.Parallel()
+		connector := newKeysetConnector(columns, rows)
+		users := iterate(t, connector, cursoriterator.WithPgBouncerCompat("id"))
+		require.Equal(t, iterate(t, cursoriteratortest.NewConnector(columns, rows...)), users)
+		require.Len(t, users, 7)
+
+		require.Equal(t, []string{
+			`SELECT * FROM (SELECT * FROM users) AS keyset ORDER BY "id" LIMIT 3`,
+			`SELECT * FROM (SELECT * FROM users) AS keyset WHERE "id" > $1 ORDER BY "id" LIMIT 3`,
+			`SELECT * FROM (SELECT * FROM users) AS keyset WHERE "id" > $1 ORDER BY "id" LIMIT 3`,
+			`SELECT * FROM (SELECT * FROM users) AS keyset WHERE "id" > $1 ORDER BY "id" LIMIT 3`,
+		}, keysetQueries(connector))
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		t.Parallel()
+		connector := newKeysetConnector(columns, rows)
+		users := iterate(t, connector, cursoriterator.WithPgBouncerCompat("id"), cursoriterator.WithLimit(4))
+		require.Equal(t, []User{{1, "user1"}, {2, "user2"}, {3, "user3"}, {4, "user4"}}, users)
+	})
+
+	t.Run("close", func(t *testing.T) {
+		t.Parallel()
+		connector := newKeysetConnector(columns, rows)
+		iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+			connector,
+			3,
+			[]cursoriterator.Option{cursoriterator.WithPgBouncerCompat("id")},
+			"SELECT * FROM users",
+		)
+		require.NoError(t, err)
+		require.True(t, iter.Next(context.Background()))
+		require.NoError(t, iter.Close(context.Background()))
+
+		// there is no transaction between the batches, the buffered rows must not be served after Close
+		require.False(t, iter.Next(context.Background()))
+		require.NoError(t, iter.Error())
+		require.False(t, iter.HasMore())
+		require.Len(t, keysetQueries(connector), 1)
+		<-iter.Done()
+	})
+
+	t.Run("unknown key column", func(t *testing.T) {
+		t.Parallel()
+		connector := newKeysetConnector(columns, rows)
+		iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+			connector,
+			3,
+			[]cursoriterator.Option{cursoriterator.WithPgBouncerCompat("uid")},
+			"SELECT * FROM users",
+		)
+		require.NoError(t, err)
+		require.False(t, iter.Next(context.Background()))
+		require.EqualError(t, iter.Error(), `key column "uid" is not part of the result`)
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		t.Parallel()
+		connector := newKeysetConnector(columns, rows)
+		_, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+			connector, 3, []cursoriterator.Option{cursoriterator.WithPgBouncerCompat("")}, "SELECT * FROM users")
+		require.EqualError(t, err, "key column cannot be empty")
+
+		_, err = cursoriterator.NewTypedCursorIteratorWithOptions[User](
+			connector,
+			3,
+			[]cursoriterator.Option{cursoriterator.WithPgBouncerCompat("id"), cursoriterator.WithBufferDepth(2)},
+			"SELECT * FROM users",
+		)
+		require.EqualError(t, err, "pgbouncer compat cannot be used with buffer depth")
+
+		// these options would be silently dropped, since keyset pagination uses a transaction per batch
+		tests := []struct {
+			option cursoriterator.Option
+			err    string
+		}{
+			{cursoriterator.WithStopOnNotify("stop"), "pgbouncer compat cannot be used with stop on notify"},
+			{cursoriterator.WithSnapshot("00000003-0000001B-1"), "pgbouncer compat cannot be used with snapshot"},
+			{cursoriterator.WithStatementTimeout(time.Second), "pgbouncer compat cannot be used with statement timeout"},
+		}
+		for _, test := range tests {
+			// WithStopOnNotify() requires a PgxAcquirer
+			_, err = cursoriterator.NewTypedCursorIteratorWithOptions[User](
+				&pgxpool.Pool{},
+				3,
+				[]cursoriterator.Option{cursoriterator.WithPgBouncerCompat("id"), test.option},
+				"SELECT * FROM users",
+			)
+			require.EqualError(t, err, test.err)
+		}
+	})
+
+	t.Run("database", func(t *testing.T) {
+		t.Parallel()
+		runTest(
+			t,
+			[]User{
+				{3, "Bob"},
+				{1, "Joe"},
+				{5, "Maria"},
+				{2, "Alice"},
+				{4, "Mike"},
+			},
+			func(pool *pgxpool.Pool) {
+				iterate := func(options ...cursoriterator.Option) []User {
+					iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+						pool, 2, options, "SELECT * FROM users WHERE name <> $1 ORDER BY id", "Mike")
+					require.NoError(t, err)
+					var users []User
+					for iter.Next(context.Background()) {
+						users = append(users, iter.Value())
+					}
+					require.NoError(t, iter.Error())
+					require.NoError(t, iter.Close(context.Background()))
+					return users
+				}
+				cursor := iterate()
+				require.Len(t, cursor, 4)
+				require.Equal(t, cursor, iterate(cursoriterator.WithPgBouncerCompat("id")))
+			})
+	})
+}
@@ -0,0 +1,53 @@
+package cursoriterator
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// NewCursorIteratorLazy works like NewCursorIteratorWithOptions() but defers the validation of the parameters and
+// the options until the first Next() (or Rows()) call, so the construction can not fail. This suits dependency
+// injection frameworks that build the iterator before it is used.
+// A validation error is returned by Error() and Next() returns false.
+//
+// Until Next() was called the iterator has no values, Capacity() and Clone() return an error before.
+func NewCursorIteratorLazy(
+	connector PgxConnector,
+	values interface{},
+	options []Option,
+	query string, args ...interface{},
+) *CursorIterator {
+	iter := newUninitializedIterator(connector, query, args)
+	iter.lazyInit = func(ctx context.Context) error {
+		if connector == nil {
+			return errors.New("connector cannot be nil")
+		}
+		valuesSlice, err := valuesDestinations(values)
+		if err != nil {
+			return err
+		}
+		return iter.initialize(ctx, values, valuesSlice, false, options)
+	}
+	return iter
+}
+
+// errNotInitialized is returned by functions that need the values of an iterator of NewCursorIteratorLazy() before
+// it was initialized.
+var errNotInitialized = errors.New("iterator is not initialized, call Next() first")
+
+// initializeLazy initializes an iterator that was created with NewCursorIteratorLazy().
+// A failed initialization is not retried, its error is returned again.
+func (iter *CursorIterator) initializeLazy(ctx context.Context) error {
+	if iter.lazyInit == nil {
+		return nil
+	}
+	if err := iter.lazyInit(ctx); err != nil {
+		iter.lazyInit = func(context.Context) error {
+			return err
+		}
+		return err
+	}
+	iter.lazyInit = nil
+	return nil
+}
@@ -0,0 +1,108 @@
+package cursoriterator_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	cursoriterator "github.com/Eun/go-pgx-cursor-iterator/v2"
+)
+
+func TestNewCursorIteratorLazy(t *testing.T) {
+	t.Parallel()
+
+	t.Run("iterates", func(t *testing.T) {
+		t.Parallel()
+		connector, users := newUsersConnector(5)
+		values := make([]User, 2)
+		iter := cursoriterator.NewCursorIteratorLazy(connector, values, nil, "SELECT * FROM users")
+		// nothing happened yet
+		require.Empty(t, connector.Statements())
+		expectValues(t, iter, values, users...)
+		require.NoError(t, iter.Close(context.Background()))
+	})
+
+	t.Run("rows", func(t *testing.T) {
+		t.Parallel()
+		connector, users := newUsersConnector(5)
+		iter := cursoriterator.NewCursorIteratorLazy(connector, make([]User, 2), nil, "SELECT * FROM users")
+		rows := iter.Rows(context.Background())
+		var result []User
+		for rows.Next() {
+			var user User
+			require.NoError(t, rows.Scan(&user.ID, &user.Name))
+			result = append(result, user)
+		}
+		require.NoError(t, rows.Err())
+		require.Equal(t, users, result)
+	})
+
+	t.Run("not initialized", func(t *testing.T) {
+		t.Parallel()
+		connector, users := newUsersConnector(3)
+		values := make([]User, 2)
+		iter := cursoriterator.NewCursorIteratorLazy(connector, values, nil, "SELECT * FROM users")
+		_, err := iter.Capacity()
+		require.EqualError(t, err, "iterator is not initialized, call Next() first")
+		clone, err := iter.Clone()
+		require.EqualError(t, err, "iterator is not initialized, call Next() first")
+		require.Nil(t, clone)
+
+		require.True(t, iter.Next(context.Background()))
+		requireCapacity(t, iter, 2)
+		clone, err = iter.Clone()
+		require.NoError(t, err)
+		require.NoError(t, clone.Close(context.Background()))
+		expectValues(t, iter, values, users[1:]...)
+		require.NoError(t, iter.Close(context.Background()))
+	})
+
+	t.Run("deferred validation", func(t *testing.T) {
+		t.Parallel()
+		connector, _ := newUsersConnector(5)
+		var array [2]User
+		tests := []struct {
+			name      string
+			connector cursoriterator.PgxConnector
+			values    interface{}
+			options   []cursoriterator.Option
+			err       string
+		}{
+			{"nil connector", nil, make([]User, 2), nil, "connector cannot be nil"},
+			{"nil values", connector, nil, nil, "values cannot be nil"},
+			{"no slice", connector, User{}, nil, "values must be a slice, got struct"},
+			{
+				"array",
+				connector,
+				array,
+				nil,
+				"values must be a slice, got array (pass values[:] or create the slice with make)",
+			},
+			{"empty slice", connector, []User{}, nil, "values must have a capacity bigger than 0"},
+			{
+				"invalid option",
+				connector,
+				make([]User, 2),
+				[]cursoriterator.Option{cursoriterator.WithBufferDepth(-1)},
+				"buffer depth must be bigger than 0",
+			},
+		}
+		for _, test := range tests {
+			test := test
+			t.Run(test.name, func(t *testing.T) {
+				t.Parallel()
+				iter := cursoriterator.NewCursorIteratorLazy(test.connector, test.values, test.options, "SELECT * FROM users")
+				require.NotNil(t, iter)
+				require.NoError(t, iter.Error())
+
+				require.False(t, iter.Next(context.Background()))
+				require.EqualError(t, iter.Error(), test.err)
+				// the validation is not retried
+				ok, err := iter.NextErr(context.Background())
+				require.False(t, ok)
+				require.EqualError(t, err, test.err)
+			})
+		}
+	})
+}
@@ -0,0 +1,39 @@
+package cursoriterator
+
+import (
+	"log"
+	"runtime"
+)
+
+// WithLeakDetection reports iterators that are garbage collected while their transaction is still open,
+// which means Close() was not called and a connection is still checked out.
+// report will be called with the query of the leaked iterator, if report is nil a warning will be logged
+// using the standard logger.
+//
+// The detection uses a finalizer, so it is only intended for development. Notice that finalizers
+// are not guaranteed to run, e.g. if the iterator is referenced by one of its own hooks.
+func WithLeakDetection(report func(query string)) Option {
+	return func(iter *CursorIterator) error {
+		if report == nil {
+			report = func(query string) {
+				log.Printf("cursoriterator: iterator for query %q was not closed", query)
+			}
+		}
+		iter.leakReport = report
+		return nil
+	}
+}
+
+// detectLeak is set as finalizer if WithLeakDetection() is used.
+func (iter *CursorIterator) detectLeak() {
+	if iter.tx != nil {
+		iter.leakReport(iter.query)
+	}
+}
+
+// setLeakFinalizer registers the finalizer, it must be called with the pointer returned by the allocation.
+func (iter *CursorIterator) setLeakFinalizer() {
+	if iter.leakReport != nil {
+		runtime.SetFinalizer(iter, (*CursorIterator).detectLeak)
+	}
+}
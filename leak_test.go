@@ -0,0 +1,67 @@
+package cursoriterator_test
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	cursoriterator "github.com/Eun/go-pgx-cursor-iterator/v2"
+	"github.com/Eun/go-pgx-cursor-iterator/v2/cursoriteratortest"
+)
+
+func TestWithLeakDetection(t *testing.T) {
+	t.Parallel()
+
+	newIterator := func(t *testing.T, report func(string), closeIterator bool) {
+		connector := cursoriteratortest.NewConnector(
+			[]string{"id", "name"},
+			[]interface{}{1, "Joe"},
+			[]interface{}{2, "Alice"},
+		)
+		iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+			connector,
+			1,
+			[]cursoriterator.Option{cursoriterator.WithLeakDetection(report)},
+			"SELECT * FROM users",
+		)
+		require.NoError(t, err)
+		require.True(t, iter.Next(context.Background()))
+		if closeIterator {
+			require.NoError(t, iter.Close(context.Background()))
+		}
+	}
+
+	waitForReport := func(reported chan string) (string, bool) {
+		for i := 0; i < 50; i++ {
+			runtime.GC()
+			select {
+			case query := <-reported:
+				return query, true
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+		return "", false
+	}
+
+	t.Run("leaked", func(t *testing.T) {
+		t.Parallel()
+		reported := make(chan string, 1)
+		newIterator(t, func(query string) { reported <- query }, false)
+
+		query, ok := waitForReport(reported)
+		require.True(t, ok)
+		require.Equal(t, "SELECT * FROM users", query)
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		t.Parallel()
+		reported := make(chan string, 1)
+		newIterator(t, func(query string) { reported <- query }, true)
+
+		_, ok := waitForReport(reported)
+		require.False(t, ok)
+	})
+}
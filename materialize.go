@@ -0,0 +1,129 @@
+package cursoriterator
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pkg/errors"
+)
+
+// materializationState is the state of WithMaterialize().
+type materializationState struct {
+	// table is the name of the temporary table, the query is materialized if it is set.
+	table string
+	// done reports whether the table was created.
+	done bool
+	// conn is the connection the table was created on, if the connector implements PgxAcquirer.
+	conn *pgxpool.Conn
+}
+
+// validateMaterialize checks whether the options that were applied can be used together with WithMaterialize().
+// The temporary table is created in a transaction that is configured like the transaction of the cursor, so it must
+// not be read only.
+func (iter *CursorIterator) validateMaterialize() error {
+	if iter.materialization.table == "" {
+		return nil
+	}
+	switch {
+	case iter.readOnly:
+		return errors.New("materialize cannot be used with read only")
+	case iter.txOptions != nil && iter.txOptions.AccessMode == pgx.ReadOnly:
+		return errors.New("materialize cannot be used with deferrable")
+	}
+	return nil
+}
+
+// materialize stores the result of the query in the temporary table of WithMaterialize(), using a separate
+// transaction that is committed right away, so the transaction of the cursor does not hold the locks of the query.
+// Temporary tables are only visible in their session: if the connector implements PgxAcquirer a connection is
+// acquired and used for all transactions until the table was dropped, see dropMaterialized().
+func (iter *CursorIterator) materialize(ctx context.Context) error {
+	if acquirer, ok := iter.connector.(PgxAcquirer); ok {
+		conn, err := acquirer.Acquire(ctx)
+		if err != nil {
+			return errors.Wrap(err, "unable to acquire connection for materialize")
+		}
+		iter.materialization.conn = conn
+	}
+
+	tx, err := iter.beginTx(ctx)
+	if err != nil {
+		iter.releaseMaterializedConn()
+		return errors.Wrap(err, "unable to start transaction")
+	}
+	iter.tx = tx
+	err = iter.prepareTransaction(ctx)
+	if err == nil {
+		query, args := iter.cursorQuery()
+		createQuery := fmt.Sprintf("CREATE TEMPORARY TABLE %s AS %s", iter.materializedIdentifier(), query)
+		if _, err = tx.Exec(ctx, createQuery, args...); err != nil {
+			err = errors.Wrap(err, "unable to materialize query")
+		}
+	}
+	if err == nil {
+		err = errors.Wrap(tx.Commit(ctx), "unable to commit materialized query")
+	}
+	if err != nil {
+		_ = tx.Rollback(ctx)
+	}
+	iter.unregisterNoticeHandler()
+	iter.tx = nil
+	if err != nil {
+		iter.releaseMaterializedConn()
+		return err
+	}
+	iter.materialization.done = true
+	return nil
+}
+
+// materializedIdentifier returns the quoted name of the temporary table that can be used in sql statements.
+func (iter *CursorIterator) materializedIdentifier() string {
+	return pgx.Identifier{iter.materialization.table}.Sanitize()
+}
+
+// dropMaterialized drops the temporary table of WithMaterialize() and releases the acquired connection.
+func (iter *CursorIterator) dropMaterialized(ctx context.Context) error {
+	if !iter.materialization.done {
+		return nil
+	}
+	iter.materialization.done = false
+	err := iter.dropMaterializedTable(ctx)
+	if err != nil && iter.materialization.conn != nil {
+		// closing the session drops the table, so the connection can not be reused with it
+		_ = iter.materialization.conn.Conn().Close(ctx)
+	}
+	iter.releaseMaterializedConn()
+	return err
+}
+
+func (iter *CursorIterator) dropMaterializedTable(ctx context.Context) error {
+	tx, err := iter.sessionConnector().Begin(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to drop materialized table")
+	}
+	if _, err := tx.Exec(ctx, "DROP TABLE IF EXISTS "+iter.materializedIdentifier()); err != nil {
+		_ = tx.Rollback(ctx)
+		return errors.Wrap(err, "unable to drop materialized table")
+	}
+	return errors.Wrap(tx.Commit(ctx), "unable to drop materialized table")
+}
+
+// releaseMaterializedConn returns the connection acquired by materialize() to the pool.
+func (iter *CursorIterator) releaseMaterializedConn() {
+	if iter.materialization.conn == nil {
+		return
+	}
+	iter.materialization.conn.Release()
+	iter.materialization.conn = nil
+}
+
+// sessionConnector returns the connector the transactions are started with, which is the connection acquired by
+// materialize() as long as the temporary table exists.
+func (iter *CursorIterator) sessionConnector() PgxConnector {
+	if iter.materialization.conn != nil {
+		return iter.materialization.conn
+	}
+	return iter.connector
+}
@@ -0,0 +1,61 @@
+package cursoriterator
+
+import (
+	"sync"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pkg/errors"
+)
+
+// noticeHandlers maps the connections of running iterators to their notice handler.
+var noticeHandlers sync.Map
+
+// DispatchNotice forwards a notice to the handler of the iterator that currently uses the connection.
+// pgx only supports notice handlers that are configured before connecting, so DispatchNotice must be set as
+// OnNotice handler of the connection config to make WithNoticeHandler() work:
+//
+//	config, _ := pgxpool.ParseConfig(connString)
+//	config.ConnConfig.OnNotice = cursoriterator.DispatchNotice
+//	pool, _ := pgxpool.NewWithConfig(ctx, config)
+func DispatchNotice(pgConn *pgconn.PgConn, notice *pgconn.Notice) {
+	if handler, ok := noticeHandlers.Load(pgConn); ok {
+		handler.(func(*pgconn.Notice))(notice)
+	}
+}
+
+// WithNoticeHandler calls fn for every notice (e.g. RAISE NOTICE in a function) that is sent by the database
+// while the iterator uses the connection.
+// The connection must be configured to use DispatchNotice, see DispatchNotice() for details.
+// Notice that this option requires a connector that provides a *pgx.Conn, it does not work with SQLConnector.
+func WithNoticeHandler(fn func(notice *pgconn.Notice)) Option {
+	return func(iter *CursorIterator) error {
+		if fn == nil {
+			return errors.New("notice handler cannot be nil")
+		}
+		iter.noticeHandler = fn
+		return nil
+	}
+}
+
+// registerNoticeHandler routes the notices of the transaction's connection to the notice handler.
+func (iter *CursorIterator) registerNoticeHandler() error {
+	if iter.noticeHandler == nil {
+		return nil
+	}
+	conn := iter.tx.Conn()
+	if conn == nil {
+		return errors.New("notice handler requires a pgx connection")
+	}
+	iter.noticeConn = conn.PgConn()
+	noticeHandlers.Store(iter.noticeConn, iter.noticeHandler)
+	return nil
+}
+
+// unregisterNoticeHandler stops routing the notices of the connection to the notice handler.
+func (iter *CursorIterator) unregisterNoticeHandler() {
+	if iter.noticeConn == nil {
+		return
+	}
+	noticeHandlers.Delete(iter.noticeConn)
+	iter.noticeConn = nil
+}
@@ -0,0 +1,74 @@
+package cursoriterator_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+
+	cursoriterator "github.com/Eun/go-pgx-cursor-iterator/v2"
+	"github.com/Eun/go-pgx-cursor-iterator/v2/cursoriteratortest"
+)
+
+func TestWithNoticeHandler(t *testing.T) {
+	t.Parallel()
+
+	t.Run("requires pgx connection", func(t *testing.T) {
+		t.Parallel()
+		iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+			cursoriteratortest.NewConnector([]string{"id", "name"}),
+			2,
+			[]cursoriterator.Option{cursoriterator.WithNoticeHandler(func(*pgconn.Notice) {})},
+			"SELECT * FROM users",
+		)
+		require.NoError(t, err)
+		require.False(t, iter.Next(context.Background()))
+		require.EqualError(t, iter.Error(), "notice handler requires a pgx connection")
+	})
+
+	t.Run("receives notices", func(t *testing.T) {
+		t.Parallel()
+		runTest(
+			t,
+			[]User{
+				{1, "Joe"},
+				{2, "Alice"},
+			},
+			func(pool *pgxpool.Pool) {
+				_, err := pool.Exec(context.Background(), `
+CREATE FUNCTION notify_user(name varchar) RETURNS varchar AS $$
+BEGIN
+	RAISE NOTICE 'visiting %', name;
+	RETURN name;
+END
+$$ LANGUAGE plpgsql;`)
+				require.NoError(t, err)
+
+				config := pool.Config()
+				config.ConnConfig.OnNotice = cursoriterator.DispatchNotice
+				noticePool, err := pgxpool.NewWithConfig(context.Background(), config)
+				require.NoError(t, err)
+				defer noticePool.Close()
+
+				var notices []string
+				iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+					noticePool,
+					2,
+					[]cursoriterator.Option{cursoriterator.WithNoticeHandler(func(notice *pgconn.Notice) {
+						notices = append(notices, notice.Message)
+					})},
+					"SELECT id, notify_user(name) AS name FROM users ORDER BY id",
+				)
+				require.NoError(t, err)
+
+				expectTypedValues(t, iter,
+					User{1, "Joe"},
+					User{2, "Alice"},
+				)
+				require.NoError(t, iter.Close(context.Background()))
+				require.Equal(t, []string{"visiting Joe", "visiting Alice"}, notices)
+			})
+	})
+}
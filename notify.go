@@ -0,0 +1,111 @@
+package cursoriterator
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pkg/errors"
+)
+
+// PgxAcquirer implements the Acquire() function from the pgxpool package.
+// It is required for options that need a second connection, like WithStopOnNotify().
+type PgxAcquirer interface {
+	Acquire(ctx context.Context) (*pgxpool.Conn, error)
+}
+
+// unlistenTimeout is the time the listener connection has to unlisten before it is released.
+const unlistenTimeout = 5 * time.Second
+
+// WithStopOnNotify stops the iteration gracefully if a notification is sent to channel, e.g. with NOTIFY channel
+// or SELECT pg_notify('channel', 'stop'). The notification is checked between the batches, so the current batch is
+// always consumed completely. Next() returns false, Error() returns nil and the iterator is closed.
+//
+// Postgres delivers notifications to a session only outside of transactions, so a second connection is used to
+// LISTEN, which requires the connector to implement PgxAcquirer (*pgxpool.Pool does).
+func WithStopOnNotify(channel string) Option {
+	return func(iter *CursorIterator) error {
+		if channel == "" {
+			return errors.New("stop channel cannot be empty")
+		}
+		if _, ok := iter.connector.(PgxAcquirer); !ok {
+			return errors.Errorf("stop on notify requires a connector that implements PgxAcquirer, got %T", iter.connector)
+		}
+		iter.stopChannel = channel
+		return nil
+	}
+}
+
+// stopListener waits for a notification on a separate connection, see WithStopOnNotify().
+type stopListener struct {
+	conn     *pgxpool.Conn
+	cancel   context.CancelFunc
+	notified chan struct{}
+	stopped  chan struct{}
+}
+
+// startStopListener acquires a connection and listens for the stop notification.
+func (iter *CursorIterator) startStopListener(ctx context.Context) error {
+	if iter.stopChannel == "" || iter.stopListener != nil {
+		// WithCommitPerBatch() declares the cursor again for every batch, the listener keeps running
+		return nil
+	}
+	conn, err := iter.connector.(PgxAcquirer).Acquire(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to acquire connection for stop notifications")
+	}
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{iter.stopChannel}.Sanitize()); err != nil {
+		conn.Release()
+		return errors.Wrap(err, "unable to listen for stop notifications")
+	}
+
+	listenCtx, cancel := context.WithCancel(context.Background())
+	l := &stopListener{
+		conn:     conn,
+		cancel:   cancel,
+		notified: make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go func() {
+		defer close(l.stopped)
+		// only one channel is listened to, so every notification is a stop notification
+		if _, err := conn.Conn().WaitForNotification(listenCtx); err == nil {
+			close(l.notified)
+		}
+	}()
+	iter.stopListener = l
+	return nil
+}
+
+// stopRequested reports whether the stop notification was received.
+func (iter *CursorIterator) stopRequested() bool {
+	if iter.stopListener == nil {
+		return false
+	}
+	select {
+	case <-iter.stopListener.notified:
+		return true
+	default:
+		return false
+	}
+}
+
+// stopStopListener stops listening and releases the connection.
+func (iter *CursorIterator) stopStopListener() {
+	l := iter.stopListener
+	if l == nil {
+		return
+	}
+	iter.stopListener = nil
+	l.cancel()
+	<-l.stopped
+
+	// a canceled wait leaves the connection intact, unlisten so it can be reused by the pool
+	ctx, cancel := context.WithTimeout(context.Background(), unlistenTimeout)
+	defer cancel()
+	if _, err := l.conn.Exec(ctx, "UNLISTEN *"); err != nil {
+		_ = l.conn.Conn().Close(ctx)
+	}
+	l.conn.Release()
+}
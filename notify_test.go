@@ -0,0 +1,80 @@
+package cursoriterator_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+
+	cursoriterator "github.com/Eun/go-pgx-cursor-iterator/v2"
+	"github.com/Eun/go-pgx-cursor-iterator/v2/cursoriteratortest"
+)
+
+func TestWithStopOnNotify(t *testing.T) {
+	t.Parallel()
+
+	t.Run("invalid options", func(t *testing.T) {
+		t.Parallel()
+		_, err := cursoriterator.NewCursorIteratorWithOptions(
+			&pgxpool.Pool{},
+			make([]User, 2),
+			[]cursoriterator.Option{cursoriterator.WithStopOnNotify("")},
+			"SELECT * FROM users",
+		)
+		require.EqualError(t, err, "stop channel cannot be empty")
+
+		_, err = cursoriterator.NewCursorIteratorWithOptions(
+			cursoriteratortest.NewConnector([]string{"id", "name"}),
+			make([]User, 2),
+			[]cursoriterator.Option{cursoriterator.WithStopOnNotify("stop")},
+			"SELECT * FROM users",
+		)
+		require.EqualError(t, err,
+			"stop on notify requires a connector that implements PgxAcquirer, got *cursoriteratortest.Connector")
+	})
+
+	t.Run("stop at the next batch", func(t *testing.T) {
+		t.Parallel()
+		runTest(
+			t,
+			[]User{
+				{1, "Joe"},
+				{2, "Alice"},
+				{3, "Bob"},
+				{4, "Mike"},
+				{5, "Maria"},
+			},
+			func(pool *pgxpool.Pool) {
+				iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+					pool,
+					2,
+					[]cursoriterator.Option{cursoriterator.WithStopOnNotify("stop_export")},
+					"SELECT * FROM users ORDER BY id",
+				)
+				require.NoError(t, err)
+
+				require.True(t, iter.Next(context.Background()))
+				require.Equal(t, User{1, "Joe"}, iter.Value())
+
+				_, err = pool.Exec(context.Background(), "NOTIFY stop_export")
+				require.NoError(t, err)
+				// give the listener time to receive the notification
+				time.Sleep(100 * time.Millisecond)
+
+				// the current batch is consumed completely
+				require.True(t, iter.Next(context.Background()))
+				require.Equal(t, User{2, "Alice"}, iter.Value())
+
+				require.False(t, iter.Next(context.Background()))
+				require.NoError(t, iter.Error())
+				require.NoError(t, iter.Close(context.Background()))
+
+				// the listener connection was returned to the pool
+				require.Eventually(t, func() bool {
+					return pool.Stat().AcquiredConns() == 0
+				}, time.Second, 10*time.Millisecond)
+			})
+	})
+}
@@ -0,0 +1,50 @@
+package cursoriterator
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Observer can be used to observe the database operations of the iterator, e.g. for metrics.
+// All functions are called while the iterator is locked, so they must not call any functions of the iterator.
+type Observer interface {
+	// Declared will be called after the cursor was declared.
+	Declared(ctx context.Context)
+	// FetchStarted will be called before rows are fetched.
+	FetchStarted(ctx context.Context, batchSize int)
+	// FetchCompleted will be called after rows were fetched, rows is the amount of fetched rows.
+	FetchCompleted(ctx context.Context, rows int, d time.Duration, err error)
+	// Closed will be called once, when the iterator was closed or finished.
+	Closed(ctx context.Context, err error)
+}
+
+// WithObserver sets an Observer that will be notified about the database operations of the iterator.
+func WithObserver(observer Observer) Option {
+	return func(iter *CursorIterator) error {
+		if observer == nil {
+			return errors.New("observer cannot be nil")
+		}
+		iter.observer = observer
+		return nil
+	}
+}
+
+// notifyClosed notifies the observer about the closed iterator and closes the Done() channel, but only once.
+func (iter *CursorIterator) notifyClosed(ctx context.Context) {
+	if iter.closeNotified {
+		return
+	}
+	iter.closeNotified = true
+	close(iter.done)
+	iter.observer.Closed(ctx, iter.err)
+}
+
+// nopObserver is the default Observer that does nothing.
+type nopObserver struct{}
+
+func (nopObserver) Declared(context.Context)                                  {}
+func (nopObserver) FetchStarted(context.Context, int)                         {}
+func (nopObserver) FetchCompleted(context.Context, int, time.Duration, error) {}
+func (nopObserver) Closed(context.Context, error)                             {}
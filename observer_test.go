@@ -0,0 +1,73 @@
+package cursoriterator_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+
+	cursoriterator "github.com/Eun/go-pgx-cursor-iterator/v2"
+)
+
+type recordingObserver struct {
+	events []string
+}
+
+func (o *recordingObserver) Declared(context.Context) {
+	o.events = append(o.events, "declared")
+}
+
+func (o *recordingObserver) FetchStarted(_ context.Context, batchSize int) {
+	o.events = append(o.events, fmt.Sprintf("fetch started %d", batchSize))
+}
+
+func (o *recordingObserver) FetchCompleted(_ context.Context, rows int, _ time.Duration, err error) {
+	o.events = append(o.events, fmt.Sprintf("fetch completed %d %v", rows, err))
+}
+
+func (o *recordingObserver) Closed(_ context.Context, err error) {
+	o.events = append(o.events, fmt.Sprintf("closed %v", err))
+}
+
+func TestWithObserver(t *testing.T) {
+	t.Parallel()
+	runTest(
+		t,
+		[]User{
+			{1, "Joe"},
+			{2, "Alice"},
+			{3, "Bob"},
+		},
+		func(pool *pgxpool.Pool) {
+			observer := &recordingObserver{}
+			values := make([]User, 2)
+			iter, err := cursoriterator.NewCursorIteratorWithOptions(
+				pool,
+				values,
+				[]cursoriterator.Option{cursoriterator.WithObserver(observer)},
+				"SELECT * FROM users ORDER BY id",
+			)
+			require.NoError(t, err)
+
+			expectValues(t, iter, values,
+				User{1, "Joe"},
+				User{2, "Alice"},
+				User{3, "Bob"},
+			)
+			require.NoError(t, iter.Close(context.Background()))
+
+			require.Equal(t, []string{
+				"declared",
+				"fetch started 2",
+				"fetch completed 2 <nil>",
+				"fetch started 2",
+				"fetch completed 1 <nil>",
+				"fetch started 2",
+				"fetch completed 0 <nil>",
+				"closed <nil>",
+			}, observer.events)
+		})
+}
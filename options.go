@@ -0,0 +1,432 @@
+package cursoriterator
+
+import (
+	"context"
+	"encoding/hex"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/pkg/errors"
+)
+
+// Option can be used to configure the CursorIterator, see NewCursorIteratorWithOptions().
+type Option func(iter *CursorIterator) error
+
+// WithStatementTimeout sets the postgres statement_timeout for the transaction of the iterator.
+// Unlike a context timeout, which only cancels on the client side, the statement_timeout is
+// enforced by the server.
+func WithStatementTimeout(d time.Duration) Option {
+	return func(iter *CursorIterator) error {
+		if d <= 0 {
+			return errors.New("statement timeout must be bigger than 0")
+		}
+		iter.statementTimeout = d
+		return nil
+	}
+}
+
+// WithApplicationName sets the postgres application_name for the transaction of the iterator,
+// so the session can be identified in pg_stat_activity. It is reset when the transaction ends.
+func WithApplicationName(name string) Option {
+	return func(iter *CursorIterator) error {
+		if name == "" {
+			return errors.New("application name cannot be empty")
+		}
+		iter.applicationName = name
+		return nil
+	}
+}
+
+// adaptiveBatchTargetDuration is the duration a fetch may take before WithAdaptiveBatch() reduces the fetch size.
+const adaptiveBatchTargetDuration = 500 * time.Millisecond
+
+// WithAdaptiveBatch lets the iterator adjust the amount of rows that are fetched with one database call.
+// The iterator starts fetching min rows, doubles the amount on full batches that were fetched fast
+// and halves it when a fetch was slow. The amount will never exceed max,
+// which cannot be bigger than the capacity of the values slice.
+func WithAdaptiveBatch(min, max int) Option {
+	return func(iter *CursorIterator) error {
+		if min <= 0 {
+			return errors.New("min batch size must be bigger than 0")
+		}
+		if max < min {
+			return errors.New("max batch size must be bigger or equal to min batch size")
+		}
+		if max > len(iter.values) {
+			return errors.New("max batch size cannot be bigger than the capacity of values")
+		}
+		iter.adaptiveMinFetchSize = min
+		iter.adaptiveMaxFetchSize = max
+		iter.fetchSize = min
+		return nil
+	}
+}
+
+// WithAfterFetch registers a hook that will be called after each successful fetch with the valid values
+// of the batch. T must match the element type of the values slice.
+// If the hook returns an error, the iteration stops with that error and the iterator will be closed.
+func WithAfterFetch[T any](fn func(values []T) error) Option {
+	return func(iter *CursorIterator) error {
+		if fn == nil {
+			return errors.New("after fetch hook cannot be nil")
+		}
+		values, ok := iter.valuesRef.([]T)
+		if !ok {
+			return errors.Errorf("after fetch hook expects %T, but values is %T", values, iter.valuesRef)
+		}
+		iter.afterFetch = func(n int) error {
+			// use the current buffer, it might have been replaced by WithBufferPool()
+			return fn(iter.valuesRef.([]T)[:n])
+		}
+		return nil
+	}
+}
+
+// WithSkipScanErrors lets the iterator skip rows that could not be scanned instead of aborting the iteration.
+// For every skipped row fn will be called with the (zero based) index of the row in the result and the scan error.
+// The amount of skipped rows can be retrieved with SkippedCount().
+//
+// pgx discards the remaining rows of a batch after a failed scan, therefore the cursor will be declared
+// as SCROLL cursor and will be moved back to the row after the skipped one. Depending on the query
+// a scrollable cursor can be slower than a regular one.
+// fn is called while the iterator is locked, so it must not call any functions of the iterator.
+func WithSkipScanErrors(fn func(rowIndex int64, err error)) Option {
+	return func(iter *CursorIterator) error {
+		if fn == nil {
+			return errors.New("skip scan errors callback cannot be nil")
+		}
+		iter.skipScanErrors = fn
+		return nil
+	}
+}
+
+// WithSnapshot lets the iterator use the snapshot of another transaction, see ExportSnapshot() and pg_export_snapshot().
+// Multiple iterators that use the same snapshot see the same data, which can be used for consistent parallel reads.
+// The transaction of the iterator will be started with the REPEATABLE READ isolation level.
+func WithSnapshot(snapshotID string) Option {
+	return func(iter *CursorIterator) error {
+		if snapshotID == "" {
+			return errors.New("snapshot id cannot be empty")
+		}
+		iter.snapshotID = snapshotID
+		return nil
+	}
+}
+
+// maxIdentifierLength is the maximum length of an identifier in postgres.
+const maxIdentifierLength = 63
+
+// WithCursorName overrides the name of the cursor.
+// By default every iterator uses a random name, so multiple iterators can be used in the same session.
+// Only use this option if you need a predictable name and make sure it is unique within the session.
+func WithCursorName(name string) Option {
+	return func(iter *CursorIterator) error {
+		if name == "" {
+			return errors.New("cursor name cannot be empty")
+		}
+		if len(name) > maxIdentifierLength {
+			return errors.Errorf("cursor name cannot be longer than %d bytes", maxIdentifierLength)
+		}
+		iter.cursorName = name
+		return nil
+	}
+}
+
+// WithMaterialize lets the iterator store the result of the query in a temporary table first
+// and declare the cursor on that table. The query will be fully executed with the first Next() call,
+// in a separate transaction that is committed right away, so the transaction of the cursor only reads the
+// temporary table and does not hold the locks of the query. This trades upfront cost and temporary storage
+// for a shorter lock duration and a stable result that does not depend on the query execution anymore.
+// The temporary table uses a unique name and will be dropped when the iterator is closed.
+// Temporary tables are only visible in their session: if the connector implements PgxAcquirer
+// (*pgxpool.Pool does) a connection is acquired for the whole iteration, otherwise the connector must always
+// use the same connection (like *pgx.Conn does).
+// The temporary table does not keep the order of the query, use WithOrderBy() to order the rows of the cursor.
+// WithMaterialize can not be combined with WithCommitPerBatch(), WithPgBouncerCompat(), WithReadOnly() and
+// WithDeferrable(), since the temporary table can not be created in a read only transaction.
+func WithMaterialize() Option {
+	return func(iter *CursorIterator) error {
+		tableID := uuid.New()
+		iter.materialization.table = "materialized_" + hex.EncodeToString(tableID[:])
+		return nil
+	}
+}
+
+// WithConnectionInit registers a hook that will be called after the transaction was started and before
+// the cursor gets declared. It can be used to configure the session, e.g. with SET LOCAL search_path.
+// If the hook returns an error, the cursor will not be declared and Next() fails with that error.
+func WithConnectionInit(fn func(ctx context.Context, tx pgx.Tx) error) Option {
+	return func(iter *CursorIterator) error {
+		if fn == nil {
+			return errors.New("connection init hook cannot be nil")
+		}
+		iter.connectionInit = fn
+		return nil
+	}
+}
+
+// WithTypeRegistration registers a hook that will be called with the underlying connection of the transaction
+// before the cursor gets declared. Use it to register custom types (enums, domains, composites) on the
+// connection's type map, so columns of these types can be scanned.
+// The hook is called for every new transaction, so it should be safe to call it on the same connection twice.
+// Notice that this option requires a connector that provides a *pgx.Conn, it does not work with SQLConnector.
+func WithTypeRegistration(fn func(ctx context.Context, conn *pgx.Conn) error) Option {
+	return func(iter *CursorIterator) error {
+		if fn == nil {
+			return errors.New("type registration hook cannot be nil")
+		}
+		iter.typeRegistration = fn
+		return nil
+	}
+}
+
+// WithReverseBuffers lets the iterator hand out the rows of each fetched batch in reverse order.
+// Notice that this only reverses the order within a batch, it does not reverse the global order:
+// with a batch size of 2 and the rows 1, 2, 3, 4, 5 the iterator returns 2, 1, 4, 3, 5.
+// Use ORDER BY ... DESC in the query to reverse the global order.
+func WithReverseBuffers() Option {
+	return func(iter *CursorIterator) error {
+		iter.reverseBuffers = true
+		return nil
+	}
+}
+
+// WithResumeFrom resumes a keyset ordered query after the given value.
+// The query will be wrapped into SELECT * FROM (query) AS resume WHERE column > value ORDER BY column,
+// so the iteration continues right after the last processed row, e.g. after a crash or restart.
+// The column must be part of the query's result and should be unique, otherwise rows with the same value
+// as the resume value will be skipped. If WithOrderBy() orders the column in descending order the query continues
+// with the rows that have a smaller value (column < value).
+func WithResumeFrom(column string, value interface{}) Option {
+	return func(iter *CursorIterator) error {
+		if column == "" {
+			return errors.New("resume column cannot be empty")
+		}
+		iter.resumeColumn = column
+		iter.resumeValue = value
+		return nil
+	}
+}
+
+// WithWrapSubquery wraps the query into SELECT * FROM (query) AS _sub before the cursor is declared.
+// This guarantees that the cursor is declared for a plain SELECT, even if the query uses constructs like CTEs
+// or an ORDER BY that should be kept as they are. A trailing semicolon of the query will be removed.
+func WithWrapSubquery() Option {
+	return func(iter *CursorIterator) error {
+		iter.wrapSubquery = true
+		return nil
+	}
+}
+
+// orderByColumnRegexp matches a column name with an optional sort direction, e.g. "created_at DESC".
+var orderByColumnRegexp = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)(?:\s+(?i:(ASC|DESC)))?$`)
+
+// WithOrderBy orders the result of the query by the given columns, each column can be followed by ASC or DESC,
+// e.g. WithOrderBy("created_at DESC", "id").
+// The query will be wrapped into SELECT * FROM (query) AS ordered ORDER BY columns, so an existing ORDER BY
+// of the query will be replaced. If WithResumeFrom() is used the columns replace its ORDER BY column.
+// The columns are validated and quoted, so they are case sensitive.
+func WithOrderBy(columns ...string) Option {
+	return func(iter *CursorIterator) error {
+		if len(columns) == 0 {
+			return errors.New("order by columns cannot be empty")
+		}
+		orderBy := make([]string, len(columns))
+		for i, column := range columns {
+			m := orderByColumnRegexp.FindStringSubmatch(strings.TrimSpace(column))
+			if m == nil {
+				return errors.Errorf("invalid order by column %q", column)
+			}
+			orderBy[i] = pgx.Identifier{m[1]}.Sanitize()
+			if m[2] != "" {
+				orderBy[i] += " " + strings.ToUpper(m[2])
+			}
+		}
+		iter.orderBy = strings.Join(orderBy, ", ")
+		return nil
+	}
+}
+
+// WithLimit stops the iteration after n rows were returned, without modifying the query.
+// The last fetch will be shrunk so that no more than n rows are fetched from the database.
+func WithLimit(n int64) Option {
+	return func(iter *CursorIterator) error {
+		if n <= 0 {
+			return errors.New("limit must be bigger than 0")
+		}
+		iter.limit = n
+		return nil
+	}
+}
+
+// WithMaxBatches is a safety limit that stops the iteration with ErrMaxBatchesExceeded if more than n batches
+// would be fetched, e.g. if a query returns far more rows than expected.
+// The fetch that detects the end of the cursor is not counted, but a query that fills exactly n batches exceeds
+// the limit, because it is unknown whether more rows follow until the next fetch.
+func WithMaxBatches(n int) Option {
+	return func(iter *CursorIterator) error {
+		if n <= 0 {
+			return errors.New("max batches must be bigger than 0")
+		}
+		iter.batch.max = n
+		return nil
+	}
+}
+
+// WithErrorOnEmpty lets the iteration fail with ErrNoRows if the query returned no rows, like pgx.Row.Scan() does,
+// so queries that are expected to return at least one row do not need to count the rows.
+// Next() returns false and Error() returns ErrNoRows after the final fetch, RowsReturned() reports the same without
+// the option.
+func WithErrorOnEmpty() Option {
+	return func(iter *CursorIterator) error {
+		iter.batch.errorOnEmpty = true
+		return nil
+	}
+}
+
+// WithDeferrable starts the transaction as SERIALIZABLE READ ONLY DEFERRABLE.
+// Such a transaction may block when starting, but afterwards it runs without the overhead of serializable
+// transactions and can not fail with a serialization failure, which makes it the recommended mode for
+// consistent long-running reads.
+// The connector must implement PgxTxBeginner (*pgx.Conn and *pgxpool.Pool do).
+// Do not combine this option with WithSnapshot(), which sets the isolation level to REPEATABLE READ.
+func WithDeferrable() Option {
+	return func(iter *CursorIterator) error {
+		iter.txOptions = &pgx.TxOptions{
+			IsoLevel:       pgx.Serializable,
+			AccessMode:     pgx.ReadOnly,
+			DeferrableMode: pgx.Deferrable,
+		}
+		return nil
+	}
+}
+
+// WithRequireTags validates that the element type of values is a struct with at least one exported field that
+// has a db tag. Without tags the columns are matched by the field names, a typo or a renamed column would then
+// leave the field empty, this option turns such a mistake into an error when the iterator is created.
+func WithRequireTags() Option {
+	return func(iter *CursorIterator) error {
+		elemType := reflect.TypeOf(iter.valuesRef).Elem()
+		if elemType.Kind() == reflect.Ptr {
+			elemType = elemType.Elem()
+		}
+		if elemType.Kind() != reflect.Struct {
+			return errors.Errorf("require tags expects values of a struct type, got %s", elemType)
+		}
+		if !hasDBTag(elemType) {
+			return errors.Errorf("%s has no exported fields with a db tag", elemType)
+		}
+		return nil
+	}
+}
+
+// hasDBTag reports whether the struct type t or one of its embedded structs has an exported field with a db tag.
+func hasDBTag(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if _, ok := field.Tag.Lookup("db"); ok && field.IsExported() {
+			return true
+		}
+		if !field.Anonymous {
+			continue
+		}
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if fieldType.Kind() == reflect.Struct && hasDBTag(fieldType) {
+			return true
+		}
+	}
+	return false
+}
+
+// WithScroll declares the cursor as SCROLL cursor, so it can be moved backwards.
+// Depending on the query a scrollable cursor can be slower than a regular one.
+func WithScroll() Option {
+	return func(iter *CursorIterator) error {
+		iter.scroll = true
+		return nil
+	}
+}
+
+// WithInsensitive declares the cursor as INSENSITIVE cursor. Cursors in PostgreSQL are always insensitive to
+// changes made after the cursor was declared, the option only makes this explicit, e.g. for portability.
+// An insensitive cursor can not be used for WHERE CURRENT OF, see ExecCurrentOf().
+func WithInsensitive() Option {
+	return func(iter *CursorIterator) error {
+		iter.insensitive = true
+		return nil
+	}
+}
+
+// WithHold declares the cursor WITH HOLD, so it can be used after the transaction that created it was committed.
+// The iterator rolls back its transaction on Close(), which also removes a holdable cursor.
+func WithHold() Option {
+	return func(iter *CursorIterator) error {
+		iter.hold = true
+		return nil
+	}
+}
+
+// WithFastShutdown prioritizes shutdown speed: if the context passed to Close() is already done, the rollback
+// will be attempted with a fresh context that expires after timeout, and its error will be ignored.
+// Without this option the rollback uses the done context, which fails and reports the context error.
+// If the rollback does not finish in time pgx closes the connection, which also ends the transaction.
+func WithFastShutdown(timeout time.Duration) Option {
+	return func(iter *CursorIterator) error {
+		if timeout <= 0 {
+			return errors.New("fast shutdown timeout must be bigger than 0")
+		}
+		iter.fastShutdownTimeout = timeout
+		return nil
+	}
+}
+
+// WithSingleRow fetches one row at a time, regardless of the capacity of values.
+// This keeps the memory usage minimal at the cost of one database round trip per row.
+// It is equivalent to a capacity of 1 and disables WithAdaptiveBatch() if that was set before.
+func WithSingleRow() Option {
+	return func(iter *CursorIterator) error {
+		iter.fetchSize = 1
+		iter.adaptiveMinFetchSize = 0
+		iter.adaptiveMaxFetchSize = 0
+		return nil
+	}
+}
+
+// WithResultFormat sets the format (pgx.TextFormatCode or pgx.BinaryFormatCode) the rows are fetched in.
+// A single format applies to all columns, otherwise there must be one format per column of the result.
+// Without this option pgx requests the binary format for every type it can decode in binary and the text format
+// for all other types. Forcing the binary format requires a binary decoder for every column type, types without
+// one (e.g. custom types that only support the text format) will fail to scan. The formats only change how pgx
+// decodes the columns, scany scans the decoded values into the fields like before.
+// The formats have no effect if the connection uses pgx.QueryExecModeExec or pgx.QueryExecModeSimpleProtocol,
+// or if the iterator runs on a SQLConnector.
+func WithResultFormat(format pgx.QueryResultFormats) Option {
+	return func(iter *CursorIterator) error {
+		if len(format) == 0 {
+			return errors.New("result format cannot be empty")
+		}
+		for _, code := range format {
+			if code != pgx.TextFormatCode && code != pgx.BinaryFormatCode {
+				return errors.Errorf("invalid result format code %d", code)
+			}
+		}
+		iter.resultFormats = format
+		return nil
+	}
+}
+
+// fetchArgs returns the arguments that must be passed to the queries that fetch the rows, see WithResultFormat().
+func (iter *CursorIterator) fetchArgs() []interface{} {
+	if iter.resultFormats == nil {
+		return nil
+	}
+	return []interface{}{iter.resultFormats}
+}
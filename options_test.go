@@ -0,0 +1,1470 @@
+package cursoriterator_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	cursoriterator "github.com/Eun/go-pgx-cursor-iterator/v2"
+	"github.com/Eun/go-pgx-cursor-iterator/v2/cursoriteratortest"
+)
+
+func TestWithStatementTimeout(t *testing.T) {
+	t.Parallel()
+
+	t.Run("timeout must be bigger than 0", func(t *testing.T) {
+		t.Parallel()
+		iter, err := cursoriterator.NewCursorIteratorWithOptions(
+			&pgxpool.Pool{},
+			make([]User, 3),
+			[]cursoriterator.Option{cursoriterator.WithStatementTimeout(0)},
+			"SELECT * FROM users",
+		)
+		require.EqualError(t, err, "statement timeout must be bigger than 0")
+		require.Nil(t, iter)
+	})
+
+	t.Run("server cancels slow query", func(t *testing.T) {
+		t.Parallel()
+		runTest(
+			t,
+			[]User{
+				{1, "Joe"},
+				{2, "Alice"},
+			},
+			func(pool *pgxpool.Pool) {
+				values := make([]User, 3)
+				iter, err := cursoriterator.NewCursorIteratorWithOptions(
+					pool,
+					values,
+					[]cursoriterator.Option{cursoriterator.WithStatementTimeout(100 * time.Millisecond)},
+					"SELECT id, name FROM users, pg_sleep(5)",
+				)
+				require.NoError(t, err)
+
+				require.False(t, iter.Next(context.Background()))
+				var pgErr *pgconn.PgError
+				require.True(t, errors.As(iter.Error(), &pgErr))
+				// query_canceled
+				require.Equal(t, "57014", pgErr.Code)
+				_ = iter.Close(context.Background())
+			})
+	})
+}
+
+func TestWithApplicationName(t *testing.T) {
+	t.Parallel()
+
+	t.Run("name cannot be empty", func(t *testing.T) {
+		t.Parallel()
+		iter, err := cursoriterator.NewCursorIteratorWithOptions(
+			&pgxpool.Pool{},
+			make([]User, 3),
+			[]cursoriterator.Option{cursoriterator.WithApplicationName("")},
+			"SELECT * FROM users",
+		)
+		require.EqualError(t, err, "application name cannot be empty")
+		require.Nil(t, iter)
+	})
+
+	t.Run("set for the transaction", func(t *testing.T) {
+		t.Parallel()
+		runTest(
+			t,
+			nil,
+			func(pool *pgxpool.Pool) {
+				values := make([]User, 1)
+				iter, err := cursoriterator.NewCursorIteratorWithOptions(
+					pool,
+					values,
+					[]cursoriterator.Option{cursoriterator.WithApplicationName("nightly-export")},
+					"SELECT 1 AS id, current_setting('application_name') AS name",
+				)
+				require.NoError(t, err)
+
+				expectValues(t, iter, values, User{1, "nightly-export"})
+				require.NoError(t, iter.Close(context.Background()))
+			})
+	})
+}
+
+func TestWithAdaptiveBatch(t *testing.T) {
+	t.Parallel()
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		t.Parallel()
+		tests := []struct {
+			min, max int
+			err      string
+		}{
+			{0, 2, "min batch size must be bigger than 0"},
+			{3, 2, "max batch size must be bigger or equal to min batch size"},
+			{1, 4, "max batch size cannot be bigger than the capacity of values"},
+		}
+		for _, test := range tests {
+			iter, err := cursoriterator.NewCursorIteratorWithOptions(
+				&pgxpool.Pool{},
+				make([]User, 3),
+				[]cursoriterator.Option{cursoriterator.WithAdaptiveBatch(test.min, test.max)},
+				"SELECT * FROM users",
+			)
+			require.EqualError(t, err, test.err)
+			require.Nil(t, iter)
+		}
+	})
+
+	t.Run("no rows are dropped while resizing", func(t *testing.T) {
+		t.Parallel()
+		runTest(t, nil, func(pool *pgxpool.Pool) {
+			_, err := pool.Exec(context.Background(), "INSERT INTO users SELECT i, 'user' || i FROM generate_series(1, 1000) i")
+			require.NoError(t, err)
+
+			values := make([]User, 64)
+			iter, err := cursoriterator.NewCursorIteratorWithOptions(
+				pool,
+				values,
+				[]cursoriterator.Option{cursoriterator.WithAdaptiveBatch(1, 64)},
+				"SELECT * FROM users ORDER BY id",
+			)
+			require.NoError(t, err)
+
+			expected := 1
+			for iter.Next(context.Background()) {
+				require.Equal(t, expected, values[iter.ValueIndex()].ID)
+				expected++
+			}
+			require.NoError(t, iter.Error())
+			require.Equal(t, 1001, expected)
+			require.NoError(t, iter.Close(context.Background()))
+		})
+	})
+}
+
+func BenchmarkAdaptiveBatch(b *testing.B) {
+	runTest(b, nil, func(pool *pgxpool.Pool) {
+		_, err := pool.Exec(context.Background(), "INSERT INTO users SELECT i, 'user' || i FROM generate_series(1, 10000) i")
+		require.NoError(b, err)
+
+		benchmarks := []struct {
+			name    string
+			options []cursoriterator.Option
+		}{
+			{"fixed", nil},
+			{"adaptive", []cursoriterator.Option{cursoriterator.WithAdaptiveBatch(16, 1024)}},
+		}
+		for _, bm := range benchmarks {
+			b.Run(bm.name, func(b *testing.B) {
+				values := make([]User, 1024)
+				for i := 0; i < b.N; i++ {
+					iter, err := cursoriterator.NewCursorIteratorWithOptions(pool, values, bm.options, "SELECT * FROM users")
+					require.NoError(b, err)
+					for iter.Next(context.Background()) {
+					}
+					require.NoError(b, iter.Error())
+					require.NoError(b, iter.Close(context.Background()))
+				}
+			})
+		}
+	})
+}
+
+func TestWithAfterFetch(t *testing.T) {
+	t.Parallel()
+
+	t.Run("type must match values", func(t *testing.T) {
+		t.Parallel()
+		iter, err := cursoriterator.NewCursorIteratorWithOptions(
+			&pgxpool.Pool{},
+			make([]User, 3),
+			[]cursoriterator.Option{cursoriterator.WithAfterFetch(func(values []string) error { return nil })},
+			"SELECT * FROM users",
+		)
+		require.EqualError(t, err, "after fetch hook expects []string, but values is []cursoriterator_test.User")
+		require.Nil(t, iter)
+	})
+
+	t.Run("hook receives batches", func(t *testing.T) {
+		t.Parallel()
+		runTest(
+			t,
+			[]User{
+				{1, "Joe"},
+				{2, "Alice"},
+				{3, "Bob"},
+			},
+			func(pool *pgxpool.Pool) {
+				var batches [][]User
+				iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+					pool,
+					2,
+					[]cursoriterator.Option{cursoriterator.WithAfterFetch(func(values []User) error {
+						batches = append(batches, append([]User(nil), values...))
+						return nil
+					})},
+					"SELECT * FROM users ORDER BY id",
+				)
+				require.NoError(t, err)
+
+				expectTypedValues(t, iter,
+					User{1, "Joe"},
+					User{2, "Alice"},
+					User{3, "Bob"},
+				)
+				require.Equal(t, [][]User{
+					{{1, "Joe"}, {2, "Alice"}},
+					{{3, "Bob"}},
+				}, batches)
+				require.NoError(t, iter.Close(context.Background()))
+			})
+	})
+
+	t.Run("hook error stops iteration", func(t *testing.T) {
+		t.Parallel()
+		runTest(
+			t,
+			[]User{
+				{1, "Joe"},
+				{2, "Alice"},
+				{3, "Bob"},
+			},
+			func(pool *pgxpool.Pool) {
+				errInvalid := errors.New("invalid user")
+				values := make([]User, 2)
+				iter, err := cursoriterator.NewCursorIteratorWithOptions(
+					pool,
+					values,
+					[]cursoriterator.Option{cursoriterator.WithAfterFetch(func(values []User) error {
+						for _, user := range values {
+							if user.Name == "Bob" {
+								return errInvalid
+							}
+						}
+						return nil
+					})},
+					"SELECT * FROM users ORDER BY id",
+				)
+				require.NoError(t, err)
+
+				require.True(t, iter.Next(context.Background()))
+				require.True(t, iter.Next(context.Background()))
+				require.False(t, iter.Next(context.Background()))
+				require.True(t, errors.Is(iter.Error(), errInvalid))
+				require.Equal(t, -1, iter.ValueIndex())
+			})
+	})
+}
+
+func TestBatchSizeSmallerThanCapacity(t *testing.T) {
+	t.Parallel()
+	runTest(
+		t,
+		[]User{
+			{1, "Joe"},
+			{2, "Alice"},
+			{3, "Bob"},
+			{4, "Mike"},
+			{5, "Maria"},
+		},
+		func(pool *pgxpool.Pool) {
+			values := make([]User, 10)
+			iter, err := cursoriterator.NewCursorIteratorWithOptions(
+				pool,
+				values,
+				[]cursoriterator.Option{cursoriterator.WithAdaptiveBatch(2, 2)},
+				"SELECT * FROM users ORDER BY id",
+			)
+			require.NoError(t, err)
+
+			expectValues(t, iter, values,
+				User{1, "Joe"},
+				User{2, "Alice"},
+				User{3, "Bob"},
+				User{4, "Mike"},
+				User{5, "Maria"},
+			)
+			requireCapacity(t, iter, 10)
+			require.NoError(t, iter.Close(context.Background()))
+		})
+}
+
+func TestWithSkipScanErrors(t *testing.T) {
+	t.Parallel()
+	batchSizes := []int{1, 2, 3, 4, 5, 6}
+
+	for _, size := range batchSizes {
+		size := size
+		t.Run(fmt.Sprint(size), func(t *testing.T) {
+			t.Parallel()
+			runTest(
+				t,
+				[]User{
+					{1, "Joe"},
+					{2, "Alice"},
+					{3, "Bob"},
+					{4, "Mike"},
+					{5, "Maria"},
+				},
+				func(pool *pgxpool.Pool) {
+					var skippedRows []int64
+					values := make([]User, size)
+					iter, err := cursoriterator.NewCursorIteratorWithOptions(
+						pool,
+						values,
+						[]cursoriterator.Option{cursoriterator.WithSkipScanErrors(func(rowIndex int64, err error) {
+							require.Error(t, err)
+							skippedRows = append(skippedRows, rowIndex)
+						})},
+						// a NULL name cannot be scanned into User.Name
+						"SELECT id, CASE WHEN id = 3 THEN NULL ELSE name END AS name FROM users ORDER BY id",
+					)
+					require.NoError(t, err)
+
+					expectValues(t, iter, values,
+						User{1, "Joe"},
+						User{2, "Alice"},
+						User{4, "Mike"},
+						User{5, "Maria"},
+					)
+					require.Equal(t, []int64{2}, skippedRows)
+					require.Equal(t, int64(1), iter.SkippedCount())
+					require.NoError(t, iter.Close(context.Background()))
+				})
+		})
+	}
+}
+
+func TestWithSnapshot(t *testing.T) {
+	t.Parallel()
+	runTest(
+		t,
+		[]User{
+			{1, "Joe"},
+			{2, "Alice"},
+			{3, "Bob"},
+		},
+		func(pool *pgxpool.Pool) {
+			values1 := make([]User, 2)
+			iter1, err := cursoriterator.NewCursorIterator(pool, values1, "SELECT * FROM users ORDER BY id")
+			require.NoError(t, err)
+			_, err = iter1.ExportSnapshot(context.Background())
+			require.EqualError(t, err, "iterator has no open transaction")
+
+			require.True(t, iter1.Next(context.Background()))
+			snapshotID, err := iter1.ExportSnapshot(context.Background())
+			require.NoError(t, err)
+			require.NotEmpty(t, snapshotID)
+
+			// this user must not be visible in the snapshot
+			_, err = pool.Exec(context.Background(), "INSERT INTO users VALUES(4, 'Mike')")
+			require.NoError(t, err)
+
+			values2 := make([]User, 2)
+			iter2, err := cursoriterator.NewCursorIteratorWithOptions(
+				pool,
+				values2,
+				[]cursoriterator.Option{cursoriterator.WithSnapshot(snapshotID)},
+				"SELECT * FROM users ORDER BY id",
+			)
+			require.NoError(t, err)
+
+			expectValues(t, iter2, values2,
+				User{1, "Joe"},
+				User{2, "Alice"},
+				User{3, "Bob"},
+			)
+			require.NoError(t, iter2.Close(context.Background()))
+			require.NoError(t, iter1.Close(context.Background()))
+		})
+}
+
+func TestWithCursorName(t *testing.T) {
+	t.Parallel()
+
+	t.Run("invalid names", func(t *testing.T) {
+		t.Parallel()
+		tests := []struct {
+			name string
+			err  string
+		}{
+			{"", "cursor name cannot be empty"},
+			{strings.Repeat("a", 64), "cursor name cannot be longer than 63 bytes"},
+		}
+		for _, test := range tests {
+			iter, err := cursoriterator.NewCursorIteratorWithOptions(
+				&pgxpool.Pool{},
+				make([]User, 3),
+				[]cursoriterator.Option{cursoriterator.WithCursorName(test.name)},
+				"SELECT * FROM users",
+			)
+			require.EqualError(t, err, test.err)
+			require.Nil(t, iter)
+		}
+	})
+
+	t.Run("custom name", func(t *testing.T) {
+		t.Parallel()
+		runTest(
+			t,
+			[]User{
+				{1, "Joe"},
+				{2, "Alice"},
+			},
+			func(pool *pgxpool.Pool) {
+				values := make([]User, 1)
+				iter, err := cursoriterator.NewCursorIteratorWithOptions(
+					pool,
+					values,
+					[]cursoriterator.Option{cursoriterator.WithCursorName(`my "cursor"`)},
+					"SELECT * FROM users ORDER BY id",
+				)
+				require.NoError(t, err)
+				require.Equal(t, `my "cursor"`, iter.CursorName())
+
+				expectValues(t, iter, values,
+					User{1, "Joe"},
+					User{2, "Alice"},
+				)
+				require.NoError(t, iter.Close(context.Background()))
+			})
+	})
+}
+
+func TestWithMaterialize(t *testing.T) {
+	t.Parallel()
+	runTest(
+		t,
+		[]User{
+			{1, "Joe"},
+			{2, "Alice"},
+			{3, "Bob"},
+		},
+		func(pool *pgxpool.Pool) {
+			// use a single connection, temporary tables are only visible in their session
+			conn, err := pgx.Connect(context.Background(), pool.Config().ConnString())
+			require.NoError(t, err)
+			defer conn.Close(context.Background())
+
+			countTemporaryTables := func() int {
+				var n int
+				require.NoError(t, conn.QueryRow(context.Background(), `
+SELECT count(*) FROM pg_class WHERE relpersistence = 't' AND relname LIKE 'materialized\_%'`).Scan(&n))
+				return n
+			}
+
+			values := make([]User, 2)
+			iter, err := cursoriterator.NewCursorIteratorWithOptions(
+				conn,
+				values,
+				[]cursoriterator.Option{cursoriterator.WithMaterialize()},
+				"SELECT * FROM users WHERE id > $1 ORDER BY id", 0,
+			)
+			require.NoError(t, err)
+
+			require.True(t, iter.Next(context.Background()))
+			require.Equal(t, User{1, "Joe"}, values[iter.ValueIndex()])
+			require.Equal(t, 1, countTemporaryTables())
+
+			// the transaction of the cursor does not hold a lock on the queried table
+			tx, err := pool.Begin(context.Background())
+			require.NoError(t, err)
+			_, err = tx.Exec(context.Background(), "SET LOCAL lock_timeout = '1s'")
+			require.NoError(t, err)
+			_, err = tx.Exec(context.Background(), "LOCK TABLE users IN ACCESS EXCLUSIVE MODE")
+			require.NoError(t, err)
+			require.NoError(t, tx.Rollback(context.Background()))
+
+			// the result is materialized, so this user must not be visible
+			_, err = pool.Exec(context.Background(), "INSERT INTO users VALUES(4, 'Mike')")
+			require.NoError(t, err)
+
+			expectValues(t, iter, values,
+				User{2, "Alice"},
+				User{3, "Bob"},
+			)
+			require.NoError(t, iter.Close(context.Background()))
+			require.Equal(t, 0, countTemporaryTables())
+		})
+}
+
+func TestWithMaterializeTransactions(t *testing.T) {
+	t.Parallel()
+	connector := cursoriteratortest.NewConnector(
+		[]string{"id", "name"},
+		[]interface{}{1, "Joe"},
+		[]interface{}{2, "Alice"},
+	)
+	iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+		connector,
+		2,
+		[]cursoriterator.Option{cursoriterator.WithMaterialize(), cursoriterator.WithOrderBy("id")},
+		"SELECT * FROM users WHERE id > $1", 0,
+	)
+	require.NoError(t, err)
+	require.True(t, iter.Next(context.Background()))
+	require.NoError(t, iter.Close(context.Background()))
+
+	statements := connector.Statements()
+	require.Len(t, statements, 10)
+	table := strings.TrimSuffix(strings.TrimPrefix(statements[1], "CREATE TEMPORARY TABLE "),
+		` AS SELECT * FROM (SELECT * FROM users WHERE id > $1) AS ordered ORDER BY "id"`)
+	require.Regexp(t, `^"materialized_[0-9a-f]{32}"$`, table)
+	require.Equal(t, []string{
+		"BEGIN",
+		statements[1],
+		// the query is materialized in its own transaction
+		"COMMIT",
+		"BEGIN",
+		fmt.Sprintf(`DECLARE %s CURSOR FOR SELECT * FROM %s ORDER BY "id"`,
+			pgx.Identifier{iter.CursorName()}.Sanitize(), table),
+		fmt.Sprintf("FETCH FORWARD 2 IN %s", pgx.Identifier{iter.CursorName()}.Sanitize()),
+		"ROLLBACK",
+		"BEGIN",
+		"DROP TABLE IF EXISTS " + table,
+		"COMMIT",
+	}, statements)
+}
+
+func TestWithMaterializeReadOnly(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		option cursoriterator.Option
+		err    string
+	}{
+		{cursoriterator.WithReadOnly(), "materialize cannot be used with read only"},
+		{cursoriterator.WithDeferrable(), "materialize cannot be used with deferrable"},
+	}
+	for _, test := range tests {
+		connector := cursoriteratortest.NewConnector([]string{"id", "name"}, []interface{}{1, "Joe"})
+		_, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+			connector,
+			2,
+			[]cursoriterator.Option{cursoriterator.WithMaterialize(), test.option},
+			"SELECT * FROM users",
+		)
+		require.EqualError(t, err, test.err)
+		// the combination is rejected before any statement is executed
+		require.Empty(t, connector.Statements())
+	}
+}
+
+func TestWithConnectionInit(t *testing.T) {
+	t.Parallel()
+
+	t.Run("set search_path", func(t *testing.T) {
+		t.Parallel()
+		runTest(t, nil, func(pool *pgxpool.Pool) {
+			_, err := pool.Exec(context.Background(), `
+CREATE SCHEMA tenant;
+CREATE TABLE tenant.people (
+	id		integer PRIMARY KEY,
+	name	varchar(32) NOT NULL
+);
+INSERT INTO tenant.people VALUES (1, 'Joe'), (2, 'Alice');`)
+			require.NoError(t, err)
+
+			values := make([]User, 2)
+			iter, err := cursoriterator.NewCursorIteratorWithOptions(
+				pool,
+				values,
+				[]cursoriterator.Option{cursoriterator.WithConnectionInit(func(ctx context.Context, tx pgx.Tx) error {
+					_, err := tx.Exec(ctx, "SET LOCAL search_path = tenant")
+					return err
+				})},
+				"SELECT * FROM people ORDER BY id",
+			)
+			require.NoError(t, err)
+
+			expectValues(t, iter, values,
+				User{1, "Joe"},
+				User{2, "Alice"},
+			)
+			require.NoError(t, iter.Close(context.Background()))
+		})
+	})
+
+	t.Run("init fails", func(t *testing.T) {
+		t.Parallel()
+		runTest(t, nil, func(pool *pgxpool.Pool) {
+			errInit := errors.New("init failed")
+			iter, err := cursoriterator.NewCursorIteratorWithOptions(
+				pool,
+				make([]User, 2),
+				[]cursoriterator.Option{cursoriterator.WithConnectionInit(func(context.Context, pgx.Tx) error {
+					return errInit
+				})},
+				"SELECT * FROM users",
+			)
+			require.NoError(t, err)
+
+			require.False(t, iter.Next(context.Background()))
+			require.True(t, errors.Is(iter.Error(), errInit))
+		})
+	})
+}
+
+func TestWithTypeRegistration(t *testing.T) {
+	t.Parallel()
+
+	type Person struct {
+		ID   int
+		Mood string
+	}
+
+	runTest(t, nil, func(pool *pgxpool.Pool) {
+		_, err := pool.Exec(context.Background(), `
+CREATE TYPE mood AS ENUM ('sad', 'ok', 'happy');
+CREATE TABLE people (
+	id		integer PRIMARY KEY,
+	mood	mood NOT NULL
+);
+INSERT INTO people VALUES (1, 'happy'), (2, 'sad');`)
+		require.NoError(t, err)
+
+		registered := 0
+		values := make([]Person, 2)
+		iter, err := cursoriterator.NewCursorIteratorWithOptions(
+			pool,
+			values,
+			[]cursoriterator.Option{cursoriterator.WithTypeRegistration(func(ctx context.Context, conn *pgx.Conn) error {
+				registered++
+				typ, err := conn.LoadType(ctx, "mood")
+				if err != nil {
+					return err
+				}
+				conn.TypeMap().RegisterType(typ)
+				return nil
+			})},
+			"SELECT * FROM people ORDER BY id",
+		)
+		require.NoError(t, err)
+
+		require.True(t, iter.Next(context.Background()))
+		require.Equal(t, Person{1, "happy"}, values[iter.ValueIndex()])
+		require.True(t, iter.Next(context.Background()))
+		require.Equal(t, Person{2, "sad"}, values[iter.ValueIndex()])
+		require.False(t, iter.Next(context.Background()))
+		require.NoError(t, iter.Error())
+		require.Equal(t, 1, registered)
+		require.NoError(t, iter.Close(context.Background()))
+	})
+}
+
+func TestWithReverseBuffers(t *testing.T) {
+	t.Parallel()
+	runTest(
+		t,
+		[]User{
+			{1, "Joe"},
+			{2, "Alice"},
+			{3, "Bob"},
+			{4, "Mike"},
+			{5, "Maria"},
+		},
+		func(pool *pgxpool.Pool) {
+			values := make([]User, 2)
+			iter, err := cursoriterator.NewCursorIteratorWithOptions(
+				pool,
+				values,
+				[]cursoriterator.Option{cursoriterator.WithReverseBuffers()},
+				"SELECT * FROM users ORDER BY id",
+			)
+			require.NoError(t, err)
+
+			expectValues(t, iter, values,
+				User{2, "Alice"},
+				User{1, "Joe"},
+				User{4, "Mike"},
+				User{3, "Bob"},
+				User{5, "Maria"},
+			)
+			require.Equal(t, -1, iter.ValueIndex())
+			require.NoError(t, iter.Close(context.Background()))
+		})
+}
+
+func TestWithResumeFrom(t *testing.T) {
+	t.Parallel()
+
+	t.Run("empty column", func(t *testing.T) {
+		t.Parallel()
+		iter, err := cursoriterator.NewCursorIteratorWithOptions(
+			&pgxpool.Pool{},
+			make([]User, 2),
+			[]cursoriterator.Option{cursoriterator.WithResumeFrom("", 1)},
+			"SELECT * FROM users",
+		)
+		require.EqualError(t, err, "resume column cannot be empty")
+		require.Nil(t, iter)
+	})
+
+	t.Run("resume from midpoint", func(t *testing.T) {
+		t.Parallel()
+		runTest(
+			t,
+			[]User{
+				{1, "Joe"},
+				{2, "Alice"},
+				{3, "Bob"},
+				{4, "Mike"},
+				{5, "Maria"},
+			},
+			func(pool *pgxpool.Pool) {
+				values := make([]User, 2)
+				iter, err := cursoriterator.NewCursorIteratorWithOptions(
+					pool,
+					values,
+					[]cursoriterator.Option{cursoriterator.WithResumeFrom("id", 2)},
+					"SELECT * FROM users WHERE name <> $1 ORDER BY id",
+					"Mike",
+				)
+				require.NoError(t, err)
+
+				expectValues(t, iter, values,
+					User{3, "Bob"},
+					User{5, "Maria"},
+				)
+				require.NoError(t, iter.Close(context.Background()))
+			})
+	})
+}
+
+func TestWithOrderBy(t *testing.T) {
+	t.Parallel()
+
+	declaredQuery := func(t *testing.T, options ...cursoriterator.Option) string {
+		connector := cursoriteratortest.NewConnector([]string{"id", "name"}, []interface{}{1, "Joe"})
+		iter, err := cursoriterator.NewCursorIteratorWithOptions(
+			connector,
+			make([]User, 2),
+			options,
+			"SELECT * FROM users WHERE name <> $1 ORDER BY name",
+			"Mike",
+		)
+		require.NoError(t, err)
+		require.True(t, iter.Next(context.Background()))
+		require.NoError(t, iter.Close(context.Background()))
+
+		for _, statement := range connector.Statements() {
+			if strings.HasPrefix(statement, "DECLARE ") {
+				return strings.SplitN(statement, " FOR ", 2)[1]
+			}
+		}
+		t.Fatal("cursor was not declared")
+		return ""
+	}
+
+	t.Run("order by", func(t *testing.T) {
+		t.Parallel()
+		require.Equal(t,
+			`SELECT * FROM (SELECT * FROM users WHERE name <> $1 ORDER BY name) AS ordered ORDER BY "id"`,
+			declaredQuery(t, cursoriterator.WithOrderBy("id")),
+		)
+	})
+
+	t.Run("multiple columns with direction", func(t *testing.T) {
+		t.Parallel()
+		require.Equal(t,
+			`SELECT * FROM (SELECT * FROM users WHERE name <> $1 ORDER BY name) AS ordered ORDER BY "name" DESC, "id" ASC`,
+			declaredQuery(t, cursoriterator.WithOrderBy("name desc", "id ASC")),
+		)
+	})
+
+	t.Run("with resume from", func(t *testing.T) {
+		t.Parallel()
+		require.Equal(t,
+			`SELECT * FROM (SELECT * FROM users WHERE name <> $1 ORDER BY name) AS resume WHERE "id" > $2 ORDER BY "name"`,
+			declaredQuery(t, cursoriterator.WithResumeFrom("id", 2), cursoriterator.WithOrderBy("name")),
+		)
+	})
+
+	t.Run("with resume from descending", func(t *testing.T) {
+		t.Parallel()
+		require.Equal(t,
+			`SELECT * FROM (SELECT * FROM users WHERE name <> $1 ORDER BY name) AS resume WHERE "id" < $2 ORDER BY "id" DESC`,
+			declaredQuery(t, cursoriterator.WithResumeFrom("id", 2), cursoriterator.WithOrderBy("id DESC")),
+		)
+		require.Equal(t,
+			`SELECT * FROM (SELECT * FROM users WHERE name <> $1 ORDER BY name) AS resume WHERE "id" > $2 ORDER BY "name" DESC, "id"`,
+			declaredQuery(t, cursoriterator.WithResumeFrom("id", 2), cursoriterator.WithOrderBy("name DESC", "id")),
+		)
+	})
+
+	t.Run("invalid columns", func(t *testing.T) {
+		t.Parallel()
+		for _, columns := range [][]string{
+			{"id; DROP TABLE users"},
+			{`"id"`},
+			{"id DESC NULLS FIRST"},
+			{"1"},
+			{""},
+			{"id", "name--"},
+		} {
+			_, err := cursoriterator.NewCursorIteratorWithOptions(
+				&pgxpool.Pool{},
+				make([]User, 2),
+				[]cursoriterator.Option{cursoriterator.WithOrderBy(columns...)},
+				"SELECT * FROM users",
+			)
+			require.EqualError(t, err, fmt.Sprintf("invalid order by column %q", columns[len(columns)-1]))
+		}
+	})
+
+	t.Run("no columns", func(t *testing.T) {
+		t.Parallel()
+		_, err := cursoriterator.NewCursorIteratorWithOptions(
+			&pgxpool.Pool{},
+			make([]User, 2),
+			[]cursoriterator.Option{cursoriterator.WithOrderBy()},
+			"SELECT * FROM users",
+		)
+		require.EqualError(t, err, "order by columns cannot be empty")
+	})
+
+	t.Run("reverse order", func(t *testing.T) {
+		t.Parallel()
+		runTest(
+			t,
+			[]User{
+				{1, "Joe"},
+				{2, "Alice"},
+				{3, "Bob"},
+			},
+			func(pool *pgxpool.Pool) {
+				values := make([]User, 2)
+				iter, err := cursoriterator.NewCursorIteratorWithOptions(
+					pool,
+					values,
+					[]cursoriterator.Option{cursoriterator.WithOrderBy("id DESC")},
+					"SELECT * FROM users",
+				)
+				require.NoError(t, err)
+
+				expectValues(t, iter, values,
+					User{3, "Bob"},
+					User{2, "Alice"},
+					User{1, "Joe"},
+				)
+				require.NoError(t, iter.Close(context.Background()))
+			})
+	})
+}
+
+func TestWithWrapSubquery(t *testing.T) {
+	t.Parallel()
+
+	t.Run("declared query", func(t *testing.T) {
+		t.Parallel()
+		connector := cursoriteratortest.NewConnector([]string{"id", "name"}, []interface{}{1, "Joe"})
+		iter, err := cursoriterator.NewCursorIteratorWithOptions(
+			connector,
+			make([]User, 2),
+			[]cursoriterator.Option{cursoriterator.WithWrapSubquery(), cursoriterator.WithOrderBy("id")},
+			"SELECT * FROM users ORDER BY name;\n",
+		)
+		require.NoError(t, err)
+		require.True(t, iter.Next(context.Background()))
+		require.NoError(t, iter.Close(context.Background()))
+
+		require.Contains(t, connector.Statements(),
+			"DECLARE "+`"`+iter.CursorName()+`"`+
+				` CURSOR FOR SELECT * FROM (SELECT * FROM (SELECT * FROM users ORDER BY name) AS _sub) AS ordered ORDER BY "id"`,
+		)
+	})
+
+	t.Run("same result as unwrapped", func(t *testing.T) {
+		t.Parallel()
+		runTest(
+			t,
+			[]User{
+				{1, "Joe"},
+				{2, "Alice"},
+				{3, "Bob"},
+				{4, "Mike"},
+			},
+			func(pool *pgxpool.Pool) {
+				const query = `WITH named AS (SELECT * FROM users WHERE id > $1) SELECT * FROM named ORDER BY name DESC`
+				iterate := func(options ...cursoriterator.Option) []User {
+					iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](pool, 2, options, query, 1)
+					require.NoError(t, err)
+					var users []User
+					for iter.Next(context.Background()) {
+						users = append(users, iter.Value())
+					}
+					require.NoError(t, iter.Error())
+					require.NoError(t, iter.Close(context.Background()))
+					return users
+				}
+
+				unwrapped := iterate()
+				require.Equal(t, []User{{4, "Mike"}, {3, "Bob"}, {2, "Alice"}}, unwrapped)
+				require.Equal(t, unwrapped, iterate(cursoriterator.WithWrapSubquery()))
+			})
+	})
+}
+
+func TestWithLimit(t *testing.T) {
+	t.Parallel()
+
+	t.Run("invalid limit", func(t *testing.T) {
+		t.Parallel()
+		iter, err := cursoriterator.NewCursorIteratorWithOptions(
+			&pgxpool.Pool{},
+			make([]User, 2),
+			[]cursoriterator.Option{cursoriterator.WithLimit(0)},
+			"SELECT * FROM users",
+		)
+		require.EqualError(t, err, "limit must be bigger than 0")
+		require.Nil(t, iter)
+	})
+
+	tests := []struct {
+		limit    int64
+		expected []User
+		fetches  []string
+	}{
+		{
+			limit:    2,
+			expected: []User{{1, "Joe"}, {2, "Alice"}},
+			fetches:  []string{"FETCH FORWARD 2"},
+		},
+		{
+			limit:    3,
+			expected: []User{{1, "Joe"}, {2, "Alice"}, {3, "Bob"}},
+			fetches:  []string{"FETCH FORWARD 2", "FETCH FORWARD 1"},
+		},
+		{
+			limit:    4,
+			expected: []User{{1, "Joe"}, {2, "Alice"}, {3, "Bob"}, {4, "Mike"}},
+			fetches:  []string{"FETCH FORWARD 2", "FETCH FORWARD 2"},
+		},
+		{
+			limit:    10,
+			expected: []User{{1, "Joe"}, {2, "Alice"}, {3, "Bob"}, {4, "Mike"}, {5, "Maria"}},
+			fetches:  []string{"FETCH FORWARD 2", "FETCH FORWARD 2", "FETCH FORWARD 2", "FETCH FORWARD 2"},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(fmt.Sprint(test.limit), func(t *testing.T) {
+			t.Parallel()
+			connector := cursoriteratortest.NewConnector(
+				[]string{"id", "name"},
+				[]interface{}{1, "Joe"},
+				[]interface{}{2, "Alice"},
+				[]interface{}{3, "Bob"},
+				[]interface{}{4, "Mike"},
+				[]interface{}{5, "Maria"},
+			)
+			values := make([]User, 2)
+			iter, err := cursoriterator.NewCursorIteratorWithOptions(
+				connector,
+				values,
+				[]cursoriterator.Option{cursoriterator.WithLimit(test.limit)},
+				"SELECT * FROM users ORDER BY id",
+			)
+			require.NoError(t, err)
+
+			expectValues(t, iter, values, test.expected...)
+			require.NoError(t, iter.Close(context.Background()))
+
+			var fetches []string
+			for _, statement := range connector.Statements() {
+				if strings.HasPrefix(statement, "FETCH ") {
+					fetches = append(fetches, strings.SplitN(statement, " IN ", 2)[0])
+				}
+			}
+			require.Equal(t, test.fetches, fetches)
+		})
+	}
+}
+
+func TestWithMaxBatches(t *testing.T) {
+	t.Parallel()
+
+	t.Run("invalid max batches", func(t *testing.T) {
+		t.Parallel()
+		iter, err := cursoriterator.NewCursorIteratorWithOptions(
+			&pgxpool.Pool{},
+			make([]User, 2),
+			[]cursoriterator.Option{cursoriterator.WithMaxBatches(0)},
+			"SELECT * FROM users",
+		)
+		require.EqualError(t, err, "max batches must be bigger than 0")
+		require.Nil(t, iter)
+	})
+
+	t.Run("exceeded", func(t *testing.T) {
+		t.Parallel()
+		connector, users := newUsersConnector(10)
+		iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+			connector,
+			3,
+			[]cursoriterator.Option{cursoriterator.WithMaxBatches(2)},
+			"SELECT * FROM users",
+		)
+		require.NoError(t, err)
+
+		var result []User
+		for iter.Next(context.Background()) {
+			result = append(result, iter.Value())
+		}
+		require.ErrorIs(t, iter.Error(), cursoriterator.ErrMaxBatchesExceeded)
+		require.EqualError(t, iter.Error(), "iteration stopped after 2 batches: maximum number of batches exceeded")
+		require.Equal(t, users[:6], result)
+		require.False(t, iter.Next(context.Background()))
+		require.Equal(t, "ROLLBACK", connector.Statements()[len(connector.Statements())-1])
+	})
+
+	t.Run("within limit", func(t *testing.T) {
+		t.Parallel()
+		// 5 rows need 2 full batches, a short one and the fetch that detects the end
+		connector, users := newUsersConnector(5)
+		values := make([]User, 2)
+		iter, err := cursoriterator.NewCursorIteratorWithOptions(
+			connector,
+			values,
+			[]cursoriterator.Option{cursoriterator.WithMaxBatches(3)},
+			"SELECT * FROM users",
+		)
+		require.NoError(t, err)
+		expectValues(t, iter, values, users...)
+		require.NoError(t, iter.Close(context.Background()))
+	})
+}
+
+func TestWithErrorOnEmpty(t *testing.T) {
+	t.Parallel()
+
+	t.Run("empty", func(t *testing.T) {
+		t.Parallel()
+		connector, _ := newUsersConnector(0)
+		iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+			connector, 2, []cursoriterator.Option{cursoriterator.WithErrorOnEmpty()}, "SELECT * FROM users",
+		)
+		require.NoError(t, err)
+		require.False(t, iter.Next(context.Background()))
+		require.ErrorIs(t, iter.Error(), cursoriterator.ErrNoRows)
+		require.ErrorIs(t, iter.Error(), pgx.ErrNoRows)
+		require.False(t, iter.RowsReturned())
+		require.ErrorIs(t, iter.Close(context.Background()), cursoriterator.ErrNoRows)
+	})
+
+	t.Run("empty without option", func(t *testing.T) {
+		t.Parallel()
+		connector, _ := newUsersConnector(0)
+		iter, err := cursoriterator.NewTypedCursorIterator[User](connector, 2, "SELECT * FROM users")
+		require.NoError(t, err)
+		require.False(t, iter.Next(context.Background()))
+		require.NoError(t, iter.Error())
+		require.False(t, iter.RowsReturned())
+		require.NoError(t, iter.Close(context.Background()))
+	})
+
+	t.Run("not empty", func(t *testing.T) {
+		t.Parallel()
+		connector, users := newUsersConnector(3)
+		iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+			connector, 2, []cursoriterator.Option{cursoriterator.WithErrorOnEmpty()}, "SELECT * FROM users",
+		)
+		require.NoError(t, err)
+		require.False(t, iter.RowsReturned())
+		expectTypedValues(t, iter, users...)
+		require.True(t, iter.RowsReturned())
+		require.NoError(t, iter.Close(context.Background()))
+	})
+}
+
+func TestWithDeferrable(t *testing.T) {
+	t.Parallel()
+
+	t.Run("connector without BeginTx", func(t *testing.T) {
+		t.Parallel()
+		iter, err := cursoriterator.NewCursorIteratorWithOptions(
+			cursoriteratortest.NewConnector([]string{"id", "name"}),
+			make([]User, 2),
+			[]cursoriterator.Option{cursoriterator.WithDeferrable()},
+			"SELECT * FROM users",
+		)
+		require.NoError(t, err)
+		require.False(t, iter.Next(context.Background()))
+		require.ErrorContains(t, iter.Error(), "does not support transaction options")
+	})
+
+	t.Run("deferrable transaction", func(t *testing.T) {
+		t.Parallel()
+		runTest(
+			t,
+			[]User{
+				{1, "Joe"},
+				{2, "Alice"},
+				{3, "Bob"},
+			},
+			func(pool *pgxpool.Pool) {
+				settings := make(map[string]string)
+				values := make([]User, 2)
+				iter, err := cursoriterator.NewCursorIteratorWithOptions(
+					pool,
+					values,
+					[]cursoriterator.Option{
+						cursoriterator.WithDeferrable(),
+						cursoriterator.WithConnectionInit(func(ctx context.Context, tx pgx.Tx) error {
+							for _, name := range []string{"transaction_isolation", "transaction_read_only", "transaction_deferrable"} {
+								var value string
+								if err := tx.QueryRow(ctx, "SHOW "+name).Scan(&value); err != nil {
+									return err
+								}
+								settings[name] = value
+							}
+							return nil
+						}),
+					},
+					"SELECT * FROM users ORDER BY id",
+				)
+				require.NoError(t, err)
+
+				expectValues(t, iter, values,
+					User{1, "Joe"},
+					User{2, "Alice"},
+					User{3, "Bob"},
+				)
+				require.NoError(t, iter.Close(context.Background()))
+				require.Equal(t, map[string]string{
+					"transaction_isolation":  "serializable",
+					"transaction_read_only":  "on",
+					"transaction_deferrable": "on",
+				}, settings)
+			})
+	})
+}
+
+func TestWithRequireTags(t *testing.T) {
+	t.Parallel()
+
+	type Untagged struct {
+		ID   int
+		Name string
+	}
+	type Base struct {
+		ID int `db:"id"`
+	}
+	type Embedded struct {
+		Base
+		Name string
+	}
+
+	tests := []struct {
+		name   string
+		values interface{}
+		err    string
+	}{
+		{"tagged", make([]User, 2), ""},
+		{"tagged pointer", make([]*User, 2), ""},
+		{"embedded", make([]Embedded, 2), ""},
+		{"untagged", make([]Untagged, 2), "cursoriterator_test.Untagged has no exported fields with a db tag"},
+		{"primitive", make([]int, 2), "require tags expects values of a struct type, got int"},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			iter, err := cursoriterator.NewCursorIteratorWithOptions(
+				&pgxpool.Pool{},
+				test.values,
+				[]cursoriterator.Option{cursoriterator.WithRequireTags()},
+				"SELECT * FROM users",
+			)
+			if test.err == "" {
+				require.NoError(t, err)
+				require.NotNil(t, iter)
+				return
+			}
+			require.EqualError(t, err, test.err)
+			require.Nil(t, iter)
+		})
+	}
+}
+
+func TestWithFastShutdown(t *testing.T) {
+	t.Parallel()
+
+	t.Run("invalid timeout", func(t *testing.T) {
+		t.Parallel()
+		iter, err := cursoriterator.NewCursorIteratorWithOptions(
+			&pgxpool.Pool{},
+			make([]User, 2),
+			[]cursoriterator.Option{cursoriterator.WithFastShutdown(0)},
+			"SELECT * FROM users",
+		)
+		require.EqualError(t, err, "fast shutdown timeout must be bigger than 0")
+		require.Nil(t, iter)
+	})
+
+	newIterator := func(t *testing.T, options ...cursoriterator.Option) *cursoriterator.TypedCursorIterator[User] {
+		connector := cursoriteratortest.NewConnector(
+			[]string{"id", "name"},
+			[]interface{}{1, "Joe"},
+			[]interface{}{2, "Alice"},
+		)
+		// the rollback blocks until the context is done
+		connector.OnRollback = func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](connector, 1, options, "SELECT * FROM users")
+		require.NoError(t, err)
+		require.True(t, iter.Next(context.Background()))
+		return iter
+	}
+
+	t.Run("canceled context", func(t *testing.T) {
+		t.Parallel()
+		iter := newIterator(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		require.ErrorIs(t, iter.Close(ctx), context.Canceled)
+	})
+
+	t.Run("canceled context with fast shutdown", func(t *testing.T) {
+		t.Parallel()
+		iter := newIterator(t, cursoriterator.WithFastShutdown(50*time.Millisecond))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		start := time.Now()
+		require.NoError(t, iter.Close(ctx))
+		require.Less(t, time.Since(start), time.Second)
+		require.False(t, iter.Next(context.Background()))
+	})
+}
+
+func TestWithSingleRow(t *testing.T) {
+	t.Parallel()
+
+	t.Run("fetches one row at a time", func(t *testing.T) {
+		t.Parallel()
+		connector := cursoriteratortest.NewConnector(
+			[]string{"id", "name"},
+			[]interface{}{1, "Joe"},
+			[]interface{}{2, "Alice"},
+			[]interface{}{3, "Bob"},
+		)
+		values := make([]User, 10)
+		iter, err := cursoriterator.NewCursorIteratorWithOptions(
+			connector,
+			values,
+			[]cursoriterator.Option{cursoriterator.WithSingleRow()},
+			"SELECT * FROM users",
+		)
+		require.NoError(t, err)
+
+		expectValues(t, iter, values,
+			User{1, "Joe"},
+			User{2, "Alice"},
+			User{3, "Bob"},
+		)
+		require.NoError(t, iter.Close(context.Background()))
+
+		var fetches []string
+		for _, statement := range connector.Statements() {
+			if strings.HasPrefix(statement, "FETCH ") {
+				fetches = append(fetches, strings.SplitN(statement, " IN ", 2)[0])
+			}
+		}
+		require.Equal(t, []string{"FETCH FORWARD 1", "FETCH FORWARD 1", "FETCH FORWARD 1", "FETCH FORWARD 1"}, fetches)
+	})
+
+	t.Run("large table", func(t *testing.T) {
+		t.Parallel()
+		runTest(t, nil, func(pool *pgxpool.Pool) {
+			_, err := pool.Exec(context.Background(), "INSERT INTO users SELECT i, 'user' || i FROM generate_series(1, 1000) i")
+			require.NoError(t, err)
+
+			iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+				pool,
+				1,
+				[]cursoriterator.Option{cursoriterator.WithSingleRow()},
+				"SELECT * FROM users ORDER BY id",
+			)
+			require.NoError(t, err)
+
+			expected := 1
+			for iter.Next(context.Background()) {
+				require.Equal(t, 0, iter.ValueIndex())
+				require.Equal(t, User{expected, fmt.Sprint("user", expected)}, iter.Value())
+				expected++
+			}
+			require.NoError(t, iter.Error())
+			require.Equal(t, 1001, expected)
+			require.NoError(t, iter.Close(context.Background()))
+		})
+	})
+}
+
+type Measurement struct {
+	ID    int64   `db:"id"`
+	Count int32   `db:"count"`
+	Value float64 `db:"value"`
+	Ratio float32 `db:"ratio"`
+	Total float64 `db:"total"`
+}
+
+const measurementsQuery = `
+SELECT i::bigint AS id, (i % 1000)::integer AS count, i * 1.5::double precision AS value,
+	(i % 7)::real / 8 AS ratio, (i * 0.25)::numeric(12, 2) AS total
+FROM generate_series(1, $1) i`
+
+func TestWithResultFormat(t *testing.T) {
+	t.Parallel()
+
+	t.Run("invalid format", func(t *testing.T) {
+		t.Parallel()
+		_, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+			&pgxpool.Pool{},
+			2,
+			[]cursoriterator.Option{cursoriterator.WithResultFormat(nil)},
+			"SELECT * FROM users",
+		)
+		require.EqualError(t, err, "result format cannot be empty")
+
+		_, err = cursoriterator.NewTypedCursorIteratorWithOptions[User](
+			&pgxpool.Pool{},
+			2,
+			[]cursoriterator.Option{cursoriterator.WithResultFormat(pgx.QueryResultFormats{2})},
+			"SELECT * FROM users",
+		)
+		require.EqualError(t, err, "invalid result format code 2")
+	})
+
+	for _, format := range []struct {
+		name string
+		code int16
+	}{{"text", pgx.TextFormatCode}, {"binary", pgx.BinaryFormatCode}} {
+		format := format
+		t.Run(format.name, func(t *testing.T) {
+			t.Parallel()
+			runTest(t, nil, func(pool *pgxpool.Pool) {
+				const rowCount = 1000
+				iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[Measurement](
+					pool,
+					64,
+					[]cursoriterator.Option{cursoriterator.WithResultFormat(pgx.QueryResultFormats{format.code})},
+					measurementsQuery, rowCount,
+				)
+				require.NoError(t, err)
+
+				var i int64
+				for iter.Next(context.Background()) {
+					i++
+					require.Equal(t, Measurement{
+						ID:    i,
+						Count: int32(i % 1000),
+						Value: float64(i) * 1.5,
+						Ratio: float32(i%7) / 8,
+						Total: float64(i) * 0.25,
+					}, iter.Value())
+				}
+				require.NoError(t, iter.Error())
+				require.EqualValues(t, rowCount, i)
+				require.NoError(t, iter.Close(context.Background()))
+			})
+		})
+	}
+}
+
+func TestWithInsensitive(t *testing.T) {
+	t.Parallel()
+
+	t.Run("declare", func(t *testing.T) {
+		t.Parallel()
+		for _, test := range []struct {
+			options []cursoriterator.Option
+			declare string
+		}{
+			{[]cursoriterator.Option{cursoriterator.WithInsensitive()}, "INSENSITIVE CURSOR FOR"},
+			{[]cursoriterator.Option{cursoriterator.WithInsensitive(), cursoriterator.WithScroll()}, "INSENSITIVE SCROLL CURSOR FOR"},
+			{[]cursoriterator.Option{cursoriterator.WithInsensitive(), cursoriterator.WithHold()}, "INSENSITIVE CURSOR WITH HOLD FOR"},
+		} {
+			connector, users := newUsersConnector(3)
+			values := make([]User, 2)
+			iter, err := cursoriterator.NewCursorIteratorWithOptions(connector, values, test.options, "SELECT * FROM users")
+			require.NoError(t, err)
+			expectValues(t, iter, values, users...)
+			require.NoError(t, iter.Close(context.Background()))
+			require.Equal(t, `DECLARE "`+iter.CursorName()+`" `+test.declare+" SELECT * FROM users", connector.Statements()[1])
+		}
+	})
+
+	t.Run("conflicting options", func(t *testing.T) {
+		t.Parallel()
+		_, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+			&pgxpool.Pool{},
+			2,
+			[]cursoriterator.Option{cursoriterator.WithInsensitive(), cursoriterator.WithPgBouncerCompat("id")},
+			"SELECT * FROM users",
+		)
+		require.EqualError(t, err, "pgbouncer compat cannot be used with insensitive")
+
+		connector, _ := newUsersConnector(3)
+		iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+			connector,
+			1,
+			[]cursoriterator.Option{cursoriterator.WithInsensitive()},
+			"SELECT * FROM users",
+		)
+		require.NoError(t, err)
+		require.True(t, iter.Next(context.Background()))
+		_, err = iter.ExecCurrentOf(context.Background(), "DELETE FROM users")
+		require.EqualError(t, err, "WHERE CURRENT OF cannot be used with insensitive")
+		require.NoError(t, iter.Close(context.Background()))
+	})
+
+	t.Run("database", func(t *testing.T) {
+		t.Parallel()
+		runTest(t, []User{{1, "Joe"}, {2, "Alice"}, {3, "Bob"}}, func(pool *pgxpool.Pool) {
+			values := make([]User, 2)
+			iter, err := cursoriterator.NewCursorIteratorWithOptions(
+				pool,
+				values,
+				[]cursoriterator.Option{cursoriterator.WithInsensitive(), cursoriterator.WithScroll()},
+				"SELECT * FROM users ORDER BY id",
+			)
+			require.NoError(t, err)
+			expectValues(t, iter, values, User{1, "Joe"}, User{2, "Alice"}, User{3, "Bob"})
+			require.NoError(t, iter.Close(context.Background()))
+		})
+	})
+}
+
+func BenchmarkResultFormat(b *testing.B) {
+	runTest(b, nil, func(pool *pgxpool.Pool) {
+		for _, format := range []struct {
+			name string
+			code int16
+		}{{"text", pgx.TextFormatCode}, {"binary", pgx.BinaryFormatCode}} {
+			format := format
+			b.Run(format.name, func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[Measurement](
+						pool,
+						1024,
+						[]cursoriterator.Option{cursoriterator.WithResultFormat(pgx.QueryResultFormats{format.code})},
+						measurementsQuery, 10000,
+					)
+					require.NoError(b, err)
+					for iter.Next(context.Background()) {
+					}
+					require.NoError(b, iter.Error())
+					require.NoError(b, iter.Close(context.Background()))
+				}
+			})
+		}
+	})
+}
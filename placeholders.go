@@ -0,0 +1,89 @@
+package cursoriterator
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// WithPlaceholderValidation validates that the number of arguments matches the placeholders ($1, $2, ...)
+// of the query when the iterator is created, instead of failing with the first Next() call.
+// Placeholders inside string literals, quoted identifiers, dollar quoted strings and comments are ignored.
+// If the query can not be parsed (e.g. an unterminated literal), the validation is skipped.
+func WithPlaceholderValidation() Option {
+	return func(iter *CursorIterator) error {
+		placeholders, ok := countPlaceholders(iter.query)
+		if !ok || placeholders == len(iter.args) {
+			return nil
+		}
+		return errors.Errorf("query has %d placeholders but %d args provided", placeholders, len(iter.args))
+	}
+}
+
+// countPlaceholders returns the highest placeholder number used in query.
+// ok is false if the query could not be parsed.
+func countPlaceholders(query string) (count int, ok bool) {
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; {
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(query[i+1:], c)
+			if end < 0 {
+				return 0, false
+			}
+			// doubled quotes are escapes, they are handled by the next iteration
+			i += end + 1
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				return count, true
+			}
+			i += end
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return 0, false
+			}
+			i += end + 3
+		case c == '$':
+			j := i + 1
+			for j < len(query) && query[j] >= '0' && query[j] <= '9' {
+				j++
+			}
+			if j > i+1 {
+				// placeholder
+				n := 0
+				for _, d := range query[i+1 : j] {
+					n = n*10 + int(d-'0')
+				}
+				if n > count {
+					count = n
+				}
+				i = j - 1
+				continue
+			}
+			// dollar quoted string: $$...$$ or $tag$...$tag$
+			for j < len(query) && isTagChar(query[j]) {
+				j++
+			}
+			if j >= len(query) || query[j] != '$' || (j > i+1 && !isTagStart(query[i+1])) {
+				// not a dollar quote, e.g. part of an identifier
+				continue
+			}
+			tag := query[i : j+1]
+			end := strings.Index(query[j+1:], tag)
+			if end < 0 {
+				return 0, false
+			}
+			i = j + end + len(tag)
+		}
+	}
+	return count, true
+}
+
+func isTagStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isTagChar(c byte) bool {
+	return isTagStart(c) || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,55 @@
+package cursoriterator_test
+
+import (
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+
+	cursoriterator "github.com/Eun/go-pgx-cursor-iterator/v2"
+)
+
+func TestWithPlaceholderValidation(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		query string
+		args  []interface{}
+		err   string
+	}{
+		{"no placeholders", "SELECT * FROM users", nil, ""},
+		{"matched", "SELECT * FROM users WHERE id > $1 AND name <> $2", []interface{}{1, "Joe"}, ""},
+		{"reused placeholder", "SELECT * FROM users WHERE id = $1 OR id = $1 + 1", []interface{}{1}, ""},
+		{"too few args", "SELECT * FROM users WHERE id > $1 AND name <> $2", []interface{}{1}, "query has 2 placeholders but 1 args provided"},
+		{"too many args", "SELECT * FROM users WHERE id > $1 AND name <> $2", []interface{}{1, "Joe", 3}, "query has 2 placeholders but 3 args provided"},
+		{"string literal", "SELECT * FROM users WHERE name = '$1' AND id > $1", []interface{}{1}, ""},
+		{"escaped quote", "SELECT * FROM users WHERE name = 'it''s $2' AND id > $1", []interface{}{1}, ""},
+		{"quoted identifier", `SELECT "$2" FROM users WHERE id > $1`, []interface{}{1}, ""},
+		{"dollar quoted", "SELECT $$ $2 $$ FROM users WHERE id > $1", []interface{}{1}, ""},
+		{"tagged dollar quoted", "SELECT $tag$ $$ $2 $tag$ FROM users WHERE id > $1", []interface{}{1}, ""},
+		{"line comment", "SELECT * FROM users -- $2\nWHERE id > $1", []interface{}{1}, ""},
+		{"block comment", "SELECT * FROM users /* $2 */ WHERE id > $1", []interface{}{1}, ""},
+		{"unterminated literal", "SELECT * FROM users WHERE name = '$1", nil, ""},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			iter, err := cursoriterator.NewCursorIteratorWithOptions(
+				&pgxpool.Pool{},
+				make([]User, 2),
+				[]cursoriterator.Option{cursoriterator.WithPlaceholderValidation()},
+				test.query,
+				test.args...,
+			)
+			if test.err == "" {
+				require.NoError(t, err)
+				require.NotNil(t, iter)
+				return
+			}
+			require.EqualError(t, err, test.err)
+			require.Nil(t, iter)
+		})
+	}
+}
@@ -0,0 +1,305 @@
+package cursoriterator
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pkg/errors"
+)
+
+// WithBufferDepth lets the iterator fetch the next batches in the background while the current batch is consumed.
+// The fetched batches are stored in a ring buffer of depth batches (each with the capacity of values), so the
+// fetcher can run up to depth batches ahead of the consumer. If the ring buffer is full the fetcher waits until the
+// consumer took the next batch. This smooths out a variable latency of the database at the cost of memory.
+//
+// The background fetches do not use the context passed to Next(), the context is only used to wait for the next
+// batch. Close() waits for a running background fetch to finish before the transaction is rolled back, if the context
+// passed to Close() is done or Cancel() was called the background fetch will be canceled.
+// WithBufferDepth can not be combined with WithAdaptiveBatch(), WithSkipScanErrors() and WithAdvisoryLock(),
+// ExecCurrentOf(), CursorInfo() and ExportSnapshot() can not be used, because all of them use the transaction between
+// the fetches.
+func WithBufferDepth(depth int) Option {
+	return func(iter *CursorIterator) error {
+		if depth <= 0 {
+			return errors.New("buffer depth must be bigger than 0")
+		}
+		iter.bufferDepth = depth
+		return nil
+	}
+}
+
+// validateBufferDepth checks whether the options that were applied can be used together with WithBufferDepth().
+func (iter *CursorIterator) validateBufferDepth() error {
+	if iter.bufferDepth == 0 {
+		return nil
+	}
+	switch {
+	case iter.adaptiveMaxFetchSize != 0:
+		return errors.New("buffer depth cannot be used with adaptive batches")
+	case iter.skipScanErrors != nil:
+		return errors.New("buffer depth cannot be used with skip scan errors")
+	case iter.lockRows != nil:
+		return errors.New("buffer depth cannot be used with advisory locks")
+	}
+	return nil
+}
+
+// prefetcher fetches batches in the background into a ring buffer, see WithBufferDepth().
+type prefetcher struct {
+	// batches contains the fetched batches in the order of the cursor, it is closed after the last batch.
+	batches chan prefetchedBatch
+	// free contains the buffers that can be fetched into.
+	free chan reflect.Value
+
+	stop    chan struct{}
+	stopped chan struct{}
+	// cancel cancels the context of the background fetches.
+	cancel context.CancelFunc
+}
+
+// prefetchedBatch is a batch that was fetched by the prefetcher.
+type prefetchedBatch struct {
+	buffer     reflect.Value
+	n          int
+	requested  int
+	commandTag pgconn.CommandTag
+	// fields are the column descriptions of the rows.
+	fields []pgconn.FieldDescription
+	// partial reports whether err is a scan error, in that case buffer contains the n rows scanned before.
+	partial bool
+	err     error
+}
+
+// startPrefetch starts fetching the batches in the background.
+func (iter *CursorIterator) startPrefetch() {
+	p := &prefetcher{
+		batches: make(chan prefetchedBatch, iter.bufferDepth),
+		free:    make(chan reflect.Value, iter.bufferDepth),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	valuesType := reflect.TypeOf(iter.valuesRef)
+	for i := 0; i < iter.bufferDepth; i++ {
+		p.free <- reflect.MakeSlice(valuesType, len(iter.values), len(iter.values))
+	}
+
+	// only the last fetch can be smaller than the fetch size (if WithLimit() is used), build both queries upfront
+	// so the fetcher does not reference the iterator
+	fetchSize := iter.fetchSize
+	lastFetchSize := fetchSize
+	if iter.limit > 0 && iter.limit%int64(fetchSize) != 0 {
+		lastFetchSize = int(iter.limit % int64(fetchSize))
+	}
+	queries := map[int]string{
+		fetchSize:     iter.buildFetchQuery(fetchForward, fetchSize),
+		lastFetchSize: iter.buildFetchQuery(fetchForward, lastFetchSize),
+	}
+
+	// the fetches are canceled by Cancel() or by stopPrefetch()
+	var ctx context.Context
+	ctx, p.cancel = iter.cancelable(context.Background())
+
+	iter.prefetch = p
+	go p.run(ctx, iter.tx, fetchSize, iter.limit, queries, iter.fetchArgs(), iter.scanMode)
+}
+
+// stopPrefetch stops the prefetcher and waits until a running fetch finished, the fetch will be canceled if ctx is
+// done before.
+func (iter *CursorIterator) stopPrefetch(ctx context.Context) {
+	p := iter.prefetch
+	if p == nil {
+		return
+	}
+	iter.prefetch = nil
+	close(p.stop)
+	select {
+	case <-p.stopped:
+	case <-ctx.Done():
+		p.cancel()
+		<-p.stopped
+	}
+	p.cancel()
+}
+
+func (p *prefetcher) run(
+	ctx context.Context,
+	tx pgx.Tx,
+	fetchSize int,
+	limit int64,
+	queries map[int]string,
+	args []interface{},
+	mode ScanMode,
+) {
+	defer close(p.stopped)
+	defer close(p.batches)
+
+	var fetched int64
+	for {
+		select {
+		case <-p.stop:
+			return
+		default:
+		}
+
+		count := fetchSize
+		if limit > 0 {
+			remaining := limit - fetched
+			if remaining <= 0 {
+				return
+			}
+			if remaining < int64(count) {
+				count = int(remaining)
+			}
+		}
+
+		var buffer reflect.Value
+		select {
+		case buffer = <-p.free:
+		case <-p.stop:
+			return
+		}
+
+		batch := fetchBatch(ctx, tx, queries[count], args, buffer, count, mode)
+		fetched += int64(batch.n)
+		// there are only as many buffers as batches fit into the channel, so this never blocks
+		p.batches <- batch
+		if batch.err != nil || batch.n < count {
+			return
+		}
+	}
+}
+
+// fetchBatch fetches count rows into buffer.
+func fetchBatch(
+	ctx context.Context,
+	tx pgx.Tx,
+	query string,
+	args []interface{},
+	buffer reflect.Value,
+	count int,
+	mode ScanMode,
+) prefetchedBatch {
+	batch := prefetchedBatch{buffer: buffer, requested: count}
+	rows, err := tx.Query(ctx, query, args...)
+	if err != nil {
+		batch.err = fetchError(err)
+		return batch
+	}
+	defer rows.Close()
+
+	destinations := scanDestinations(buffer)
+	scanner := pgxscan.NewRowScanner(rows)
+	for rows.Next() {
+		if batch.n >= count {
+			batch.err = errors.New("database returned more rows than expected")
+			return batch
+		}
+		if batch.n == 0 {
+			batch.fields = append([]pgconn.FieldDescription(nil), rows.FieldDescriptions()...)
+		}
+		if err := scanRow(mode, scanner, rows, destinations[batch.n]); err != nil {
+			batch.partial = true
+			batch.err = scanError(err, rows, batch.n, destinations[batch.n])
+			return batch
+		}
+		batch.n++
+	}
+	if err := rows.Err(); err != nil {
+		batch.err = fetchError(err)
+		return batch
+	}
+	batch.commandTag = rows.CommandTag()
+	return batch
+}
+
+// fetchError converts an error of a fetch that bypasses fetchNextRows() like fetchNextRows() does.
+func fetchError(err error) error {
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return nil
+	case isContextError(err):
+		return err
+	case isConnectionLost(err):
+		return fmt.Errorf("%w: %w", ErrCursorLost, err)
+	case isTransactionAborted(err):
+		return fmt.Errorf("%w: %w", ErrTransactionAborted, err)
+	default:
+		return errors.Wrap(err, "unable to fetch rows")
+	}
+}
+
+// nextPrefetchedBatch takes the next batch of the prefetcher and makes it the current batch.
+func (iter *CursorIterator) nextPrefetchedBatch(ctx context.Context) {
+	var batch prefetchedBatch
+	var ok bool
+	select {
+	case batch, ok = <-iter.prefetch.batches:
+	case <-ctx.Done():
+		iter.err = ctx.Err()
+		return
+	}
+	if !ok {
+		iter.close(ctx)
+		return
+	}
+
+	if batch.n > 0 {
+		// the last batch gets overwritten
+		iter.snapshotLen = 0
+		if iter.scanMode == scanModeRaw {
+			iter.rawFields = batch.fields
+		}
+	}
+	swapValues(reflect.ValueOf(iter.valuesRef), batch.buffer, batch.n)
+	// the buffer is returned before the batch is consumed, the values were swapped into the values of the iterator
+	iter.prefetch.free <- batch.buffer
+
+	if batch.fields != nil {
+		if err := iter.checkSchema(batch.fields); err != nil {
+			iter.close(ctx)
+			iter.err = err
+			return
+		}
+	}
+
+	if batch.err != nil {
+		iter.close(ctx)
+		iter.err = batch.err
+		if batch.partial {
+			iter.partialLen = batch.n
+		}
+		return
+	}
+	iter.lastCommandTag = batch.commandTag
+	if batch.n == 0 {
+		iter.close(ctx)
+		return
+	}
+	iter.position += int64(batch.n)
+	if iter.afterFetch != nil {
+		if err := iter.afterFetch(batch.n); err != nil {
+			iter.close(ctx)
+			iter.err = errors.Wrap(err, "after fetch hook failed")
+			return
+		}
+	}
+	iter.fetchedRows += int64(batch.n)
+	iter.hasMore = batch.n >= batch.requested && (iter.limit == 0 || iter.fetchedRows < iter.limit)
+	iter.valuesPos = 0
+	iter.valuesMaxPos = batch.n
+}
+
+// swapValues swaps the first n elements of the slices a and b.
+// Swapping instead of copying keeps pointer elements (e.g. []*User) exclusive to one of the slices.
+func swapValues(a, b reflect.Value, n int) {
+	tmp := reflect.New(a.Type().Elem()).Elem()
+	for i := 0; i < n; i++ {
+		tmp.Set(a.Index(i))
+		a.Index(i).Set(b.Index(i))
+		b.Index(i).Set(tmp)
+	}
+}
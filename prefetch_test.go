@@ -0,0 +1,346 @@
+package cursoriterator_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+
+	cursoriterator "github.com/Eun/go-pgx-cursor-iterator/v2"
+	"github.com/Eun/go-pgx-cursor-iterator/v2/cursoriteratortest"
+)
+
+func newUsersConnector(n int) (*cursoriteratortest.Connector, []User) {
+	users := make([]User, n)
+	rows := make([][]interface{}, n)
+	for i := range users {
+		users[i] = User{ID: i + 1, Name: fmt.Sprintf("user%d", i+1)}
+		rows[i] = []interface{}{users[i].ID, users[i].Name}
+	}
+	return cursoriteratortest.NewConnector([]string{"id", "name"}, rows...), users
+}
+
+func TestWithBufferDepth(t *testing.T) {
+	t.Parallel()
+
+	for _, depth := range []int{1, 2, 3, 8} {
+		for _, batchSize := range []int{1, 2, 3} {
+			for _, rowCount := range []int{0, 1, 5, 6, 10} {
+				depth, batchSize, rowCount := depth, batchSize, rowCount
+				t.Run(fmt.Sprintf("depth %d batch %d rows %d", depth, batchSize, rowCount), func(t *testing.T) {
+					t.Parallel()
+					connector, users := newUsersConnector(rowCount)
+					iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+						connector,
+						batchSize,
+						[]cursoriterator.Option{cursoriterator.WithBufferDepth(depth)},
+						"SELECT * FROM users",
+					)
+					require.NoError(t, err)
+
+					result := []User{}
+					for iter.Next(context.Background()) {
+						result = append(result, iter.Value())
+					}
+					require.NoError(t, iter.Error())
+					require.Equal(t, users, result)
+					require.False(t, iter.HasMore())
+					require.NoError(t, iter.Close(context.Background()))
+				})
+			}
+		}
+	}
+}
+
+func TestWithBufferDepthPointers(t *testing.T) {
+	t.Parallel()
+	connector, users := newUsersConnector(7)
+	iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[*User](
+		connector,
+		2,
+		[]cursoriterator.Option{cursoriterator.WithBufferDepth(2)},
+		"SELECT * FROM users",
+	)
+	require.NoError(t, err)
+
+	var result []*User
+	for iter.Next(context.Background()) {
+		result = append(result, iter.Value())
+		// the values of the current batch must not be overwritten by the background fetches
+		time.Sleep(time.Millisecond)
+		require.Equal(t, users[len(result)-1], *iter.Value())
+	}
+	require.NoError(t, iter.Error())
+	require.Len(t, result, len(users))
+	require.NoError(t, iter.Close(context.Background()))
+}
+
+func TestWithBufferDepthLimit(t *testing.T) {
+	t.Parallel()
+	connector, users := newUsersConnector(10)
+	iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+		connector,
+		3,
+		[]cursoriterator.Option{cursoriterator.WithBufferDepth(2), cursoriterator.WithLimit(7)},
+		"SELECT * FROM users",
+	)
+	require.NoError(t, err)
+
+	var result []User
+	for iter.Next(context.Background()) {
+		result = append(result, iter.Value())
+	}
+	require.NoError(t, iter.Error())
+	require.Equal(t, users[:7], result)
+	require.NoError(t, iter.Close(context.Background()))
+
+	var fetches []string
+	for _, statement := range connector.Statements() {
+		if strings.HasPrefix(statement, "FETCH ") {
+			fetches = append(fetches, strings.SplitN(statement, " IN ", 2)[0])
+		}
+	}
+	require.Equal(t, []string{"FETCH FORWARD 3", "FETCH FORWARD 3", "FETCH FORWARD 1"}, fetches)
+}
+
+func TestWithBufferDepthFetchError(t *testing.T) {
+	t.Parallel()
+	connector, users := newUsersConnector(10)
+	connector.FetchErr = func(fetch int) error {
+		if fetch == 3 {
+			return errors.New("connection reset")
+		}
+		return nil
+	}
+	iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+		connector,
+		2,
+		[]cursoriterator.Option{cursoriterator.WithBufferDepth(3)},
+		"SELECT * FROM users",
+	)
+	require.NoError(t, err)
+
+	var result []User
+	for iter.Next(context.Background()) {
+		result = append(result, iter.Value())
+	}
+	require.Equal(t, users[:4], result)
+	require.EqualError(t, iter.Error(), "unable to fetch rows: connection reset")
+	require.Equal(t, -1, iter.ValueIndex())
+	require.Error(t, iter.Close(context.Background()))
+}
+
+func TestWithBufferDepthClose(t *testing.T) {
+	t.Parallel()
+	connector, _ := newUsersConnector(100)
+	iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+		connector,
+		2,
+		[]cursoriterator.Option{cursoriterator.WithBufferDepth(3)},
+		"SELECT * FROM users",
+	)
+	require.NoError(t, err)
+	require.True(t, iter.Next(context.Background()))
+	require.NoError(t, iter.Close(context.Background()))
+
+	// the fetcher stopped before the transaction was rolled back
+	statements := connector.Statements()
+	require.Equal(t, "ROLLBACK", statements[len(statements)-1])
+	// the fetcher runs at most depth batches ahead of the consumer
+	require.LessOrEqual(t, len(statements), 1+1+1+3+1)
+	require.False(t, iter.Next(context.Background()))
+}
+
+func TestWithBufferDepthWaitCanceled(t *testing.T) {
+	t.Parallel()
+	connector, users := newUsersConnector(4)
+	release := make(chan struct{})
+	connector.FetchErr = func(fetch int) error {
+		if fetch == 2 {
+			<-release
+		}
+		return nil
+	}
+	iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+		connector,
+		2,
+		[]cursoriterator.Option{cursoriterator.WithBufferDepth(1)},
+		"SELECT * FROM users",
+	)
+	require.NoError(t, err)
+	require.True(t, iter.Next(context.Background()))
+	require.True(t, iter.Next(context.Background()))
+
+	// the second batch is still being fetched
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.False(t, iter.Next(ctx))
+	require.ErrorIs(t, iter.Error(), context.DeadlineExceeded)
+
+	// continue with another context
+	close(release)
+	require.True(t, iter.Next(context.Background()))
+	require.Equal(t, users[2], iter.Value())
+	require.True(t, iter.Next(context.Background()))
+	require.Equal(t, users[3], iter.Value())
+	require.False(t, iter.Next(context.Background()))
+	require.NoError(t, iter.Error())
+	require.NoError(t, iter.Close(context.Background()))
+}
+
+// hangFetches lets every fetch of the connector after the first one block until its context is done.
+func hangFetches(connector *cursoriteratortest.Connector) {
+	var fetches int32
+	connector.OnQuery = func(ctx context.Context, next cursoriteratortest.QueryFunc, sql string, args ...interface{}) (pgx.Rows, error) {
+		if strings.HasPrefix(sql, "FETCH ") && atomic.AddInt32(&fetches, 1) > 1 {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return next(ctx, sql, args...)
+	}
+}
+
+func TestWithBufferDepthHangingFetch(t *testing.T) {
+	t.Parallel()
+
+	start := func(t *testing.T) *cursoriterator.TypedCursorIterator[User] {
+		connector, _ := newUsersConnector(10)
+		hangFetches(connector)
+		iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+			connector,
+			2,
+			[]cursoriterator.Option{cursoriterator.WithBufferDepth(1)},
+			"SELECT * FROM users",
+		)
+		require.NoError(t, err)
+		require.True(t, iter.Next(context.Background()))
+		return iter
+	}
+	closed := func(t *testing.T, closeFn func()) {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			closeFn()
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("close is blocked by the background fetch")
+		}
+	}
+
+	t.Run("close context", func(t *testing.T) {
+		t.Parallel()
+		iter := start(t)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		closed(t, func() {
+			_ = iter.Close(ctx)
+		})
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		t.Parallel()
+		iter := start(t)
+		iter.Cancel()
+		var err error
+		closed(t, func() {
+			err = iter.Close(context.Background())
+		})
+		require.NoError(t, err)
+	})
+}
+
+func TestWithBufferDepthExportSnapshot(t *testing.T) {
+	t.Parallel()
+	connector, _ := newUsersConnector(3)
+	iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+		connector,
+		2,
+		[]cursoriterator.Option{cursoriterator.WithBufferDepth(1)},
+		"SELECT * FROM users",
+	)
+	require.NoError(t, err)
+	defer iter.Close(context.Background())
+	require.True(t, iter.Next(context.Background()))
+	_, err = iter.ExportSnapshot(context.Background())
+	require.EqualError(t, err, "snapshot cannot be exported with buffer depth")
+}
+
+func TestWithBufferDepthOptions(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		options []cursoriterator.Option
+		err     string
+	}{
+		{[]cursoriterator.Option{cursoriterator.WithBufferDepth(0)}, "buffer depth must be bigger than 0"},
+		{
+			[]cursoriterator.Option{cursoriterator.WithBufferDepth(1), cursoriterator.WithAdaptiveBatch(1, 2)},
+			"buffer depth cannot be used with adaptive batches",
+		},
+		{
+			[]cursoriterator.Option{
+				cursoriterator.WithSkipScanErrors(func(int64, error) {}), cursoriterator.WithBufferDepth(1),
+			},
+			"buffer depth cannot be used with skip scan errors",
+		},
+		{
+			[]cursoriterator.Option{
+				cursoriterator.WithBufferDepth(1),
+				cursoriterator.WithAdvisoryLock(func(u User) int64 { return int64(u.ID) }),
+			},
+			"buffer depth cannot be used with advisory locks",
+		},
+	}
+	for _, test := range tests {
+		connector, _ := newUsersConnector(1)
+		_, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](connector, 2, test.options, "SELECT * FROM users")
+		require.EqualError(t, err, test.err)
+	}
+}
+
+func BenchmarkBufferDepth(b *testing.B) {
+	const (
+		rowCount     = 200
+		batchSize    = 10
+		fetchLatency = time.Millisecond
+		batchWork    = time.Millisecond
+	)
+	for _, depth := range []int{0, 1, 2, 4} {
+		depth := depth
+		b.Run(fmt.Sprint(depth), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				connector, _ := newUsersConnector(rowCount)
+				connector.FetchErr = func(int) error {
+					time.Sleep(fetchLatency)
+					return nil
+				}
+				var options []cursoriterator.Option
+				if depth > 0 {
+					options = append(options, cursoriterator.WithBufferDepth(depth))
+				}
+				iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+					connector,
+					batchSize,
+					options,
+					"SELECT * FROM users",
+				)
+				require.NoError(b, err)
+				for iter.Next(context.Background()) {
+					if iter.ValueIndex() == 0 {
+						// simulate processing the batch
+						time.Sleep(batchWork)
+					}
+				}
+				require.NoError(b, iter.Error())
+				require.NoError(b, iter.Close(context.Background()))
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package cursoriterator
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Profile contains the timing distribution of the fetches of an iterator and the time spent starting its transactions.
+// It will only be recorded if WithProfiling() is used.
+type Profile struct {
+	// Fetches is the number of fetches, including the final fetch that returned no rows.
+	Fetches int
+	// Rows is the total number of fetched rows.
+	Rows int64
+	// MinFetchLatency is the duration of the fastest fetch.
+	MinFetchLatency time.Duration
+	// MaxFetchLatency is the duration of the slowest fetch.
+	MaxFetchLatency time.Duration
+	// AvgFetchLatency is the average duration of a fetch.
+	AvgFetchLatency time.Duration
+	// TotalFetchLatency is the sum of the durations of all fetches.
+	TotalFetchLatency time.Duration
+	// AcquireDuration is the sum of the durations of starting the transactions, which includes acquiring a connection
+	// from the pool. It is not part of the fetch latencies, so pool contention can be told apart from slow queries.
+	AcquireDuration time.Duration
+}
+
+// WithProfiling records the latency of every fetch and the number of fetched rows,
+// the result can be retrieved with Profile().
+// Use it to find the optimal batch size for a query.
+func WithProfiling() Option {
+	return func(iter *CursorIterator) error {
+		iter.profiling = true
+		return nil
+	}
+}
+
+// Profile returns the recorded profile of the iterator.
+// It returns an empty profile if WithProfiling() is not used.
+func (iter *CursorIterator) Profile() Profile {
+	iter.mu.Lock()
+	defer iter.mu.Unlock()
+	profile := iter.profile
+	if profile.Fetches > 0 {
+		profile.AvgFetchLatency = profile.TotalFetchLatency / time.Duration(profile.Fetches)
+	}
+	return profile
+}
+
+// recordProfile adds a fetch to the profile.
+func (iter *CursorIterator) recordProfile(rows int, d time.Duration) {
+	if !iter.profiling {
+		return
+	}
+	if iter.profile.Fetches == 0 || d < iter.profile.MinFetchLatency {
+		iter.profile.MinFetchLatency = d
+	}
+	if d > iter.profile.MaxFetchLatency {
+		iter.profile.MaxFetchLatency = d
+	}
+	iter.profile.Fetches++
+	iter.profile.Rows += int64(rows)
+	iter.profile.TotalFetchLatency += d
+}
+
+// recordAcquire adds the duration of starting a transaction that started at start to the profile.
+func (iter *CursorIterator) recordAcquire(start time.Time) {
+	if !iter.profiling {
+		return
+	}
+	iter.profile.AcquireDuration += time.Since(start)
+}
+
+// latencyState is the state of WithFetchLatencies().
+type latencyState struct {
+	enabled    bool
+	maxSamples int
+	// samples is a ring buffer once maxSamples is reached, start is the index of the oldest sample.
+	samples []time.Duration
+	start   int
+}
+
+// WithFetchLatencies records the duration of every fetch, the durations can be retrieved with FetchLatencies(),
+// e.g. to compute percentiles. If maxSamples is bigger than 0 only the latest maxSamples durations will be kept,
+// otherwise all durations will be kept.
+func WithFetchLatencies(maxSamples int) Option {
+	return func(iter *CursorIterator) error {
+		if maxSamples < 0 {
+			return errors.New("max samples cannot be negative")
+		}
+		iter.latency.enabled = true
+		iter.latency.maxSamples = maxSamples
+		return nil
+	}
+}
+
+// FetchLatencies returns the recorded durations of the fetches in the order they were performed,
+// including the final fetch that returned no rows.
+// It returns nil if WithFetchLatencies() is not used.
+func (iter *CursorIterator) FetchLatencies() []time.Duration {
+	iter.mu.Lock()
+	defer iter.mu.Unlock()
+	if !iter.latency.enabled {
+		return nil
+	}
+	latencies := make([]time.Duration, 0, len(iter.latency.samples))
+	latencies = append(latencies, iter.latency.samples[iter.latency.start:]...)
+	return append(latencies, iter.latency.samples[:iter.latency.start]...)
+}
+
+// recordLatency adds the duration of a fetch to the recorded latencies.
+func (iter *CursorIterator) recordLatency(d time.Duration) {
+	if !iter.latency.enabled {
+		return
+	}
+	if iter.latency.maxSamples == 0 || len(iter.latency.samples) < iter.latency.maxSamples {
+		iter.latency.samples = append(iter.latency.samples, d)
+		return
+	}
+	iter.latency.samples[iter.latency.start] = d
+	iter.latency.start = (iter.latency.start + 1) % len(iter.latency.samples)
+}
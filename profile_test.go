@@ -0,0 +1,169 @@
+package cursoriterator_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+
+	cursoriterator "github.com/Eun/go-pgx-cursor-iterator/v2"
+	"github.com/Eun/go-pgx-cursor-iterator/v2/cursoriteratortest"
+)
+
+func TestProfile(t *testing.T) {
+	t.Parallel()
+
+	newConnector := func() *cursoriteratortest.Connector {
+		return cursoriteratortest.NewConnector(
+			[]string{"id", "name"},
+			[]interface{}{1, "Joe"},
+			[]interface{}{2, "Alice"},
+			[]interface{}{3, "Bob"},
+		)
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		t.Parallel()
+		iter, err := cursoriterator.NewTypedCursorIterator[User](newConnector(), 2, "SELECT * FROM users")
+		require.NoError(t, err)
+		for iter.Next(context.Background()) {
+		}
+		require.NoError(t, iter.Error())
+		require.Equal(t, cursoriterator.Profile{}, iter.Profile())
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		t.Parallel()
+		iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+			newConnector(),
+			2,
+			[]cursoriterator.Option{cursoriterator.WithProfiling()},
+			"SELECT * FROM users",
+		)
+		require.NoError(t, err)
+		for iter.Next(context.Background()) {
+		}
+		require.NoError(t, iter.Error())
+
+		profile := iter.Profile()
+		require.Equal(t, 3, profile.Fetches)
+		require.Equal(t, int64(3), profile.Rows)
+		require.LessOrEqual(t, profile.MinFetchLatency, profile.AvgFetchLatency)
+		require.LessOrEqual(t, profile.AvgFetchLatency, profile.MaxFetchLatency)
+		require.Equal(t, profile.TotalFetchLatency/3, profile.AvgFetchLatency)
+	})
+}
+
+func TestProfileAcquireDuration(t *testing.T) {
+	t.Parallel()
+	const delay = 5 * time.Millisecond
+	connector, users := newUsersConnector(3)
+	// like a pool that has no idle connection
+	connector.OnBegin = func(context.Context) error {
+		time.Sleep(delay)
+		return nil
+	}
+	values := make([]User, 2)
+	iter, err := cursoriterator.NewCursorIteratorWithOptions(
+		connector,
+		values,
+		[]cursoriterator.Option{cursoriterator.WithProfiling()},
+		"SELECT * FROM users",
+	)
+	require.NoError(t, err)
+	require.Zero(t, iter.Profile().AcquireDuration)
+
+	require.True(t, iter.Next(context.Background()))
+	acquired := iter.Profile().AcquireDuration
+	require.GreaterOrEqual(t, acquired, delay)
+
+	// the transaction is started once
+	expectValues(t, iter, values, users[1:]...)
+	require.Equal(t, acquired, iter.Profile().AcquireDuration)
+	require.NoError(t, iter.Close(context.Background()))
+}
+
+func TestFetchLatencies(t *testing.T) {
+	t.Parallel()
+
+	iterate := func(t *testing.T, options ...cursoriterator.Option) *cursoriterator.TypedCursorIterator[User] {
+		connector, _ := newUsersConnector(9)
+		iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](connector, 2, options, "SELECT * FROM users")
+		require.NoError(t, err)
+		for iter.Next(context.Background()) {
+		}
+		require.NoError(t, iter.Error())
+		return iter
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		t.Parallel()
+		require.Nil(t, iterate(t).FetchLatencies())
+	})
+
+	t.Run("all samples", func(t *testing.T) {
+		t.Parallel()
+		iter := iterate(t, cursoriterator.WithFetchLatencies(0), cursoriterator.WithProfiling())
+		latencies := iter.FetchLatencies()
+		// 4 full batches, the short batch and the final fetch that returned no rows
+		require.Len(t, latencies, 6)
+		require.Equal(t, iter.Profile().Fetches, len(latencies))
+
+		var total time.Duration
+		for _, d := range latencies {
+			total += d
+		}
+		require.Equal(t, iter.Profile().TotalFetchLatency, total)
+	})
+
+	t.Run("limited samples", func(t *testing.T) {
+		t.Parallel()
+		iter := iterate(t, cursoriterator.WithFetchLatencies(3), cursoriterator.WithProfiling())
+		require.Len(t, iter.FetchLatencies(), 3)
+		require.Equal(t, 6, iter.Profile().Fetches)
+	})
+
+	t.Run("negative samples", func(t *testing.T) {
+		t.Parallel()
+		_, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+			cursoriteratortest.NewConnector([]string{"id", "name"}),
+			2,
+			[]cursoriterator.Option{cursoriterator.WithFetchLatencies(-1)},
+			"SELECT * FROM users",
+		)
+		require.EqualError(t, err, "max samples cannot be negative")
+	})
+}
+
+func BenchmarkBatchSizes(b *testing.B) {
+	runTest(b, nil, func(pool *pgxpool.Pool) {
+		_, err := pool.Exec(context.Background(), "INSERT INTO users SELECT i, 'user' || i FROM generate_series(1, 100000) i")
+		require.NoError(b, err)
+
+		for _, size := range []int{10, 100, 1000, 10000} {
+			size := size
+			b.Run(fmt.Sprint(size), func(b *testing.B) {
+				var profile cursoriterator.Profile
+				for i := 0; i < b.N; i++ {
+					iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+						pool,
+						size,
+						[]cursoriterator.Option{cursoriterator.WithProfiling()},
+						"SELECT * FROM users",
+					)
+					require.NoError(b, err)
+					for iter.Next(context.Background()) {
+					}
+					require.NoError(b, iter.Error())
+					require.NoError(b, iter.Close(context.Background()))
+					profile = iter.Profile()
+				}
+				b.ReportMetric(float64(profile.Fetches), "fetches/op")
+				b.ReportMetric(float64(profile.AvgFetchLatency.Microseconds()), "us/fetch")
+			})
+		}
+	})
+}
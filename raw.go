@@ -0,0 +1,101 @@
+package cursoriterator
+
+import (
+	"reflect"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pkg/errors"
+)
+
+// RawIterator is a CursorIterator that returns the raw column values of the rows without decoding them.
+// It will be returned by NewRawIterator().
+type RawIterator struct {
+	*CursorIterator
+	values [][][]byte
+}
+
+// NewRawIterator can be used to create an iterator that returns every row as the raw bytes of its columns,
+// as sent by the database. Neither pgx nor scany decode the values, which makes it the fastest way to pass rows
+// through without interpreting them (e.g. in a proxy).
+// The values are in the format described by FieldDescriptions(), which is the binary format for most types,
+// WithResultFormat() can be used to request a specific format. NULL values are nil.
+//
+// Example Usage:
+//
+//	iter, err := NewRawIterator(pool, 1000, "SELECT * FROM users WHERE role = $1", "Guest")
+//	if err != nil {
+//		panic(err)
+//	}
+//	defer iter.Close(ctx)
+//	for iter.Next(ctx) {
+//		forward(iter.FieldDescriptions(), iter.Value())
+//	}
+//	if err := iter.Error(); err != nil {
+//		panic(err)
+//	}
+func NewRawIterator(
+	connector PgxConnector,
+	batchSize int,
+	query string, args ...interface{},
+) (*RawIterator, error) {
+	return NewRawIteratorWithOptions(connector, batchSize, nil, query, args...)
+}
+
+// NewRawIteratorWithOptions works like NewRawIterator() but additionally accepts options to configure the iterator.
+// WithScanMode() and WithScanConcurrency() can not be used.
+func NewRawIteratorWithOptions(
+	connector PgxConnector,
+	batchSize int,
+	options []Option,
+	query string, args ...interface{},
+) (*RawIterator, error) {
+	if connector == nil {
+		return nil, errors.New("connector cannot be nil")
+	}
+	if batchSize <= 0 {
+		return nil, errors.New("batch size must be bigger than 0")
+	}
+
+	values := make([][][]byte, batchSize)
+	valuesSlice := scanDestinations(reflect.ValueOf(values))
+
+	options = append([]Option{withRawScanMode()}, options...)
+	iter, err := newCursorIterator(connector, values, valuesSlice, true, options, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if iter.scanConcurrency > 1 {
+		return nil, errors.New("raw iterator cannot be used with scan concurrency")
+	}
+	return &RawIterator{
+		CursorIterator: iter,
+		// the buffer might have been replaced by WithBufferPool()
+		values: iter.valuesRef.([][][]byte),
+	}, nil
+}
+
+// withRawScanMode lets the iterator store the raw column values, see NewRawIterator().
+func withRawScanMode() Option {
+	return func(iter *CursorIterator) error {
+		iter.scanMode = scanModeRaw
+		return nil
+	}
+}
+
+// Value returns the raw column values of the current row.
+// If there is no current value (Next() was not called or returned false) nil will be returned.
+func (iter *RawIterator) Value() [][]byte {
+	i := iter.ValueIndex()
+	if i < 0 {
+		return nil
+	}
+	return iter.values[i]
+}
+
+// FieldDescriptions returns the descriptions of the columns of the current batch, which are needed to interpret
+// the raw values. It returns nil until the first row was fetched.
+func (iter *RawIterator) FieldDescriptions() []pgconn.FieldDescription {
+	iter.mu.Lock()
+	defer iter.mu.Unlock()
+	return iter.rawFields
+}
@@ -0,0 +1,141 @@
+package cursoriterator_test
+
+import (
+	"context"
+	"encoding/binary"
+	"strconv"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+
+	cursoriterator "github.com/Eun/go-pgx-cursor-iterator/v2"
+)
+
+func TestRawIterator(t *testing.T) {
+	t.Parallel()
+
+	t.Run("values", func(t *testing.T) {
+		t.Parallel()
+		connector, users := newUsersConnector(5)
+		iter, err := cursoriterator.NewRawIterator(connector, 2, "SELECT * FROM users")
+		require.NoError(t, err)
+		require.Nil(t, iter.Value())
+		require.Nil(t, iter.FieldDescriptions())
+
+		var result []User
+		for iter.Next(context.Background()) {
+			row := iter.Value()
+			require.Len(t, row, 2)
+			id, err := strconv.Atoi(string(row[0]))
+			require.NoError(t, err)
+			result = append(result, User{ID: id, Name: string(row[1])})
+		}
+		require.NoError(t, iter.Error())
+		require.Equal(t, users, result)
+		fields := iter.FieldDescriptions()
+		require.Len(t, fields, 2)
+		require.Equal(t, "id", fields[0].Name)
+		require.Equal(t, "name", fields[1].Name)
+		require.NoError(t, iter.Close(context.Background()))
+	})
+
+	t.Run("buffer depth", func(t *testing.T) {
+		t.Parallel()
+		connector, _ := newUsersConnector(5)
+		iter, err := cursoriterator.NewRawIteratorWithOptions(
+			connector, 2, []cursoriterator.Option{cursoriterator.WithBufferDepth(1)}, "SELECT * FROM users",
+		)
+		require.NoError(t, err)
+		n := 0
+		for iter.Next(context.Background()) {
+			require.Len(t, iter.Value(), 2)
+			fields := iter.FieldDescriptions()
+			require.Len(t, fields, 2)
+			require.Equal(t, "name", fields[1].Name)
+			n++
+		}
+		require.NoError(t, iter.Error())
+		require.Equal(t, 5, n)
+		require.NoError(t, iter.Close(context.Background()))
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		t.Parallel()
+		_, err := cursoriterator.NewRawIterator(nil, 2, "SELECT * FROM users")
+		require.EqualError(t, err, "connector cannot be nil")
+		_, err = cursoriterator.NewRawIterator(&pgxpool.Pool{}, 0, "SELECT * FROM users")
+		require.EqualError(t, err, "batch size must be bigger than 0")
+		_, err = cursoriterator.NewRawIteratorWithOptions(
+			&pgxpool.Pool{}, 2, []cursoriterator.Option{cursoriterator.WithScanConcurrency(2)}, "SELECT * FROM users",
+		)
+		require.EqualError(t, err, "raw iterator cannot be used with scan concurrency")
+		_, err = cursoriterator.NewRawIteratorWithOptions(
+			&pgxpool.Pool{}, 2, []cursoriterator.Option{cursoriterator.WithScanMode(cursoriterator.ScanModeMap)}, "SELECT * FROM users",
+		)
+		require.EqualError(t, err, "scan mode requires values of type []interface{}, got [][][]uint8")
+	})
+
+	t.Run("database", func(t *testing.T) {
+		t.Parallel()
+		runTest(t, []User{{1, "Joe"}, {2, "Alice"}, {3, "Bob"}}, func(pool *pgxpool.Pool) {
+			iter, err := cursoriterator.NewRawIterator(pool, 2, "SELECT id::bigint, name, NULL::text AS nothing FROM users ORDER BY id")
+			require.NoError(t, err)
+
+			var result []User
+			for iter.Next(context.Background()) {
+				fields := iter.FieldDescriptions()
+				require.Len(t, fields, 3)
+				require.Equal(t, int16(pgx.BinaryFormatCode), fields[0].Format)
+				row := iter.Value()
+				require.Len(t, row[0], 8)
+				require.Nil(t, row[2])
+				result = append(result, User{ID: int(binary.BigEndian.Uint64(row[0])), Name: string(row[1])})
+			}
+			require.NoError(t, iter.Error())
+			require.Equal(t, []User{{1, "Joe"}, {2, "Alice"}, {3, "Bob"}}, result)
+			require.NoError(t, iter.Close(context.Background()))
+
+			iter, err = cursoriterator.NewRawIteratorWithOptions(
+				pool,
+				2,
+				[]cursoriterator.Option{cursoriterator.WithResultFormat(pgx.QueryResultFormats{pgx.TextFormatCode})},
+				"SELECT id, name FROM users ORDER BY id",
+			)
+			require.NoError(t, err)
+			var ids []string
+			for iter.Next(context.Background()) {
+				ids = append(ids, string(iter.Value()[0]))
+			}
+			require.NoError(t, iter.Error())
+			require.Equal(t, []string{"1", "2", "3"}, ids)
+			require.NoError(t, iter.Close(context.Background()))
+		})
+	})
+}
+
+func BenchmarkRawIterator(b *testing.B) {
+	runTest(b, nil, func(pool *pgxpool.Pool) {
+		b.Run("raw", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				iter, err := cursoriterator.NewRawIterator(pool, 1024, wideRowsQuery, 10000)
+				require.NoError(b, err)
+				for iter.Next(context.Background()) {
+				}
+				require.NoError(b, iter.Error())
+				require.NoError(b, iter.Close(context.Background()))
+			}
+		})
+		b.Run("struct", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				iter, err := cursoriterator.NewTypedCursorIterator[WideRow](pool, 1024, wideRowsQuery, 10000)
+				require.NoError(b, err)
+				for iter.Next(context.Background()) {
+				}
+				require.NoError(b, iter.Error())
+				require.NoError(b, iter.Close(context.Background()))
+			}
+		})
+	})
+}
@@ -0,0 +1,144 @@
+package cursoriterator
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+)
+
+// NewRefCursorIterator creates an iterator that fetches the rows of a cursor that was already opened in tx,
+// e.g. a refcursor returned by a PL/pgSQL function or procedure. The iterator does not declare a cursor and does not
+// start a transaction, it fetches from the cursor with the given name in tx. Closing the iterator closes the cursor,
+// the transaction is left to the caller.
+// ctx is used to check whether the cursor exists.
+// Options that configure the transaction or the declaration of the cursor can not be used. The iterator has no query
+// of its own, so it can not be rebound, reopened or cloned and Explain(), Validate(), EstimateTotal() and
+// CountTotal() return an error.
+//
+// Example Usage:
+//
+//	var cursorName string
+//	if err := tx.QueryRow(ctx, "SELECT get_users($1)", "Guest").Scan(&cursorName); err != nil {
+//		panic(err)
+//	}
+//	values := make([]User, 1000)
+//	iter, err := NewRefCursorIterator(ctx, tx, cursorName, values, nil)
+//	if err != nil {
+//		panic(err)
+//	}
+//	defer iter.Close(ctx)
+//	for iter.Next(ctx) {
+//		fmt.Printf("Name: %s\n", values[iter.ValueIndex()].Name)
+//	}
+//	if err := iter.Error(); err != nil {
+//		panic(err)
+//	}
+func NewRefCursorIterator(
+	ctx context.Context,
+	tx pgx.Tx,
+	cursorName string,
+	values interface{},
+	options []Option,
+) (*CursorIterator, error) {
+	if tx == nil {
+		return nil, errors.New("tx cannot be nil")
+	}
+	if cursorName == "" {
+		return nil, errors.New("cursor name cannot be empty")
+	}
+	valuesSlice, err := valuesDestinations(values)
+	if err != nil {
+		return nil, err
+	}
+
+	var exists bool
+	if err := tx.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM pg_cursors WHERE name = $1)", cursorName).Scan(&exists); err != nil {
+		return nil, errors.Wrap(err, "unable to check cursor")
+	}
+	if !exists {
+		return nil, errors.Errorf("cursor %q does not exist", cursorName)
+	}
+
+	iter := newUninitializedIterator(&refCursorConnector{tx: tx, cursorName: cursorName}, "", nil)
+	iter.cursorName = cursorName
+	iter.refCursor = true
+	if err := iter.initialize(ctx, values, valuesSlice, false, options); err != nil {
+		return nil, err
+	}
+	if iter.cursorName != cursorName {
+		return nil, errors.New("ref cursor iterator cannot be used with cursor name")
+	}
+	return iter, nil
+}
+
+// validateRefCursor checks whether the options that were applied can be used with NewRefCursorIterator().
+// It runs before WithValidateQuery() would prepare the (empty) query on the transaction of the caller.
+func (iter *CursorIterator) validateRefCursor() error {
+	if !iter.refCursor {
+		return nil
+	}
+	var option string
+	switch {
+	case iter.hold:
+		option = "hold"
+	case iter.scroll, iter.skipScanErrors != nil:
+		option = "scroll"
+	case iter.insensitive:
+		option = "insensitive"
+	case iter.commit.column != "":
+		option = "commit per batch"
+	case iter.keyset.column != "":
+		option = "pgbouncer compat"
+	case iter.snapshotID != "", iter.txOptions != nil, iter.readOnly:
+		option = "transaction options"
+	case iter.statementTimeout > 0:
+		option = "statement timeout"
+	case iter.applicationName != "", iter.connectionInit != nil, iter.typeRegistration != nil:
+		option = "connection options"
+	case iter.materialization.table != "":
+		option = "materialize"
+	case iter.resumeColumn != "", iter.orderBy != "", iter.wrapSubquery:
+		option = "query options"
+	case iter.validateQueryTimeout > 0:
+		option = "validate query"
+	case iter.stopChannel != "":
+		option = "stop on notify"
+	default:
+		return nil
+	}
+	return errors.Errorf("ref cursor iterator cannot be used with %s", option)
+}
+
+// refCursorConnector returns the transaction of a ref cursor, see NewRefCursorIterator().
+type refCursorConnector struct {
+	tx         pgx.Tx
+	cursorName string
+}
+
+func (c *refCursorConnector) Begin(context.Context) (pgx.Tx, error) {
+	return &refCursorTx{Tx: c.tx, cursorName: c.cursorName}, nil
+}
+
+// refCursorTx is a transaction of the caller, ending it closes the cursor instead of the transaction.
+type refCursorTx struct {
+	pgx.Tx
+	cursorName string
+	closed     bool
+}
+
+func (tx *refCursorTx) Rollback(ctx context.Context) error {
+	if tx.closed {
+		return pgx.ErrTxClosed
+	}
+	tx.closed = true
+	if _, err := tx.Tx.Exec(ctx, fmt.Sprintf("CLOSE %s", pgx.Identifier{tx.cursorName}.Sanitize())); err != nil {
+		return errors.Wrap(err, "unable to close cursor")
+	}
+	return nil
+}
+
+func (tx *refCursorTx) Commit(ctx context.Context) error {
+	return tx.Rollback(ctx)
+}
@@ -0,0 +1,151 @@
+package cursoriterator_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+
+	cursoriterator "github.com/Eun/go-pgx-cursor-iterator/v2"
+)
+
+// cursorsTx answers the lookup of the cursor in pg_cursors, which the in-memory connector does not support.
+type cursorsTx struct {
+	pgx.Tx
+	cursors []string
+}
+
+func (tx *cursorsTx) QueryRow(_ context.Context, _ string, args ...interface{}) pgx.Row {
+	for _, cursor := range tx.cursors {
+		if cursor == args[0] {
+			return existsRow(true)
+		}
+	}
+	return existsRow(false)
+}
+
+type existsRow bool
+
+func (r existsRow) Scan(dest ...interface{}) error {
+	*dest[0].(*bool) = bool(r)
+	return nil
+}
+
+func TestNewRefCursorIterator(t *testing.T) {
+	t.Parallel()
+
+	t.Run("fetches from the cursor", func(t *testing.T) {
+		t.Parallel()
+		connector, users := newUsersConnector(5)
+		tx, err := connector.Begin(context.Background())
+		require.NoError(t, err)
+		_, err = tx.Exec(context.Background(), `DECLARE "users_cursor" CURSOR FOR SELECT * FROM users`)
+		require.NoError(t, err)
+
+		values := make([]User, 2)
+		iter, err := cursoriterator.NewRefCursorIterator(
+			context.Background(), &cursorsTx{Tx: tx, cursors: []string{"users_cursor"}}, "users_cursor", values, nil,
+		)
+		require.NoError(t, err)
+		require.Equal(t, "users_cursor", iter.CursorName())
+		require.EqualError(t, iter.Rebind(context.Background()), "ref cursor iterator cannot be rebound")
+		// these would run on the transaction of the caller and close its cursor
+		_, err = iter.Explain(context.Background())
+		require.EqualError(t, err, "ref cursor iterator cannot be explained")
+		require.EqualError(t, iter.Validate(context.Background()), "ref cursor iterator cannot be explained")
+		_, err = iter.EstimateTotal(context.Background())
+		require.EqualError(t, err, "ref cursor iterator cannot be estimated")
+		_, err = iter.CountTotal(context.Background())
+		require.EqualError(t, err, "ref cursor iterator cannot be counted")
+		_, err = iter.Clone()
+		require.EqualError(t, err, "ref cursor iterator cannot be cloned")
+		expectValues(t, iter, values, users...)
+		require.NoError(t, iter.Close(context.Background()))
+
+		// the cursor is closed, the transaction is left to the caller
+		require.Equal(t, []string{
+			"BEGIN",
+			`DECLARE "users_cursor" CURSOR FOR SELECT * FROM users`,
+			`FETCH FORWARD 2 IN "users_cursor"`,
+			`FETCH FORWARD 2 IN "users_cursor"`,
+			`FETCH FORWARD 2 IN "users_cursor"`,
+			`FETCH FORWARD 2 IN "users_cursor"`,
+			`CLOSE "users_cursor"`,
+		}, connector.Statements())
+		require.NoError(t, tx.Rollback(context.Background()))
+	})
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		t.Parallel()
+		connector, _ := newUsersConnector(1)
+		tx, err := connector.Begin(context.Background())
+		require.NoError(t, err)
+		defer tx.Rollback(context.Background())
+		cursors := &cursorsTx{Tx: tx, cursors: []string{"users_cursor"}}
+
+		_, err = cursoriterator.NewRefCursorIterator(context.Background(), nil, "users_cursor", make([]User, 2), nil)
+		require.EqualError(t, err, "tx cannot be nil")
+		_, err = cursoriterator.NewRefCursorIterator(context.Background(), cursors, "", make([]User, 2), nil)
+		require.EqualError(t, err, "cursor name cannot be empty")
+		_, err = cursoriterator.NewRefCursorIterator(context.Background(), cursors, "users_cursor", nil, nil)
+		require.EqualError(t, err, "values cannot be nil")
+		_, err = cursoriterator.NewRefCursorIterator(context.Background(), cursors, "unknown", make([]User, 2), nil)
+		require.EqualError(t, err, `cursor "unknown" does not exist`)
+
+		tests := []struct {
+			option cursoriterator.Option
+			err    string
+		}{
+			{cursoriterator.WithCursorName("other"), "ref cursor iterator cannot be used with cursor name"},
+			{cursoriterator.WithHold(), "ref cursor iterator cannot be used with hold"},
+			{cursoriterator.WithScroll(), "ref cursor iterator cannot be used with scroll"},
+			{cursoriterator.WithCommitPerBatch("id"), "ref cursor iterator cannot be used with commit per batch"},
+			{cursoriterator.WithReadOnly(), "ref cursor iterator cannot be used with transaction options"},
+			{cursoriterator.WithOrderBy("id"), "ref cursor iterator cannot be used with query options"},
+			{cursoriterator.WithValidateQuery(time.Minute), "ref cursor iterator cannot be used with validate query"},
+		}
+		for _, test := range tests {
+			_, err := cursoriterator.NewRefCursorIterator(
+				context.Background(), cursors, "users_cursor", make([]User, 2), []cursoriterator.Option{test.option},
+			)
+			require.EqualError(t, err, test.err)
+		}
+	})
+
+	t.Run("database", func(t *testing.T) {
+		t.Parallel()
+		runTest(t, []User{{1, "Joe"}, {2, "Alice"}, {3, "Bob"}}, func(pool *pgxpool.Pool) {
+			ctx := context.Background()
+			_, err := pool.Exec(ctx, `
+CREATE FUNCTION users_after(min_id integer) RETURNS refcursor AS $$
+DECLARE
+	result refcursor;
+BEGIN
+	OPEN result FOR SELECT * FROM users WHERE id > min_id ORDER BY id;
+	RETURN result;
+END;
+$$ LANGUAGE plpgsql`)
+			require.NoError(t, err)
+
+			tx, err := pool.Begin(ctx)
+			require.NoError(t, err)
+			defer tx.Rollback(ctx)
+			var cursorName string
+			require.NoError(t, tx.QueryRow(ctx, "SELECT users_after($1)", 1).Scan(&cursorName))
+
+			values := make([]User, 1)
+			iter, err := cursoriterator.NewRefCursorIterator(ctx, tx, cursorName, values, nil)
+			require.NoError(t, err)
+			expectValues(t, iter, values, User{2, "Alice"}, User{3, "Bob"})
+			require.NoError(t, iter.Close(ctx))
+
+			// the transaction can still be used
+			var count int
+			require.NoError(t, tx.QueryRow(ctx, "SELECT COUNT(*) FROM pg_cursors WHERE name = $1", cursorName).Scan(&count))
+			require.Equal(t, 0, count)
+		})
+	})
+}
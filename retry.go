@@ -0,0 +1,65 @@
+package cursoriterator
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// retryState is the configuration of WithRetry().
+type retryState struct {
+	attempts   int
+	backoff    time.Duration
+	classifier func(err error) bool
+}
+
+// WithRetry retries starting the transaction and declaring the cursor up to maxAttempts times (including the
+// first attempt) if it failed with a retryable error, waiting backoff between the attempts.
+// Which errors are retryable is decided by the classifier, see WithRetryClassifier() and DefaultRetryClassifier().
+//
+// Fetches are not retried: a failed statement aborts the transaction, so the cursor can not be used anymore.
+func WithRetry(maxAttempts int, backoff time.Duration) Option {
+	return func(iter *CursorIterator) error {
+		if maxAttempts <= 0 {
+			return errors.New("max attempts must be bigger than 0")
+		}
+		if backoff < 0 {
+			return errors.New("backoff cannot be negative")
+		}
+		iter.retry.attempts = maxAttempts
+		iter.retry.backoff = backoff
+		return nil
+	}
+}
+
+// WithRetryClassifier replaces DefaultRetryClassifier(), fn reports whether an error is retryable.
+// It is only used if WithRetry() is used as well.
+func WithRetryClassifier(fn func(err error) bool) Option {
+	return func(iter *CursorIterator) error {
+		if fn == nil {
+			return errors.New("retry classifier cannot be nil")
+		}
+		iter.retry.classifier = fn
+		return nil
+	}
+}
+
+// DefaultRetryClassifier reports serialization failures (40001), deadlocks (40P01) and lost connections
+// as retryable.
+func DefaultRetryClassifier(err error) bool {
+	if code, ok := SQLState(err); ok && (code == "40001" || code == "40P01") {
+		return true
+	}
+	return isConnectionLost(err)
+}
+
+// shouldRetry reports whether the operation that failed with err in the given attempt should be retried.
+func (iter *CursorIterator) shouldRetry(attempt int, err error) bool {
+	if attempt >= iter.retry.attempts {
+		return false
+	}
+	if iter.retry.classifier != nil {
+		return iter.retry.classifier(err)
+	}
+	return DefaultRetryClassifier(err)
+}
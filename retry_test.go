@@ -0,0 +1,119 @@
+package cursoriterator_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+
+	cursoriterator "github.com/Eun/go-pgx-cursor-iterator/v2"
+	"github.com/Eun/go-pgx-cursor-iterator/v2/cursoriteratortest"
+)
+
+func TestWithRetry(t *testing.T) {
+	t.Parallel()
+
+	// newConnector fails the first failures calls to Begin with a *pgconn.PgError with the given code,
+	// the returned counter counts the calls to Begin.
+	newConnector := func(code string, failures int) (*cursoriteratortest.Connector, *int) {
+		connector := cursoriteratortest.NewConnector(
+			[]string{"id", "name"},
+			[]interface{}{1, "Joe"},
+		)
+		begins := new(int)
+		connector.OnBegin = func(context.Context) error {
+			*begins++
+			if *begins <= failures {
+				return &pgconn.PgError{Code: code}
+			}
+			return nil
+		}
+		return connector, begins
+	}
+
+	run := func(connector *cursoriteratortest.Connector, options ...cursoriterator.Option) *cursoriterator.TypedCursorIterator[User] {
+		iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](connector, 2, options, "SELECT * FROM users")
+		require.NoError(t, err)
+		return iter
+	}
+
+	t.Run("default classifier", func(t *testing.T) {
+		t.Parallel()
+		connector, begins := newConnector("40001", 2)
+		iter := run(connector, cursoriterator.WithRetry(3, time.Millisecond))
+		require.True(t, iter.Next(context.Background()))
+		require.Equal(t, User{1, "Joe"}, iter.Value())
+		require.NoError(t, iter.Close(context.Background()))
+		require.Equal(t, 3, *begins)
+	})
+
+	t.Run("default classifier does not retry other errors", func(t *testing.T) {
+		t.Parallel()
+		connector, begins := newConnector("55P03", 1)
+		iter := run(connector, cursoriterator.WithRetry(3, time.Millisecond))
+		require.False(t, iter.Next(context.Background()))
+		require.Error(t, iter.Error())
+		require.Equal(t, 1, *begins)
+	})
+
+	t.Run("default classifier does not retry context errors", func(t *testing.T) {
+		t.Parallel()
+		// context errors satisfy net.Error, but are not caused by a lost connection
+		require.False(t, cursoriterator.DefaultRetryClassifier(context.DeadlineExceeded))
+		require.False(t, cursoriterator.DefaultRetryClassifier(context.Canceled))
+	})
+
+	t.Run("custom classifier", func(t *testing.T) {
+		t.Parallel()
+		connector, begins := newConnector("55P03", 2)
+		iter := run(
+			connector,
+			cursoriterator.WithRetry(3, time.Millisecond),
+			cursoriterator.WithRetryClassifier(func(err error) bool {
+				var pgErr *pgconn.PgError
+				return errors.As(err, &pgErr) && pgErr.Code == "55P03"
+			}),
+		)
+		require.True(t, iter.Next(context.Background()))
+		require.NoError(t, iter.Close(context.Background()))
+		require.Equal(t, 3, *begins)
+	})
+
+	t.Run("classifier that retries nothing", func(t *testing.T) {
+		t.Parallel()
+		connector, begins := newConnector("40001", 1)
+		iter := run(
+			connector,
+			cursoriterator.WithRetry(3, time.Millisecond),
+			cursoriterator.WithRetryClassifier(func(error) bool { return false }),
+		)
+		require.False(t, iter.Next(context.Background()))
+		var pgErr *pgconn.PgError
+		require.True(t, errors.As(iter.Error(), &pgErr))
+		require.Equal(t, "40001", pgErr.Code)
+		require.Equal(t, 1, *begins)
+	})
+
+	t.Run("attempts exhausted", func(t *testing.T) {
+		t.Parallel()
+		connector, begins := newConnector("40001", 5)
+		iter := run(connector, cursoriterator.WithRetry(2, time.Millisecond))
+		require.False(t, iter.Next(context.Background()))
+		require.Error(t, iter.Error())
+		require.Equal(t, 2, *begins)
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		t.Parallel()
+		connector, _ := newConnector("40001", 0)
+		_, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+			connector, 2, []cursoriterator.Option{cursoriterator.WithRetry(0, 0)}, "SELECT * FROM users")
+		require.EqualError(t, err, "max attempts must be bigger than 0")
+		_, err = cursoriterator.NewTypedCursorIteratorWithOptions[User](
+			connector, 2, []cursoriterator.Option{cursoriterator.WithRetryClassifier(nil)}, "SELECT * FROM users")
+		require.EqualError(t, err, "retry classifier cannot be nil")
+	})
+}
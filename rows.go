@@ -0,0 +1,217 @@
+package cursoriterator
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pkg/errors"
+)
+
+// Rows returns the remaining rows of the iterator as pgx.Rows, so the iterator can replace a conn.Query() call in
+// code that consumes pgx.Rows (e.g. pgx.CollectRows() or pgxscan.ScanAll()). The batches are fetched transparently
+// while the rows are read, the batch size is the capacity of values.
+//
+// The rows bypass the values of the iterator, do not mix them with Next(). Like pgx.Rows they are closed once Next()
+// returned false, closing the rows closes the iterator with ctx. FieldDescriptions() returns nil until Next() was
+// called. WithLimit(), WithMaxBatches() and WithErrorOnEmpty() are applied like they are for Next().
+// Rows can not be used with WithBufferDepth(), WithPgBouncerCompat(), WithCommitPerBatch() and WithSavepoints().
+func (iter *CursorIterator) Rows(ctx context.Context) pgx.Rows {
+	return &cursorRows{iter: iter, ctx: ctx}
+}
+
+// cursorRows is the pgx.Rows implementation returned by Rows().
+type cursorRows struct {
+	iter *CursorIterator
+	ctx  context.Context
+
+	// rows are the rows of the current batch.
+	rows      pgx.Rows
+	requested int
+	read      int
+	total     int64
+	fields    []pgconn.FieldDescription
+
+	err    error
+	closed bool
+}
+
+func (r *cursorRows) Close() {
+	if r.closed {
+		return
+	}
+	r.closed = true
+	if r.rows != nil {
+		r.rows.Close()
+		r.rows = nil
+	}
+	if err := r.iter.Close(r.ctx); err != nil && r.err == nil {
+		r.err = err
+	}
+}
+
+func (r *cursorRows) Err() error {
+	return r.err
+}
+
+// CommandTag returns the command tag of a SELECT that returned all rows that were read.
+func (r *cursorRows) CommandTag() pgconn.CommandTag {
+	return pgconn.NewCommandTag(fmt.Sprintf("SELECT %d", r.total))
+}
+
+func (r *cursorRows) FieldDescriptions() []pgconn.FieldDescription {
+	return r.fields
+}
+
+func (r *cursorRows) Next() bool {
+	if r.closed {
+		return false
+	}
+	for {
+		if r.rows == nil {
+			rows, count, err := r.iter.queryBatch(r.ctx)
+			if err != nil {
+				r.fail(err)
+				return false
+			}
+			if rows == nil {
+				r.finish()
+				return false
+			}
+			r.rows, r.requested, r.read = rows, count, 0
+			if fields := rows.FieldDescriptions(); len(fields) > 0 {
+				r.fields = fields
+			}
+		}
+		if r.rows.Next() {
+			r.read++
+			r.total++
+			return true
+		}
+
+		err := r.rows.Err()
+		r.rows.Close()
+		r.rows = nil
+		if err = fetchError(err); err != nil {
+			r.fail(err)
+			return false
+		}
+		r.iter.mu.Lock()
+		r.iter.position += int64(r.read)
+		r.iter.fetchedRows += int64(r.read)
+		r.iter.mu.Unlock()
+		if r.read < r.requested {
+			// a batch with less rows than requested is the last one
+			r.finish()
+			return false
+		}
+	}
+}
+
+// finish closes the rows after the last row was read, see WithErrorOnEmpty().
+func (r *cursorRows) finish() {
+	if r.total > 0 || !r.iter.batch.errorOnEmpty {
+		r.Close()
+		return
+	}
+	r.iter.mu.Lock()
+	r.iter.err = ErrNoRows
+	r.iter.mu.Unlock()
+	r.fail(ErrNoRows)
+}
+
+// fail closes the rows with err.
+func (r *cursorRows) fail(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+	r.Close()
+}
+
+func (r *cursorRows) Scan(dest ...interface{}) error {
+	if r.rows == nil {
+		return errors.New("no current row")
+	}
+	if err := r.rows.Scan(dest...); err != nil {
+		r.fail(err)
+		return err
+	}
+	return nil
+}
+
+func (r *cursorRows) Values() ([]interface{}, error) {
+	if r.rows == nil {
+		return nil, errors.New("no current row")
+	}
+	return r.rows.Values()
+}
+
+func (r *cursorRows) RawValues() [][]byte {
+	if r.rows == nil {
+		return nil
+	}
+	return r.rows.RawValues()
+}
+
+func (r *cursorRows) Conn() *pgx.Conn {
+	if r.rows == nil {
+		return nil
+	}
+	return r.rows.Conn()
+}
+
+// queryBatch starts the iteration if needed and fetches the next batch for Rows().
+// It returns nil rows if the iteration already ended.
+func (iter *CursorIterator) queryBatch(ctx context.Context) (pgx.Rows, int, error) {
+	iter.mu.Lock()
+	defer iter.mu.Unlock()
+	if err := iter.initializeLazy(ctx); err != nil {
+		iter.err = err
+		return nil, 0, err
+	}
+	switch {
+	case iter.bufferDepth > 0:
+		return nil, 0, errors.New("rows cannot be used with buffer depth")
+	case iter.keyset.column != "":
+		return nil, 0, errors.New("rows cannot be used with pgbouncer compat")
+	case iter.commit.column != "":
+		return nil, 0, errors.New("rows cannot be used with commit per batch")
+	case iter.savepoint.enabled:
+		return nil, 0, errors.New("rows cannot be used with savepoints")
+	case iter.valuesPos == -1:
+		return nil, 0, iter.err
+	}
+	if iter.valuesPos == -2 {
+		if err := iter.begin(ctx); err != nil {
+			iter.err = err
+			return nil, 0, err
+		}
+		// the values are not filled, a Next() call must not return them
+		iter.valuesPos = 0
+		iter.valuesMaxPos = 0
+	}
+	count := len(iter.values)
+	if iter.limit > 0 {
+		// do not fetch more rows than allowed by WithLimit()
+		remaining := iter.limit - iter.fetchedRows
+		if remaining <= 0 {
+			return nil, 0, nil
+		}
+		if remaining < int64(count) {
+			count = int(remaining)
+		}
+	}
+	if iter.batch.max > 0 && iter.batch.count >= iter.batch.max {
+		// the previous batch was full, so there may be more rows
+		iter.err = errors.Wrapf(ErrMaxBatchesExceeded, "iteration stopped after %d batches", iter.batch.count)
+		return nil, 0, iter.err
+	}
+	iter.batch.count++
+	rows, err := iter.tx.Query(ctx, iter.buildFetchQuery(fetchForward, count), iter.fetchArgs()...)
+	if err != nil {
+		// fetchError() returns nil for pgx.ErrNoRows, which ends the rows
+		return nil, 0, fetchError(err)
+	}
+	return rows, count, nil
+}
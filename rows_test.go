@@ -0,0 +1,167 @@
+package cursoriterator_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+
+	cursoriterator "github.com/Eun/go-pgx-cursor-iterator/v2"
+)
+
+func TestRows(t *testing.T) {
+	t.Parallel()
+
+	newIterator := func(t *testing.T, rowCount, batchSize int) (*cursoriterator.TypedCursorIterator[User], []User) {
+		connector, users := newUsersConnector(rowCount)
+		iter, err := cursoriterator.NewTypedCursorIterator[User](connector, batchSize, "SELECT * FROM users")
+		require.NoError(t, err)
+		return iter, users
+	}
+
+	t.Run("next and scan", func(t *testing.T) {
+		t.Parallel()
+		for _, rowCount := range []int{0, 1, 5, 6, 7} {
+			iter, users := newIterator(t, rowCount, 3)
+			rows := iter.Rows(context.Background())
+			result := []User{}
+			for rows.Next() {
+				var user User
+				require.NoError(t, rows.Scan(&user.ID, &user.Name))
+				result = append(result, user)
+			}
+			require.NoError(t, rows.Err())
+			require.Equal(t, users, result)
+			require.Equal(t, fmt.Sprintf("SELECT %d", rowCount), rows.CommandTag().String())
+			// the rows closed the iterator
+			require.False(t, iter.Next(context.Background()))
+			rows.Close()
+		}
+	})
+
+	t.Run("collect rows", func(t *testing.T) {
+		t.Parallel()
+		iter, users := newIterator(t, 10, 4)
+		result, err := pgx.CollectRows(iter.Rows(context.Background()), pgx.RowToStructByName[User])
+		require.NoError(t, err)
+		require.Equal(t, users, result)
+	})
+
+	t.Run("scany", func(t *testing.T) {
+		t.Parallel()
+		iter, users := newIterator(t, 10, 3)
+		var result []User
+		require.NoError(t, pgxscan.ScanAll(&result, iter.Rows(context.Background())))
+		require.Equal(t, users, result)
+	})
+
+	t.Run("field descriptions", func(t *testing.T) {
+		t.Parallel()
+		iter, _ := newIterator(t, 2, 3)
+		rows := iter.Rows(context.Background())
+		defer rows.Close()
+		require.Nil(t, rows.FieldDescriptions())
+		require.True(t, rows.Next())
+		require.Len(t, rows.FieldDescriptions(), 2)
+		require.Equal(t, "name", rows.FieldDescriptions()[1].Name)
+		values, err := rows.Values()
+		require.NoError(t, err)
+		require.Equal(t, []interface{}{1, "user1"}, values)
+	})
+
+	t.Run("close early", func(t *testing.T) {
+		t.Parallel()
+		connector, _ := newUsersConnector(10)
+		iter, err := cursoriterator.NewTypedCursorIterator[User](connector, 3, "SELECT * FROM users")
+		require.NoError(t, err)
+		rows := iter.Rows(context.Background())
+		require.True(t, rows.Next())
+		rows.Close()
+		require.NoError(t, rows.Err())
+		require.False(t, rows.Next())
+
+		statements := connector.Statements()
+		require.Equal(t, "ROLLBACK", statements[len(statements)-1])
+	})
+
+	t.Run("fetch error", func(t *testing.T) {
+		t.Parallel()
+		connector, _ := newUsersConnector(10)
+		connector.FetchErr = func(fetch int) error {
+			if fetch == 2 {
+				return errors.New("connection reset")
+			}
+			return nil
+		}
+		iter, err := cursoriterator.NewTypedCursorIterator[User](connector, 3, "SELECT * FROM users")
+		require.NoError(t, err)
+		rows := iter.Rows(context.Background())
+		n := 0
+		for rows.Next() {
+			n++
+		}
+		require.Equal(t, 3, n)
+		require.EqualError(t, rows.Err(), "unable to fetch rows: connection reset")
+	})
+
+	t.Run("scan error", func(t *testing.T) {
+		t.Parallel()
+		iter, _ := newIterator(t, 3, 2)
+		rows := iter.Rows(context.Background())
+		require.True(t, rows.Next())
+		var id string
+		var name int
+		require.Error(t, rows.Scan(&id, &name))
+		require.Error(t, rows.Err())
+		require.False(t, rows.Next())
+	})
+
+	t.Run("options", func(t *testing.T) {
+		t.Parallel()
+		newIterator := func(t *testing.T, rowCount int, options ...cursoriterator.Option) pgx.Rows {
+			connector, _ := newUsersConnector(rowCount)
+			iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](connector, 3, options, "SELECT * FROM users")
+			require.NoError(t, err)
+			return iter.Rows(context.Background())
+		}
+
+		result, err := pgx.CollectRows(newIterator(t, 10, cursoriterator.WithLimit(4)), pgx.RowToStructByName[User])
+		require.NoError(t, err)
+		require.Equal(t, []User{{1, "user1"}, {2, "user2"}, {3, "user3"}, {4, "user4"}}, result)
+
+		rows := newIterator(t, 10, cursoriterator.WithMaxBatches(2))
+		n := 0
+		for rows.Next() {
+			n++
+		}
+		require.Equal(t, 6, n)
+		require.ErrorIs(t, rows.Err(), cursoriterator.ErrMaxBatchesExceeded)
+
+		_, err = pgx.CollectRows(newIterator(t, 0, cursoriterator.WithErrorOnEmpty()), pgx.RowToStructByName[User])
+		require.ErrorIs(t, err, cursoriterator.ErrNoRows)
+	})
+
+	t.Run("statements", func(t *testing.T) {
+		t.Parallel()
+		connector, _ := newUsersConnector(6)
+		iter, err := cursoriterator.NewTypedCursorIterator[User](connector, 3, "SELECT * FROM users")
+		require.NoError(t, err)
+		rows := iter.Rows(context.Background())
+		for rows.Next() {
+		}
+		require.NoError(t, rows.Err())
+
+		var fetches []string
+		for _, statement := range connector.Statements() {
+			if strings.HasPrefix(statement, "FETCH ") {
+				fetches = append(fetches, strings.SplitN(statement, " IN ", 2)[0])
+			}
+		}
+		require.Equal(t, []string{"FETCH FORWARD 3", "FETCH FORWARD 3", "FETCH FORWARD 3"}, fetches)
+	})
+}
@@ -0,0 +1,110 @@
+package cursoriterator
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+)
+
+// savepointState is the state of WithSavepoints().
+type savepointState struct {
+	enabled bool
+	// tx is the transaction the savepoint was created on.
+	tx         pgx.Tx
+	rolledBack int64
+}
+
+// reset forgets the savepoint of the previous transaction and resets the counter of the rolled back batches.
+func (s *savepointState) reset() {
+	s.tx = nil
+	s.rolledBack = 0
+}
+
+// WithSavepoints wraps every batch in a savepoint, so a statement that fails while a batch is processed
+// (e.g. an ExecCurrentOf() that violates a constraint) does not end the iteration.
+// Before the next batch is fetched the savepoint is released. If the transaction was aborted by a failed statement
+// the transaction is rolled back to the savepoint instead: all changes made during the failed batch are undone and
+// the iteration continues with the next batch. RolledBackBatches() returns the amount of batches that were rolled
+// back.
+//
+// Savepoints only help for failures that happen outside the cursor. A FETCH that fails (e.g. because of a division
+// by zero in the query) puts the cursor into a state where it can not be used anymore, so such errors still end
+// the iteration. Every batch costs two additional round trips to the database.
+// WithSavepoints can not be combined with WithBufferDepth() and WithPgBouncerCompat(), Rows() and FetchInto() can
+// not be used.
+func WithSavepoints() Option {
+	return func(iter *CursorIterator) error {
+		iter.savepoint.enabled = true
+		return nil
+	}
+}
+
+// validateSavepoints checks whether the options that were applied can be used together with WithSavepoints().
+func (iter *CursorIterator) validateSavepoints() error {
+	if !iter.savepoint.enabled {
+		return nil
+	}
+	switch {
+	case iter.bufferDepth > 0:
+		return errors.New("savepoints cannot be used with buffer depth")
+	case iter.keyset.column != "":
+		return errors.New("savepoints cannot be used with pgbouncer compat")
+	}
+	return nil
+}
+
+// RolledBackBatches returns the amount of batches that were rolled back to their savepoint because a statement
+// failed during the batch, see WithSavepoints().
+func (iter *CursorIterator) RolledBackBatches() int64 {
+	iter.mu.Lock()
+	n := iter.savepoint.rolledBack
+	iter.mu.Unlock()
+	return n
+}
+
+// savepointIdentifier returns the sanitized name of the savepoint of the iterator.
+func (iter *CursorIterator) savepointIdentifier() string {
+	return pgx.Identifier{"savepoint_" + iter.cursorName}.Sanitize()
+}
+
+// startSavepoint ends the savepoint of the previous batch and creates a savepoint for the next batch.
+func (iter *CursorIterator) startSavepoint(ctx context.Context) error {
+	if !iter.savepoint.enabled {
+		return nil
+	}
+	if iter.savepoint.tx == iter.tx {
+		// the savepoint still exists if the previous batch was rolled back
+		ok, err := iter.endSavepoint(ctx)
+		if err != nil || !ok {
+			return err
+		}
+	}
+	if _, err := iter.tx.Exec(ctx, "SAVEPOINT "+iter.savepointIdentifier()); err != nil {
+		return errors.Wrap(err, "unable to create savepoint")
+	}
+	iter.savepoint.tx = iter.tx
+	return nil
+}
+
+// endSavepoint releases the savepoint of the current batch, or rolls back to it if the transaction was aborted.
+// released reports whether the savepoint was released.
+func (iter *CursorIterator) endSavepoint(ctx context.Context) (released bool, err error) {
+	if !iter.savepoint.enabled || iter.tx == nil || iter.savepoint.tx != iter.tx {
+		return false, nil
+	}
+	_, err = iter.tx.Exec(ctx, "RELEASE SAVEPOINT "+iter.savepointIdentifier())
+	if err == nil {
+		iter.savepoint.tx = nil
+		return true, nil
+	}
+	if !isTransactionAborted(err) {
+		return false, errors.Wrap(err, "unable to release savepoint")
+	}
+	// a statement of the batch failed, undo the batch and continue with the cursor
+	if _, err := iter.tx.Exec(ctx, "ROLLBACK TO SAVEPOINT "+iter.savepointIdentifier()); err != nil {
+		return false, errors.Wrap(err, "unable to roll back to savepoint")
+	}
+	iter.savepoint.rolledBack++
+	return false, nil
+}
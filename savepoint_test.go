@@ -0,0 +1,160 @@
+package cursoriterator_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+
+	cursoriterator "github.com/Eun/go-pgx-cursor-iterator/v2"
+	"github.com/Eun/go-pgx-cursor-iterator/v2/cursoriteratortest"
+)
+
+// abortOnUpdate simulates a transaction that gets aborted by a failing UPDATE statement,
+// all following statements fail until the transaction is rolled back to a savepoint.
+func abortOnUpdate(connector *cursoriteratortest.Connector) *cursoriteratortest.Connector {
+	var aborted bool
+	connector.OnBegin = func(context.Context) error {
+		aborted = false
+		return nil
+	}
+	connector.OnExec = func(ctx context.Context, next cursoriteratortest.ExecFunc, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+		switch {
+		case strings.HasPrefix(sql, "ROLLBACK TO SAVEPOINT "):
+			aborted = false
+		case aborted:
+			return pgconn.CommandTag{}, &pgconn.PgError{Code: "25P02", Message: "current transaction is aborted"}
+		case strings.HasPrefix(sql, "UPDATE "):
+			aborted = true
+			return pgconn.CommandTag{}, &pgconn.PgError{Code: "23505", Message: "duplicate key value"}
+		}
+		return next(ctx, sql, args...)
+	}
+	connector.OnQuery = func(ctx context.Context, next cursoriteratortest.QueryFunc, sql string, args ...interface{}) (pgx.Rows, error) {
+		if aborted {
+			return nil, &pgconn.PgError{Code: "25P02", Message: "current transaction is aborted"}
+		}
+		return next(ctx, sql, args...)
+	}
+	return connector
+}
+
+func TestWithSavepoints(t *testing.T) {
+	t.Parallel()
+
+	t.Run("continues after rollback to savepoint", func(t *testing.T) {
+		t.Parallel()
+		connector, users := newUsersConnector(3)
+		iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+			abortOnUpdate(connector),
+			1,
+			[]cursoriterator.Option{cursoriterator.WithSavepoints()},
+			"SELECT * FROM users",
+		)
+		require.NoError(t, err)
+
+		var result []User
+		for iter.Next(context.Background()) {
+			result = append(result, iter.Value())
+			if iter.Value().ID == 2 {
+				_, err := iter.ExecCurrentOf(context.Background(), "UPDATE users SET id = 1")
+				require.Error(t, err)
+			}
+		}
+		require.NoError(t, iter.Error())
+		require.NoError(t, iter.Close(context.Background()))
+		require.Equal(t, users, result)
+		require.Equal(t, int64(1), iter.RolledBackBatches())
+
+		var savepoints []string
+		for _, statement := range connector.Statements() {
+			if strings.Contains(statement, "SAVEPOINT") || strings.HasPrefix(statement, "UPDATE") {
+				savepoints = append(savepoints, strings.SplitN(statement, " \"", 2)[0])
+			}
+		}
+		require.Equal(t, []string{
+			"SAVEPOINT",
+			"RELEASE SAVEPOINT",
+			"SAVEPOINT",
+			"UPDATE users SET id = 1 WHERE CURRENT OF",
+			"RELEASE SAVEPOINT",
+			// the savepoint is kept for the next batch
+			"ROLLBACK TO SAVEPOINT",
+			"RELEASE SAVEPOINT",
+			"SAVEPOINT",
+		}, savepoints)
+	})
+
+	t.Run("fails without savepoints", func(t *testing.T) {
+		t.Parallel()
+		connector, _ := newUsersConnector(3)
+		iter, err := cursoriterator.NewTypedCursorIterator[User](abortOnUpdate(connector), 1, "SELECT * FROM users")
+		require.NoError(t, err)
+		for iter.Next(context.Background()) {
+			if iter.Value().ID == 2 {
+				_, err := iter.ExecCurrentOf(context.Background(), "UPDATE users SET id = 1")
+				require.Error(t, err)
+			}
+		}
+		require.ErrorIs(t, iter.Error(), cursoriterator.ErrTransactionAborted)
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		t.Parallel()
+		tests := []struct {
+			option cursoriterator.Option
+			err    string
+		}{
+			{cursoriterator.WithBufferDepth(1), "savepoints cannot be used with buffer depth"},
+			{cursoriterator.WithPgBouncerCompat("id"), "savepoints cannot be used with pgbouncer compat"},
+		}
+		for _, test := range tests {
+			connector, _ := newUsersConnector(1)
+			_, err := cursoriterator.NewCursorIteratorWithOptions(
+				connector,
+				make([]User, 2),
+				[]cursoriterator.Option{cursoriterator.WithSavepoints(), test.option},
+				"SELECT * FROM users",
+			)
+			require.EqualError(t, err, test.err)
+		}
+	})
+
+	t.Run("database", func(t *testing.T) {
+		t.Parallel()
+		var users []User
+		for i := 1; i <= 6; i++ {
+			users = append(users, User{ID: i, Name: fmt.Sprintf("user%d", i)})
+		}
+		runTest(t, users, func(pool *pgxpool.Pool) {
+			values := make([]User, 1)
+			iter, err := cursoriterator.NewCursorIteratorWithOptions(
+				pool,
+				values,
+				[]cursoriterator.Option{cursoriterator.WithSavepoints()},
+				"SELECT * FROM users",
+			)
+			require.NoError(t, err)
+
+			var result []User
+			for iter.Next(context.Background()) {
+				user := values[iter.ValueIndex()]
+				result = append(result, user)
+				if user.ID == 3 {
+					// violates the primary key and aborts the transaction
+					_, err := iter.ExecCurrentOf(context.Background(), "UPDATE users SET id = 1")
+					require.Error(t, err)
+				}
+			}
+			require.NoError(t, iter.Error())
+			require.NoError(t, iter.Close(context.Background()))
+			require.Equal(t, users, result)
+			require.Equal(t, int64(1), iter.RolledBackBatches())
+		})
+	})
+}
@@ -0,0 +1,227 @@
+package cursoriterator
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/pkg/errors"
+)
+
+// WithScanConcurrency scans the rows of a batch with n goroutines, which can speed up the iteration of wide rows
+// where decoding the columns dominates.
+// pgx.Rows can not be used concurrently, so the raw values of all rows of a batch are copied into memory first
+// and decoded afterwards, which needs more memory than the sequential scan. Every goroutine uses its own copy of
+// the types known to the connection, so types registered with WithTypeRegistration() can be scanned.
+// If a row can not be scanned the batch ends before that row, rows after it may have been scanned already.
+// WithScanConcurrency can not be combined with WithBufferDepth(), WithPgBouncerCompat() and WithSkipScanErrors().
+func WithScanConcurrency(n int) Option {
+	return func(iter *CursorIterator) error {
+		if n <= 0 {
+			return errors.New("scan concurrency must be bigger than 0")
+		}
+		iter.scanConcurrency = n
+		return nil
+	}
+}
+
+// validateScanConcurrency checks whether the options that were applied can be used together with
+// WithScanConcurrency().
+func (iter *CursorIterator) validateScanConcurrency() error {
+	if iter.scanConcurrency <= 1 {
+		return nil
+	}
+	switch {
+	case iter.bufferDepth > 0:
+		return errors.New("scan concurrency cannot be used with buffer depth")
+	case iter.keyset.column != "":
+		return errors.New("scan concurrency cannot be used with pgbouncer compat")
+	case iter.skipScanErrors != nil:
+		return errors.New("scan concurrency cannot be used with skip scan errors")
+	}
+	return nil
+}
+
+// scanRowsConcurrently works like scanRows() but reads the raw values of all rows first
+// and scans them with iter.scanConcurrency goroutines.
+func (iter *CursorIterator) scanRowsConcurrently(ctx context.Context, rows pgx.Rows, fetchSize int) (int, error) {
+	var fields []pgconn.FieldDescription
+	var raw [][][]byte
+	for rows.Next() {
+		// the fetch size can be smaller than the capacity of values, so guard against the requested amount
+		if len(raw) >= fetchSize {
+			return 0, errors.New("database returned more rows than expected")
+		}
+		if len(raw) > 0 && len(raw)%scanContextCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return 0, err
+			}
+		}
+		if len(raw) == 0 {
+			// the last batch gets overwritten
+			iter.snapshotLen = 0
+			if err := iter.checkSchema(rows.FieldDescriptions()); err != nil {
+				return 0, err
+			}
+			fields = rows.FieldDescriptions()
+		}
+		if iter.commit.column != "" {
+			if err := iter.recordCommitKey(rows); err != nil {
+				return 0, err
+			}
+		}
+		raw = append(raw, copyRawValues(rows.RawValues()))
+	}
+	if rows.Err() != nil || len(raw) == 0 {
+		// the caller handles the error of rows
+		return 0, nil
+	}
+
+	typeMaps := iter.scanTypeMaps(rows.Conn(), fields, len(raw))
+	errs := make([]error, len(raw))
+	var next int64 = -1
+	var wg sync.WaitGroup
+	for _, typeMap := range typeMaps {
+		wg.Add(1)
+		go func(row *bufferedRow) {
+			defer wg.Done()
+			scanner := pgxscan.NewRowScanner(row)
+			for {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= len(raw) {
+					return
+				}
+				row.values = raw[i]
+				errs[i] = scanRow(iter.scanMode, scanner, row, iter.values[i])
+			}
+		}(&bufferedRow{fields: fields, typeMap: typeMap})
+	}
+	wg.Wait()
+
+	for n, err := range errs {
+		if err != nil {
+			iter.partialLen = n
+			return n, scanError(err, rows, n, iter.values[n])
+		}
+	}
+	iter.position += int64(len(raw))
+	return len(raw), nil
+}
+
+// scanTypeMaps returns one type map for every goroutine that scans a batch of the given amount of rows.
+// pgtype.Map caches its scan plans and can not be used concurrently, so every goroutine gets its own map,
+// containing the types of the connection that are needed for fields.
+func (iter *CursorIterator) scanTypeMaps(conn *pgx.Conn, fields []pgconn.FieldDescription, rows int) []*pgtype.Map {
+	workers := iter.scanConcurrency
+	if rows < workers {
+		workers = rows
+	}
+	for len(iter.typeMaps) < workers {
+		iter.typeMaps = append(iter.typeMaps, pgtype.NewMap())
+	}
+	typeMaps := iter.typeMaps[:workers]
+	if conn == nil {
+		return typeMaps
+	}
+	for _, field := range fields {
+		t, ok := conn.TypeMap().TypeForOID(field.DataTypeOID)
+		if !ok {
+			continue
+		}
+		for _, typeMap := range typeMaps {
+			if known, ok := typeMap.TypeForOID(field.DataTypeOID); !ok || known != t {
+				typeMap.RegisterType(t)
+			}
+		}
+	}
+	return typeMaps
+}
+
+// copyRawValues copies values, pgx reuses the memory of the raw values for the next row.
+func copyRawValues(values [][]byte) [][]byte {
+	size := 0
+	for _, value := range values {
+		size += len(value)
+	}
+	buf := make([]byte, 0, size)
+	result := make([][]byte, len(values))
+	for i, value := range values {
+		if value == nil {
+			continue
+		}
+		buf = append(buf, value...)
+		result[i] = buf[len(buf)-len(value) : len(buf) : len(buf)]
+	}
+	return result
+}
+
+// bufferedRow is a pgx.Rows implementation for a single row whose raw values were read into memory,
+// see WithScanConcurrency().
+type bufferedRow struct {
+	fields  []pgconn.FieldDescription
+	typeMap *pgtype.Map
+	values  [][]byte
+}
+
+func (r *bufferedRow) Close() {}
+
+func (r *bufferedRow) Err() error {
+	return nil
+}
+
+func (r *bufferedRow) CommandTag() pgconn.CommandTag {
+	return pgconn.CommandTag{}
+}
+
+func (r *bufferedRow) FieldDescriptions() []pgconn.FieldDescription {
+	return r.fields
+}
+
+func (r *bufferedRow) Next() bool {
+	return false
+}
+
+func (r *bufferedRow) Scan(dest ...interface{}) error {
+	return pgx.ScanRow(r.typeMap, r.fields, r.values, dest...)
+}
+
+// Values decodes the values like pgx does.
+func (r *bufferedRow) Values() ([]interface{}, error) {
+	values := make([]interface{}, len(r.fields))
+	for i := range r.fields {
+		buf := r.values[i]
+		if buf == nil {
+			continue
+		}
+		field := &r.fields[i]
+		if t, ok := r.typeMap.TypeForOID(field.DataTypeOID); ok {
+			value, err := t.Codec.DecodeValue(r.typeMap, field.DataTypeOID, field.Format, buf)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+			continue
+		}
+		switch field.Format {
+		case pgx.TextFormatCode:
+			values[i] = string(buf)
+		case pgx.BinaryFormatCode:
+			values[i] = append([]byte(nil), buf...)
+		default:
+			return nil, errors.New("unknown format code")
+		}
+	}
+	return values, nil
+}
+
+func (r *bufferedRow) RawValues() [][]byte {
+	return r.values
+}
+
+func (r *bufferedRow) Conn() *pgx.Conn {
+	return nil
+}
@@ -0,0 +1,230 @@
+package cursoriterator_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+
+	cursoriterator "github.com/Eun/go-pgx-cursor-iterator/v2"
+	"github.com/Eun/go-pgx-cursor-iterator/v2/cursoriteratortest"
+)
+
+// typedRows reports the type oids of the columns, so the raw (text) values of the in-memory connector
+// can be decoded by pgx.
+type typedRows struct {
+	pgx.Rows
+	oids []uint32
+}
+
+func (r *typedRows) FieldDescriptions() []pgconn.FieldDescription {
+	fields := r.Rows.FieldDescriptions()
+	for i := range fields {
+		fields[i].DataTypeOID = r.oids[i]
+		fields[i].Format = pgx.TextFormatCode
+	}
+	return fields
+}
+
+// newTypedUsersConnector returns a users connector that reports the returned oids for the columns,
+// the oids can be changed to simulate a change of the schema.
+func newTypedUsersConnector(n int) (*cursoriteratortest.Connector, []uint32, []User) {
+	connector, users := newUsersConnector(n)
+	oids := []uint32{pgtype.Int8OID, pgtype.TextOID}
+	connector.OnQuery = func(ctx context.Context, next cursoriteratortest.QueryFunc, sql string, args ...interface{}) (pgx.Rows, error) {
+		rows, err := next(ctx, sql, args...)
+		if err != nil {
+			return nil, err
+		}
+		return &typedRows{Rows: rows, oids: oids}, nil
+	}
+	return connector, oids, users
+}
+
+func TestWithScanConcurrency(t *testing.T) {
+	t.Parallel()
+
+	for _, concurrency := range []int{1, 2, 4, 16} {
+		for _, rowCount := range []int{0, 1, 7, 100} {
+			concurrency, rowCount := concurrency, rowCount
+			t.Run(fmt.Sprintf("concurrency %d rows %d", concurrency, rowCount), func(t *testing.T) {
+				t.Parallel()
+				connector, _, users := newTypedUsersConnector(rowCount)
+				iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+					connector,
+					8,
+					[]cursoriterator.Option{cursoriterator.WithScanConcurrency(concurrency)},
+					"SELECT * FROM users",
+				)
+				require.NoError(t, err)
+
+				result := []User{}
+				for iter.Next(context.Background()) {
+					result = append(result, iter.Value())
+				}
+				require.NoError(t, iter.Error())
+				require.NoError(t, iter.Close(context.Background()))
+				if rowCount == 0 {
+					users = []User{}
+				}
+				require.Equal(t, users, result)
+			})
+		}
+	}
+
+	t.Run("scan modes", func(t *testing.T) {
+		t.Parallel()
+		for _, mode := range []cursoriterator.ScanMode{cursoriterator.ScanModeMap, cursoriterator.ScanModeSlice} {
+			connector, _, _ := newTypedUsersConnector(5)
+			values := make([]interface{}, 2)
+			iter, err := cursoriterator.NewCursorIteratorWithOptions(
+				connector,
+				values,
+				[]cursoriterator.Option{cursoriterator.WithScanMode(mode), cursoriterator.WithScanConcurrency(2)},
+				"SELECT * FROM users",
+			)
+			require.NoError(t, err)
+
+			var result []interface{}
+			for iter.Next(context.Background()) {
+				result = append(result, values[iter.ValueIndex()])
+			}
+			require.NoError(t, iter.Error())
+			require.Len(t, result, 5)
+			if mode == cursoriterator.ScanModeMap {
+				require.Equal(t, map[string]interface{}{"id": int64(5), "name": "user5"}, result[4])
+			} else {
+				require.Equal(t, []interface{}{int64(5), "user5"}, result[4])
+			}
+		}
+	})
+
+	t.Run("scan error", func(t *testing.T) {
+		t.Parallel()
+		type invalidUser struct {
+			ID   int
+			Name int
+		}
+		connector, _, _ := newTypedUsersConnector(5)
+		iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[invalidUser](
+			connector,
+			4,
+			[]cursoriterator.Option{cursoriterator.WithScanConcurrency(2)},
+			"SELECT * FROM users",
+		)
+		require.NoError(t, err)
+		require.False(t, iter.Next(context.Background()))
+		require.ErrorContains(t, iter.Error(), `unable to scan row 0 of the batch into values element, column "name"`)
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		t.Parallel()
+		tests := []struct {
+			options []cursoriterator.Option
+			err     string
+		}{
+			{[]cursoriterator.Option{cursoriterator.WithScanConcurrency(0)}, "scan concurrency must be bigger than 0"},
+			{
+				[]cursoriterator.Option{cursoriterator.WithScanConcurrency(2), cursoriterator.WithBufferDepth(1)},
+				"scan concurrency cannot be used with buffer depth",
+			},
+			{
+				[]cursoriterator.Option{cursoriterator.WithScanConcurrency(2), cursoriterator.WithPgBouncerCompat("id")},
+				"scan concurrency cannot be used with pgbouncer compat",
+			},
+			{
+				[]cursoriterator.Option{
+					cursoriterator.WithScanConcurrency(2),
+					cursoriterator.WithSkipScanErrors(func(int64, error) {}),
+				},
+				"scan concurrency cannot be used with skip scan errors",
+			},
+		}
+		for _, test := range tests {
+			_, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](&pgxpool.Pool{}, 2, test.options, "SELECT * FROM users")
+			require.EqualError(t, err, test.err)
+		}
+	})
+
+	t.Run("database", func(t *testing.T) {
+		t.Parallel()
+		runTest(t, nil, func(pool *pgxpool.Pool) {
+			iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[WideRow](
+				pool,
+				100,
+				[]cursoriterator.Option{cursoriterator.WithScanConcurrency(4)},
+				wideRowsQuery, 1000,
+			)
+			require.NoError(t, err)
+
+			var i int64
+			for iter.Next(context.Background()) {
+				i++
+				row := iter.Value()
+				require.Equal(t, i, row.ID)
+				require.Equal(t, fmt.Sprintf("name %d", i), row.Name)
+				require.Equal(t, float64(i)/4, row.Amount)
+				require.Equal(t, i%2 == 0, row.Active)
+				require.Equal(t, []string{"a", fmt.Sprint(i)}, row.Tags)
+				require.Equal(t, map[string]interface{}{"id": float64(i)}, row.Data)
+			}
+			require.NoError(t, iter.Error())
+			require.EqualValues(t, 1000, i)
+			require.NoError(t, iter.Close(context.Background()))
+		})
+	})
+}
+
+// WideRow is a row with many columns that are expensive to decode.
+type WideRow struct {
+	ID        int64                  `db:"id"`
+	Name      string                 `db:"name"`
+	Email     string                 `db:"email"`
+	Amount    float64                `db:"amount"`
+	Price     pgtype.Numeric         `db:"price"`
+	Active    bool                   `db:"active"`
+	CreatedAt time.Time              `db:"created_at"`
+	UpdatedAt time.Time              `db:"updated_at"`
+	Tags      []string               `db:"tags"`
+	Scores    []int32                `db:"scores"`
+	Data      map[string]interface{} `db:"data"`
+	UUID      pgtype.UUID            `db:"uuid"`
+	Text      string                 `db:"text"`
+}
+
+const wideRowsQuery = `
+SELECT i::bigint AS id, 'name ' || i AS name, 'user' || i || '@example.com' AS email,
+	i::double precision / 4 AS amount, (i * 1.25)::numeric(12, 2) AS price, i % 2 = 0 AS active,
+	now() - i * interval '1 minute' AS created_at, now() AS updated_at,
+	ARRAY['a', i::text] AS tags, ARRAY[i, i * 2, i * 3]::integer[] AS scores,
+	jsonb_build_object('id', i) AS data, md5(i::text)::uuid AS uuid, repeat('x', 200) AS text
+FROM generate_series(1, $1) i`
+
+func BenchmarkScanConcurrency(b *testing.B) {
+	runTest(b, nil, func(pool *pgxpool.Pool) {
+		for _, concurrency := range []int{1, 2, 4, 8} {
+			concurrency := concurrency
+			b.Run(fmt.Sprintf("concurrency %d", concurrency), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[WideRow](
+						pool,
+						1024,
+						[]cursoriterator.Option{cursoriterator.WithScanConcurrency(concurrency)},
+						wideRowsQuery, 10000,
+					)
+					require.NoError(b, err)
+					for iter.Next(context.Background()) {
+					}
+					require.NoError(b, iter.Error())
+					require.NoError(b, iter.Close(context.Background()))
+				}
+			})
+		}
+	})
+}
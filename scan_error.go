@@ -0,0 +1,77 @@
+package cursoriterator
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/georgysavva/scany/v2/dbscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+)
+
+// scanError wraps the error of a failed scan with the index of the row in the batch and, if known,
+// the column and the field the value should have been scanned into.
+func scanError(err error, rows pgx.Rows, rowIndex int, dest interface{}) error {
+	msg := fmt.Sprintf("unable to scan row %d of the batch into values element", rowIndex)
+	var argErr pgx.ScanArgError
+	if errors.As(err, &argErr) {
+		fields := rows.FieldDescriptions()
+		if argErr.ColumnIndex >= 0 && argErr.ColumnIndex < len(fields) {
+			column := fields[argErr.ColumnIndex].Name
+			msg += fmt.Sprintf(", column %q", column)
+			if field := fieldForColumn(reflect.TypeOf(dest), column); field != "" {
+				msg += " into field " + field
+			}
+		}
+	}
+	return errors.Wrap(err, msg)
+}
+
+// fieldForColumn returns the name of the struct field (e.g. User.Name) the column is mapped to by scany,
+// or an empty string if t is not a struct or has no field for the column.
+func fieldForColumn(t reflect.Type, column string) string {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return ""
+	}
+	path := structFieldForColumn(t, column)
+	if path == "" {
+		return ""
+	}
+	return t.Name() + "." + path
+}
+
+// structFieldForColumn searches the field for column in the struct t, including embedded structs.
+func structFieldForColumn(t reflect.Type, column string) string {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := strings.Split(field.Tag.Get("db"), ",")[0]
+		if tag == "-" {
+			continue
+		}
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && tag == "" && fieldType.Kind() == reflect.Struct {
+			if path := structFieldForColumn(fieldType, column); path != "" {
+				return field.Name + "." + path
+			}
+			continue
+		}
+		name := tag
+		if name == "" {
+			name = dbscan.SnakeCaseMapper(field.Name)
+		}
+		if name == column {
+			return field.Name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,78 @@
+package cursoriterator_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+
+	cursoriterator "github.com/Eun/go-pgx-cursor-iterator/v2"
+	"github.com/Eun/go-pgx-cursor-iterator/v2/cursoriteratortest"
+)
+
+func TestScanError(t *testing.T) {
+	t.Parallel()
+
+	t.Run("column and field", func(t *testing.T) {
+		t.Parallel()
+		connector := cursoriteratortest.NewConnector(
+			[]string{"id", "name"},
+			[]interface{}{1, "Joe"},
+			[]interface{}{2, "Alice"},
+			[]interface{}{3, nil},
+		)
+		iter, err := cursoriterator.NewTypedCursorIterator[User](connector, 2, "SELECT * FROM users")
+		require.NoError(t, err)
+		for iter.Next(context.Background()) {
+		}
+		require.EqualError(t, iter.Error(),
+			`unable to scan row 0 of the batch into values element, column "name" into field User.Name: `+
+				`doing scan: scanFn: scany: scan row into struct fields: can't scan into dest[1]: cannot scan NULL into string`)
+		var argErr pgx.ScanArgError
+		require.True(t, errors.As(iter.Error(), &argErr))
+		require.Equal(t, 1, argErr.ColumnIndex)
+	})
+
+	t.Run("embedded field", func(t *testing.T) {
+		t.Parallel()
+		type Audit struct {
+			CreatedBy string `db:"created_by"`
+		}
+		type Document struct {
+			ID int `db:"id"`
+			Audit
+		}
+		connector := cursoriteratortest.NewConnector(
+			[]string{"id", "created_by"},
+			[]interface{}{1, "Joe"},
+			[]interface{}{2, nil},
+		)
+		iter, err := cursoriterator.NewTypedCursorIterator[Document](connector, 5, "SELECT * FROM documents")
+		require.NoError(t, err)
+		for iter.Next(context.Background()) {
+		}
+		require.ErrorContains(t, iter.Error(),
+			`unable to scan row 1 of the batch into values element, column "created_by" into field Document.Audit.CreatedBy`)
+	})
+
+	t.Run("type mismatch", func(t *testing.T) {
+		t.Parallel()
+		runTest(
+			t,
+			[]User{
+				{1, "Joe"},
+			},
+			func(pool *pgxpool.Pool) {
+				values := make([]User, 2)
+				iter, err := cursoriterator.NewCursorIterator(pool, values, "SELECT id, now() AS name FROM users")
+				require.NoError(t, err)
+				require.False(t, iter.Next(context.Background()))
+				require.ErrorContains(t, iter.Error(),
+					`unable to scan row 0 of the batch into values element, column "name" into field User.Name`)
+				_ = iter.Close(context.Background())
+			})
+	})
+}
@@ -0,0 +1,81 @@
+package cursoriterator
+
+import (
+	"reflect"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+)
+
+// ScanMode defines how a row is scanned into an element of a []interface{} values slice, see WithScanMode().
+type ScanMode int
+
+const (
+	// ScanModeStruct scans the rows into the elements of values as they are, this is the default.
+	ScanModeStruct ScanMode = iota
+	// ScanModeMap scans every row into a map[string]interface{} with the column names as keys.
+	ScanModeMap
+	// ScanModeSlice scans every row into a []interface{} containing the column values in the order of the columns.
+	ScanModeSlice
+	// scanModeRaw stores a copy of the raw column values of every row, it is used by NewRawIterator().
+	scanModeRaw
+)
+
+// WithScanMode lets the iterator scan rows of arbitrary queries into values of type []interface{}:
+// every element will be a map[string]interface{} (ScanModeMap) or a []interface{} (ScanModeSlice).
+// It is required if the elements of values are of type interface{}.
+func WithScanMode(mode ScanMode) Option {
+	return func(iter *CursorIterator) error {
+		switch mode {
+		case ScanModeStruct, ScanModeMap, ScanModeSlice:
+		default:
+			return errors.Errorf("unknown scan mode %d", mode)
+		}
+		iter.scanMode = mode
+		return nil
+	}
+}
+
+// interfaceType is the type of interface{}.
+var interfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+
+// validateScanMode checks whether the scan mode matches the element type of values.
+func (iter *CursorIterator) validateScanMode() error {
+	if iter.scanMode == scanModeRaw {
+		return nil
+	}
+	isInterface := reflect.TypeOf(iter.valuesRef).Elem() == interfaceType
+	if isInterface && iter.scanMode == ScanModeStruct {
+		return errors.New("values of type []interface{} require a map or slice scan mode")
+	}
+	if !isInterface && iter.scanMode != ScanModeStruct {
+		return errors.Errorf("scan mode requires values of type []interface{}, got %T", iter.valuesRef)
+	}
+	return nil
+}
+
+// scanRow scans the current row of rows into dest according to mode.
+func scanRow(mode ScanMode, scanner *pgxscan.RowScanner, rows pgx.Rows, dest interface{}) error {
+	switch mode {
+	case ScanModeMap:
+		m := make(map[string]interface{})
+		if err := scanner.Scan(&m); err != nil {
+			return err
+		}
+		*dest.(*interface{}) = m
+		return nil
+	case scanModeRaw:
+		*dest.(*[][]byte) = copyRawValues(rows.RawValues())
+		return nil
+	case ScanModeSlice:
+		values, err := rows.Values()
+		if err != nil {
+			return err
+		}
+		*dest.(*interface{}) = values
+		return nil
+	default:
+		return scanner.Scan(dest)
+	}
+}
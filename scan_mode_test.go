@@ -0,0 +1,129 @@
+package cursoriterator_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+
+	cursoriterator "github.com/Eun/go-pgx-cursor-iterator/v2"
+	"github.com/Eun/go-pgx-cursor-iterator/v2/cursoriteratortest"
+)
+
+func TestWithScanMode(t *testing.T) {
+	t.Parallel()
+
+	newConnector := func() *cursoriteratortest.Connector {
+		return cursoriteratortest.NewConnector(
+			[]string{"id", "name", "active"},
+			[]interface{}{int64(1), "Joe", true},
+			[]interface{}{int64(2), "Alice", nil},
+			[]interface{}{int64(3), "Bob", false},
+		)
+	}
+
+	t.Run("map", func(t *testing.T) {
+		t.Parallel()
+		values := make([]interface{}, 2)
+		iter, err := cursoriterator.NewCursorIteratorWithOptions(
+			newConnector(),
+			values,
+			[]cursoriterator.Option{cursoriterator.WithScanMode(cursoriterator.ScanModeMap)},
+			"SELECT id, name, active FROM users",
+		)
+		require.NoError(t, err)
+		expectValues[interface{}](t, iter, values,
+			map[string]interface{}{"id": int64(1), "name": "Joe", "active": true},
+			map[string]interface{}{"id": int64(2), "name": "Alice", "active": nil},
+			map[string]interface{}{"id": int64(3), "name": "Bob", "active": false},
+		)
+		require.NoError(t, iter.Close(context.Background()))
+	})
+
+	t.Run("slice", func(t *testing.T) {
+		t.Parallel()
+		values := make([]interface{}, 2)
+		iter, err := cursoriterator.NewCursorIteratorWithOptions(
+			newConnector(),
+			values,
+			[]cursoriterator.Option{cursoriterator.WithScanMode(cursoriterator.ScanModeSlice)},
+			"SELECT id, name, active FROM users",
+		)
+		require.NoError(t, err)
+		expectValues[interface{}](t, iter, values,
+			[]interface{}{int64(1), "Joe", true},
+			[]interface{}{int64(2), "Alice", nil},
+			[]interface{}{int64(3), "Bob", false},
+		)
+		require.NoError(t, iter.Close(context.Background()))
+	})
+
+	t.Run("typed", func(t *testing.T) {
+		t.Parallel()
+		iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[any](
+			newConnector(),
+			2,
+			[]cursoriterator.Option{cursoriterator.WithScanMode(cursoriterator.ScanModeSlice)},
+			"SELECT id, name, active FROM users",
+		)
+		require.NoError(t, err)
+		var rows []any
+		for iter.Next(context.Background()) {
+			rows = append(rows, iter.Value())
+		}
+		require.NoError(t, iter.Error())
+		require.Equal(t, []any{
+			[]interface{}{int64(1), "Joe", true},
+			[]interface{}{int64(2), "Alice", nil},
+			[]interface{}{int64(3), "Bob", false},
+		}, rows)
+	})
+
+	t.Run("invalid combinations", func(t *testing.T) {
+		t.Parallel()
+		_, err := cursoriterator.NewCursorIterator(newConnector(), make([]interface{}, 2), "SELECT * FROM users")
+		require.EqualError(t, err, "values of type []interface{} require a map or slice scan mode")
+
+		_, err = cursoriterator.NewCursorIteratorWithOptions(
+			newConnector(),
+			make([]User, 2),
+			[]cursoriterator.Option{cursoriterator.WithScanMode(cursoriterator.ScanModeMap)},
+			"SELECT * FROM users",
+		)
+		require.EqualError(t, err, "scan mode requires values of type []interface{}, got []cursoriterator_test.User")
+
+		_, err = cursoriterator.NewCursorIteratorWithOptions(
+			newConnector(),
+			make([]interface{}, 2),
+			[]cursoriterator.Option{cursoriterator.WithScanMode(cursoriterator.ScanMode(42))},
+			"SELECT * FROM users",
+		)
+		require.EqualError(t, err, "unknown scan mode 42")
+	})
+
+	t.Run("arbitrary query", func(t *testing.T) {
+		t.Parallel()
+		runTest(
+			t,
+			[]User{
+				{1, "Joe"},
+				{2, "Alice"},
+			},
+			func(pool *pgxpool.Pool) {
+				values := make([]interface{}, 1)
+				iter, err := cursoriterator.NewCursorIteratorWithOptions(
+					pool,
+					values,
+					[]cursoriterator.Option{cursoriterator.WithScanMode(cursoriterator.ScanModeMap)},
+					"SELECT id, upper(name) AS upper_name, id * 2 AS double FROM users ORDER BY id",
+				)
+				require.NoError(t, err)
+				expectValues[interface{}](t, iter, values,
+					map[string]interface{}{"id": int32(1), "upper_name": "JOE", "double": int32(2)},
+					map[string]interface{}{"id": int32(2), "upper_name": "ALICE", "double": int32(4)},
+				)
+				require.NoError(t, iter.Close(context.Background()))
+			})
+	})
+}
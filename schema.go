@@ -0,0 +1,31 @@
+package cursoriterator
+
+import (
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pkg/errors"
+)
+
+// ErrSchemaChanged will be returned when the columns of a batch differ from the columns of the first batch.
+// The snapshot of the transaction protects the cursor against schema changes, but a WITH HOLD cursor (see WithHold())
+// or the queries of WithPgBouncerCompat() can see a table that was altered during the iteration.
+var ErrSchemaChanged = errors.New("schema of the result changed during the iteration")
+
+// checkSchema compares the columns of a batch with the columns of the first batch, the first batch records its columns.
+func (iter *CursorIterator) checkSchema(fields []pgconn.FieldDescription) error {
+	if iter.schema == nil {
+		iter.schema = make([]pgconn.FieldDescription, len(fields))
+		copy(iter.schema, fields)
+		return nil
+	}
+	if len(fields) != len(iter.schema) {
+		return errors.Wrapf(ErrSchemaChanged, "expected %d columns, got %d", len(iter.schema), len(fields))
+	}
+	for i, field := range fields {
+		expected := iter.schema[i]
+		if field.Name != expected.Name || field.DataTypeOID != expected.DataTypeOID {
+			return errors.Wrapf(ErrSchemaChanged, "column %d changed from %q (oid %d) to %q (oid %d)",
+				i, expected.Name, expected.DataTypeOID, field.Name, field.DataTypeOID)
+		}
+	}
+	return nil
+}
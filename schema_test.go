@@ -0,0 +1,89 @@
+package cursoriterator_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+
+	cursoriterator "github.com/Eun/go-pgx-cursor-iterator/v2"
+	"github.com/Eun/go-pgx-cursor-iterator/v2/cursoriteratortest"
+)
+
+// changeSchemaAfterFirstFetch changes the type of the name column after the first fetch, e.g. because of
+// ALTER TABLE users ALTER COLUMN name TYPE varchar.
+func changeSchemaAfterFirstFetch(connector *cursoriteratortest.Connector) {
+	var fetches int32
+	connector.OnQuery = func(ctx context.Context, next cursoriteratortest.QueryFunc, sql string, args ...interface{}) (pgx.Rows, error) {
+		rows, err := next(ctx, sql, args...)
+		if err != nil {
+			return nil, err
+		}
+		oids := []uint32{pgtype.Int8OID, pgtype.TextOID}
+		if atomic.AddInt32(&fetches, 1) > 1 {
+			oids[1] = pgtype.VarcharOID
+		}
+		return &typedRows{Rows: rows, oids: oids}, nil
+	}
+}
+
+func TestSchemaChanged(t *testing.T) {
+	t.Parallel()
+
+	t.Run("type changed", func(t *testing.T) {
+		t.Parallel()
+		connector, oids, users := newTypedUsersConnector(5)
+		values := make([]User, 2)
+		iter, err := cursoriterator.NewCursorIterator(connector, values, "SELECT * FROM users")
+		require.NoError(t, err)
+
+		require.True(t, iter.Next(context.Background()))
+		require.Equal(t, users[0], values[iter.ValueIndex()])
+		require.True(t, iter.Next(context.Background()))
+		// e.g. ALTER TABLE users ALTER COLUMN name TYPE varchar
+		oids[1] = pgtype.VarcharOID
+		require.False(t, iter.Next(context.Background()))
+		require.ErrorIs(t, iter.Error(), cursoriterator.ErrSchemaChanged)
+		require.EqualError(t, iter.Error(),
+			`column 1 changed from "name" (oid 25) to "name" (oid 1043): schema of the result changed during the iteration`)
+	})
+
+	t.Run("buffer depth", func(t *testing.T) {
+		t.Parallel()
+		connector, users := newUsersConnector(5)
+		changeSchemaAfterFirstFetch(connector)
+		values := make([]User, 2)
+		iter, err := cursoriterator.NewCursorIteratorWithOptions(
+			connector,
+			values,
+			[]cursoriterator.Option{cursoriterator.WithBufferDepth(1)},
+			"SELECT * FROM users",
+		)
+		require.NoError(t, err)
+
+		require.True(t, iter.Next(context.Background()))
+		require.Equal(t, users[0], values[iter.ValueIndex()])
+		require.True(t, iter.Next(context.Background()))
+		require.False(t, iter.Next(context.Background()))
+		require.ErrorIs(t, iter.Error(), cursoriterator.ErrSchemaChanged)
+		require.ErrorIs(t, iter.Close(context.Background()), cursoriterator.ErrSchemaChanged)
+	})
+
+	t.Run("rebind", func(t *testing.T) {
+		t.Parallel()
+		connector, oids, users := newTypedUsersConnector(3)
+		values := make([]User, 2)
+		iter, err := cursoriterator.NewCursorIterator(connector, values, "SELECT * FROM users")
+		require.NoError(t, err)
+		require.True(t, iter.Next(context.Background()))
+
+		// the columns of a rebound iterator are recorded again
+		oids[1] = pgtype.VarcharOID
+		require.NoError(t, iter.Rebind(context.Background()))
+		expectValues(t, iter, values, users...)
+		require.NoError(t, iter.Close(context.Background()))
+	})
+}
@@ -0,0 +1,215 @@
+package cursoriterator
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pkg/errors"
+)
+
+// errNotSupportedBySQL will be returned for pgx.Tx functions that are not available through database/sql.
+var errNotSupportedBySQL = errors.New("not supported by database/sql")
+
+// SQLBeginner implements the BeginTx() function from the database/sql package,
+// it is implemented by *sql.DB and *sql.Conn.
+type SQLBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// SQLConnector is a PgxConnector for database/sql, it can be used to run the iterator
+// on a *sql.DB or *sql.Conn that uses the pgx stdlib driver (github.com/jackc/pgx/v5/stdlib).
+//
+// Notice that database/sql does not expose command tags, so LastCommandTag() will always be empty.
+//
+// Example Usage:
+//
+//	db, _ := sql.Open("pgx", "example-connection-url")
+//	values := make([]User, 1000)
+//	iter, err := NewCursorIterator(&SQLConnector{DB: db}, values, "SELECT * FROM users")
+type SQLConnector struct {
+	DB        SQLBeginner
+	TxOptions *sql.TxOptions
+}
+
+// Begin starts a database/sql transaction and returns it as a pgx.Tx.
+func (c *SQLConnector) Begin(ctx context.Context) (pgx.Tx, error) {
+	if c.DB == nil {
+		return nil, errors.New("db cannot be nil")
+	}
+	tx, err := c.DB.BeginTx(ctx, c.TxOptions)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlTx{tx: tx}, nil
+}
+
+// sqlTx wraps a *sql.Tx, so it can be used as a pgx.Tx.
+type sqlTx struct {
+	tx *sql.Tx
+}
+
+func (t *sqlTx) Begin(context.Context) (pgx.Tx, error) {
+	return nil, errNotSupportedBySQL
+}
+
+func (t *sqlTx) Commit(context.Context) error {
+	if err := t.tx.Commit(); err != nil {
+		if errors.Is(err, sql.ErrTxDone) {
+			return pgx.ErrTxClosed
+		}
+		return err
+	}
+	return nil
+}
+
+func (t *sqlTx) Rollback(context.Context) error {
+	if err := t.tx.Rollback(); err != nil {
+		if errors.Is(err, sql.ErrTxDone) {
+			return pgx.ErrTxClosed
+		}
+		return err
+	}
+	return nil
+}
+
+func (t *sqlTx) CopyFrom(context.Context, pgx.Identifier, []string, pgx.CopyFromSource) (int64, error) {
+	return 0, errNotSupportedBySQL
+}
+
+func (t *sqlTx) SendBatch(context.Context, *pgx.Batch) pgx.BatchResults {
+	return errBatchResults{}
+}
+
+func (t *sqlTx) LargeObjects() pgx.LargeObjects {
+	return pgx.LargeObjects{}
+}
+
+func (t *sqlTx) Prepare(context.Context, string, string) (*pgconn.StatementDescription, error) {
+	return nil, errNotSupportedBySQL
+}
+
+func (t *sqlTx) Exec(ctx context.Context, query string, args ...any) (pgconn.CommandTag, error) {
+	if _, err := t.tx.ExecContext(ctx, query, args...); err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	return pgconn.CommandTag{}, nil
+}
+
+func (t *sqlTx) Query(ctx context.Context, query string, args ...any) (pgx.Rows, error) {
+	if len(args) > 0 {
+		// database/sql can not select the result format, see WithResultFormat()
+		if _, ok := args[0].(pgx.QueryResultFormats); ok {
+			args = args[1:]
+		}
+	}
+	rows, err := t.tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlRows{rows: rows}, nil
+}
+
+func (t *sqlTx) QueryRow(ctx context.Context, query string, args ...any) pgx.Row {
+	return t.tx.QueryRowContext(ctx, query, args...)
+}
+
+func (t *sqlTx) Conn() *pgx.Conn {
+	return nil
+}
+
+// sqlRows wraps *sql.Rows, so they can be used as pgx.Rows.
+type sqlRows struct {
+	rows   *sql.Rows
+	fields []pgconn.FieldDescription
+	err    error
+}
+
+func (r *sqlRows) Close() {
+	if err := r.rows.Close(); err != nil && r.err == nil {
+		r.err = err
+	}
+}
+
+func (r *sqlRows) Err() error {
+	if err := r.rows.Err(); err != nil {
+		return err
+	}
+	return r.err
+}
+
+func (r *sqlRows) CommandTag() pgconn.CommandTag {
+	return pgconn.CommandTag{}
+}
+
+func (r *sqlRows) FieldDescriptions() []pgconn.FieldDescription {
+	if r.fields != nil {
+		return r.fields
+	}
+	columns, err := r.rows.Columns()
+	if err != nil {
+		r.err = err
+		return nil
+	}
+	r.fields = make([]pgconn.FieldDescription, len(columns))
+	for i, column := range columns {
+		r.fields[i].Name = column
+	}
+	return r.fields
+}
+
+func (r *sqlRows) Next() bool {
+	return r.rows.Next()
+}
+
+func (r *sqlRows) Scan(dest ...any) error {
+	return r.rows.Scan(dest...)
+}
+
+func (r *sqlRows) Values() ([]any, error) {
+	values := make([]any, len(r.FieldDescriptions()))
+	dest := make([]any, len(values))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := r.rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// RawValues is not supported by database/sql and always returns nil.
+func (r *sqlRows) RawValues() [][]byte {
+	return nil
+}
+
+func (r *sqlRows) Conn() *pgx.Conn {
+	return nil
+}
+
+// errBatchResults is returned by sqlTx.SendBatch, since batches are not supported by database/sql.
+type errBatchResults struct{}
+
+func (errBatchResults) Exec() (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, errNotSupportedBySQL
+}
+
+func (errBatchResults) Query() (pgx.Rows, error) {
+	return nil, errNotSupportedBySQL
+}
+
+func (errBatchResults) QueryRow() pgx.Row {
+	return errRow{}
+}
+
+func (errBatchResults) Close() error {
+	return errNotSupportedBySQL
+}
+
+// errRow is a pgx.Row that always fails with errNotSupportedBySQL.
+type errRow struct{}
+
+func (errRow) Scan(...any) error {
+	return errNotSupportedBySQL
+}
@@ -0,0 +1,52 @@
+package cursoriterator_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+
+	cursoriterator "github.com/Eun/go-pgx-cursor-iterator/v2"
+)
+
+// make sure SQLBeginner implements sql.DB.
+var _ cursoriterator.SQLBeginner = &sql.DB{}
+
+// make sure SQLBeginner implements sql.Conn.
+var _ cursoriterator.SQLBeginner = &sql.Conn{}
+
+func TestSQLConnector(t *testing.T) {
+	t.Parallel()
+	runTest(
+		t,
+		[]User{
+			{1, "Joe"},
+			{2, "Alice"},
+			{3, "Bob"},
+			{4, "Mike"},
+			{5, "Maria"},
+		},
+		func(pool *pgxpool.Pool) {
+			db, err := sql.Open("pgx", pool.Config().ConnString())
+			require.NoError(t, err)
+			defer db.Close()
+
+			values := make([]User, 2)
+			iter, err := cursoriterator.NewCursorIterator(
+				&cursoriterator.SQLConnector{DB: db},
+				values,
+				"SELECT * FROM users WHERE id > $1 ORDER BY id", 1,
+			)
+			require.NoError(t, err)
+
+			expectValues(t, iter, values,
+				User{2, "Alice"},
+				User{3, "Bob"},
+				User{4, "Mike"},
+				User{5, "Maria"},
+			)
+			require.NoError(t, iter.Close(context.Background()))
+		})
+}
@@ -0,0 +1,192 @@
+package cursoriterator
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// TypedCursorIterator is a CursorIterator that manages its own values buffer.
+// It will be returned by NewTypedCursorIterator().
+type TypedCursorIterator[T any] struct {
+	*CursorIterator
+	values []T
+}
+
+// NewTypedCursorIterator can be used to create a new iterator that allocates its own buffer.
+// Required parameters:
+//
+//	connector  most likely a *pgx.Conn or *pgxpool.Pool, needed to start a transaction on the database
+//	batchSize  how many rows should be fetched with one database call
+//	query      the query to fetch the rows
+//	args       arguments for the query
+//
+// Example Usage:
+//
+//	iter, err := NewTypedCursorIterator[User](pool, 1000, "SELECT * FROM users WHERE role = $1", "Guest")
+//	if err != nil {
+//		panic(err)
+//	}
+//	defer iter.Close(ctx)
+//	for iter.Next(ctx) {
+//		fmt.Printf("Name: %s\n", iter.Value().Name)
+//	}
+//	if err := iter.Error(); err != nil {
+//		panic(err)
+//	}
+func NewTypedCursorIterator[T any](
+	connector PgxConnector,
+	batchSize int,
+	query string, args ...interface{},
+) (*TypedCursorIterator[T], error) {
+	return NewTypedCursorIteratorWithOptions[T](connector, batchSize, nil, query, args...)
+}
+
+// NewTypedCursorIteratorWithOptions works like NewTypedCursorIterator() but additionally accepts options
+// to configure the iterator.
+func NewTypedCursorIteratorWithOptions[T any](
+	connector PgxConnector,
+	batchSize int,
+	options []Option,
+	query string, args ...interface{},
+) (*TypedCursorIterator[T], error) {
+	if connector == nil {
+		return nil, errors.New("connector cannot be nil")
+	}
+	if batchSize <= 0 {
+		return nil, errors.New("batch size must be bigger than 0")
+	}
+
+	values := make([]T, batchSize)
+	valuesSlice := scanDestinations(reflect.ValueOf(values))
+
+	iter, err := newCursorIterator(connector, values, valuesSlice, true, options, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return &TypedCursorIterator[T]{
+		CursorIterator: iter,
+		// the buffer might have been replaced by WithBufferPool()
+		values: iter.valuesRef.([]T),
+	}, nil
+}
+
+// Clone returns a new, not yet started iterator with the same configuration (query, args, batch size, options)
+// as the current one. The clone uses its own transaction and its own buffer.
+func (iter *TypedCursorIterator[T]) Clone() (*TypedCursorIterator[T], error) {
+	iter.mu.Lock()
+	args := make([]interface{}, len(iter.args))
+	copy(args, iter.args)
+	iter.mu.Unlock()
+	return NewTypedCursorIteratorWithOptions[T](iter.connector, len(iter.values), iter.options, iter.query, args...)
+}
+
+// Value returns the current value.
+// If there is no current value (Next() was not called or returned false) the zero value of T will be returned.
+func (iter *TypedCursorIterator[T]) Value() T {
+	i := iter.ValueIndex()
+	if i < 0 {
+		var zero T
+		return zero
+	}
+	return iter.values[i]
+}
+
+// ValuePtr returns a pointer to the current value inside the internal buffer, which avoids copying the value.
+// If there is no current value (Next() was not called or returned false) nil will be returned.
+//
+// The buffer is reused for every fetch, so the pointer is only valid until the next call to Next().
+// After that the value it points to will most likely be replaced, copy the value if you need to keep it.
+func (iter *TypedCursorIterator[T]) ValuePtr() *T {
+	i := iter.ValueIndex()
+	if i < 0 {
+		return nil
+	}
+	return &iter.values[i]
+}
+
+// Take advances the iterator up to n times and returns a copy of the values it visited.
+// It returns fewer than n values if the iterator reaches the end, this makes the consumer's chunk size
+// independent of the batch size used to fetch the rows from the database.
+// An empty slice (and no error) means there are no more values.
+func (iter *TypedCursorIterator[T]) Take(ctx context.Context, n int) ([]T, error) {
+	if n <= 0 {
+		return nil, errors.New("n must be bigger than 0")
+	}
+	result := make([]T, 0, n)
+	for len(result) < n {
+		ok, err := iter.NextErr(ctx)
+		if err != nil {
+			return result, err
+		}
+		if !ok {
+			break
+		}
+		result = append(result, iter.Value())
+	}
+	return result, nil
+}
+
+// TakeWithin works like Take() but advances the iterator until d elapsed instead of a fixed number of times, so the
+// amount of returned values adapts to the speed of the database and the consumer.
+// The deadline is checked after every value, a fetch that is in progress when d elapses is not interrupted,
+// so TakeWithin can take longer than d if a new batch must be fetched.
+// An empty slice (and no error) means there are no more values.
+func (iter *TypedCursorIterator[T]) TakeWithin(ctx context.Context, d time.Duration) ([]T, error) {
+	if d <= 0 {
+		return nil, errors.New("d must be bigger than 0")
+	}
+	deadline := time.Now().Add(d)
+	var result []T
+	for {
+		ok, err := iter.NextErr(ctx)
+		if err != nil {
+			return result, err
+		}
+		if !ok {
+			break
+		}
+		result = append(result, iter.Value())
+		if !time.Now().Before(deadline) {
+			break
+		}
+	}
+	if result == nil {
+		result = []T{}
+	}
+	return result, nil
+}
+
+// PartialBatch returns a copy of the values that were scanned successfully before the last fetch failed
+// with a scan error. It returns nil if the last fetch did not fail with a scan error or the buffer was put back into
+// the pool of WithBufferPool().
+// This is intended for debugging only, the values are not returned by Next().
+func (iter *TypedCursorIterator[T]) PartialBatch() []T {
+	iter.mu.Lock()
+	defer iter.mu.Unlock()
+	if iter.partialLen == 0 {
+		return nil
+	}
+	result := make([]T, iter.partialLen)
+	copy(result, iter.values[:iter.partialLen])
+	return result
+}
+
+// Snapshot returns a copy of the values of the last batch that was fetched completely.
+// It can be called after Close(), the buffer is not cleared by Close() and still contains the last batch,
+// since the iteration ends with a fetch that returned no rows. This avoids copying the values inside the loop
+// if only the final batch is needed for post-processing.
+// It returns nil if no batch was fetched, the last fetch failed after it started to overwrite the buffer or
+// the buffer was put back into the pool of WithBufferPool() by Close().
+func (iter *TypedCursorIterator[T]) Snapshot() []T {
+	iter.mu.Lock()
+	defer iter.mu.Unlock()
+	if iter.snapshotLen == 0 {
+		return nil
+	}
+	result := make([]T, iter.snapshotLen)
+	copy(result, iter.values[:iter.snapshotLen])
+	return result
+}
@@ -0,0 +1,402 @@
+package cursoriterator_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+
+	cursoriterator "github.com/Eun/go-pgx-cursor-iterator/v2"
+	"github.com/Eun/go-pgx-cursor-iterator/v2/cursoriteratortest"
+)
+
+func expectTypedValues(t *testing.T, iter *cursoriterator.TypedCursorIterator[User], expected ...User) {
+	for _, user := range expected {
+		require.True(t, iter.Next(context.Background()))
+		require.NoError(t, iter.Error())
+		require.Equal(t, user, iter.Value())
+	}
+	require.False(t, iter.Next(context.Background()))
+	require.NoError(t, iter.Error())
+	require.Equal(t, User{}, iter.Value())
+}
+
+func TestTypedBatchSizes(t *testing.T) {
+	t.Parallel()
+	batchSizes := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+
+	for _, size := range batchSizes {
+		size := size
+		t.Run(fmt.Sprint(size), func(t *testing.T) {
+			t.Parallel()
+			runTest(
+				t,
+				[]User{
+					{1, "Joe"},
+					{2, "Alice"},
+					{3, "Bob"},
+					{4, "Mike"},
+					{5, "Maria"},
+				},
+				func(pool *pgxpool.Pool) {
+					iter, err := cursoriterator.NewTypedCursorIterator[User](pool, size, "SELECT * FROM users")
+					require.NoError(t, err)
+
+					expectTypedValues(t, iter,
+						User{1, "Joe"},
+						User{2, "Alice"},
+						User{3, "Bob"},
+						User{4, "Mike"},
+						User{5, "Maria"},
+					)
+					require.NoError(t, iter.Close(context.Background()))
+				})
+		})
+	}
+}
+
+func TestTypedInvalidConstructorParameters(t *testing.T) {
+	t.Parallel()
+
+	t.Run("connector cannot be nil", func(t *testing.T) {
+		t.Parallel()
+		iter, err := cursoriterator.NewTypedCursorIterator[User](nil, 3, "SELECT * FROM users")
+		require.EqualError(t, err, "connector cannot be nil")
+		require.Nil(t, iter)
+	})
+
+	t.Run("batch size must be bigger than 0", func(t *testing.T) {
+		t.Parallel()
+		iter, err := cursoriterator.NewTypedCursorIterator[User](&pgxpool.Pool{}, 0, "SELECT * FROM users")
+		require.EqualError(t, err, "batch size must be bigger than 0")
+		require.Nil(t, iter)
+	})
+}
+
+func TestTypedClone(t *testing.T) {
+	t.Parallel()
+	runTest(
+		t,
+		[]User{
+			{1, "Joe"},
+			{2, "Alice"},
+			{3, "Bob"},
+		},
+		func(pool *pgxpool.Pool) {
+			iter, err := cursoriterator.NewTypedCursorIterator[User](pool, 2, "SELECT * FROM users ORDER BY id")
+			require.NoError(t, err)
+
+			clone1, err := iter.Clone()
+			require.NoError(t, err)
+			clone2, err := iter.Clone()
+			require.NoError(t, err)
+
+			require.True(t, clone1.Next(context.Background()))
+			require.Equal(t, User{1, "Joe"}, clone1.Value())
+			expectTypedValues(t, clone2,
+				User{1, "Joe"},
+				User{2, "Alice"},
+				User{3, "Bob"},
+			)
+			expectTypedValues(t, clone1,
+				User{2, "Alice"},
+				User{3, "Bob"},
+			)
+			require.NoError(t, clone1.Close(context.Background()))
+			require.NoError(t, clone2.Close(context.Background()))
+			require.NoError(t, iter.Close(context.Background()))
+		})
+}
+
+func TestTypedValuePtr(t *testing.T) {
+	t.Parallel()
+	runTest(
+		t,
+		[]User{
+			{1, "Joe"},
+			{2, "Alice"},
+			{3, "Bob"},
+		},
+		func(pool *pgxpool.Pool) {
+			iter, err := cursoriterator.NewTypedCursorIterator[User](pool, 2, "SELECT * FROM users ORDER BY id")
+			require.NoError(t, err)
+			require.Nil(t, iter.ValuePtr())
+
+			require.True(t, iter.Next(context.Background()))
+			first := iter.ValuePtr()
+			require.Equal(t, &User{1, "Joe"}, first)
+
+			require.True(t, iter.Next(context.Background()))
+			require.Equal(t, &User{2, "Alice"}, iter.ValuePtr())
+
+			// the buffer gets reused, so the first pointer now points to the third value
+			require.True(t, iter.Next(context.Background()))
+			require.Same(t, first, iter.ValuePtr())
+			require.Equal(t, User{3, "Bob"}, *first)
+
+			require.False(t, iter.Next(context.Background()))
+			require.NoError(t, iter.Error())
+			require.Nil(t, iter.ValuePtr())
+			require.NoError(t, iter.Close(context.Background()))
+		})
+}
+
+func TestTypedTake(t *testing.T) {
+	t.Parallel()
+	runTest(
+		t,
+		[]User{
+			{1, "Joe"},
+			{2, "Alice"},
+			{3, "Bob"},
+			{4, "Mike"},
+			{5, "Maria"},
+		},
+		func(pool *pgxpool.Pool) {
+			iter, err := cursoriterator.NewTypedCursorIterator[User](pool, 2, "SELECT * FROM users ORDER BY id")
+			require.NoError(t, err)
+
+			_, err = iter.Take(context.Background(), 0)
+			require.Error(t, err)
+
+			// spans the batch boundary
+			values, err := iter.Take(context.Background(), 3)
+			require.NoError(t, err)
+			require.Equal(t, []User{{1, "Joe"}, {2, "Alice"}, {3, "Bob"}}, values)
+
+			// fewer than n remain
+			values, err = iter.Take(context.Background(), 3)
+			require.NoError(t, err)
+			require.Equal(t, []User{{4, "Mike"}, {5, "Maria"}}, values)
+
+			values, err = iter.Take(context.Background(), 3)
+			require.NoError(t, err)
+			require.Empty(t, values)
+			require.NoError(t, iter.Close(context.Background()))
+		})
+}
+
+func TestTypedPartialBatch(t *testing.T) {
+	t.Parallel()
+	runTest(t, nil, func(pool *pgxpool.Pool) {
+		iter, err := cursoriterator.NewTypedCursorIterator[User](
+			pool,
+			5,
+			"SELECT * FROM (VALUES (1, 'Joe'), (2, 'Alice'), (3, NULL), (4, 'Mike')) AS t(id, name) ORDER BY id",
+		)
+		require.NoError(t, err)
+		require.Nil(t, iter.PartialBatch())
+
+		require.False(t, iter.Next(context.Background()))
+		require.Error(t, iter.Error())
+		require.Equal(t, []User{{1, "Joe"}, {2, "Alice"}}, iter.PartialBatch())
+		require.Error(t, iter.Close(context.Background()))
+	})
+}
+
+func TestTypedSnapshot(t *testing.T) {
+	t.Parallel()
+
+	t.Run("after close", func(t *testing.T) {
+		t.Parallel()
+		connector, users := newUsersConnector(7)
+		iter, err := cursoriterator.NewTypedCursorIterator[User](connector, 3, "SELECT * FROM users")
+		require.NoError(t, err)
+		require.Nil(t, iter.Snapshot())
+
+		require.True(t, iter.Next(context.Background()))
+		require.Equal(t, users[:3], iter.Snapshot())
+
+		for iter.Next(context.Background()) {
+		}
+		require.NoError(t, iter.Error())
+		require.NoError(t, iter.Close(context.Background()))
+		require.Equal(t, users[6:], iter.Snapshot())
+	})
+
+	t.Run("closed while iterating", func(t *testing.T) {
+		t.Parallel()
+		connector, users := newUsersConnector(7)
+		iter, err := cursoriterator.NewTypedCursorIterator[User](connector, 3, "SELECT * FROM users")
+		require.NoError(t, err)
+		for i := 0; i < 4; i++ {
+			require.True(t, iter.Next(context.Background()))
+		}
+		require.NoError(t, iter.Close(context.Background()))
+		require.Equal(t, users[3:6], iter.Snapshot())
+	})
+
+	t.Run("scan error", func(t *testing.T) {
+		t.Parallel()
+		connector := cursoriteratortest.NewConnector(
+			[]string{"id", "name"},
+			[]interface{}{1, "Joe"},
+			[]interface{}{2, "Alice"},
+			[]interface{}{3, "Bob"},
+			[]interface{}{"four", "Mike"},
+		)
+		iter, err := cursoriterator.NewTypedCursorIterator[User](connector, 2, "SELECT * FROM users")
+		require.NoError(t, err)
+		for iter.Next(context.Background()) {
+		}
+		require.Error(t, iter.Error())
+		// the last batch was partially overwritten
+		require.Nil(t, iter.Snapshot())
+	})
+
+	t.Run("buffer pool", func(t *testing.T) {
+		t.Parallel()
+		connector, users := newUsersConnector(2)
+		iter, err := cursoriterator.NewTypedCursorIteratorWithOptions[User](
+			connector,
+			3,
+			[]cursoriterator.Option{cursoriterator.WithBufferPool[User](&sync.Pool{})},
+			"SELECT * FROM users",
+		)
+		require.NoError(t, err)
+		for iter.Next(context.Background()) {
+		}
+		require.Equal(t, users, iter.Snapshot())
+		require.NoError(t, iter.Close(context.Background()))
+		// the buffer was put back into the pool
+		require.Nil(t, iter.Snapshot())
+	})
+}
+
+func TestTypedPointer(t *testing.T) {
+	t.Parallel()
+	runTest(
+		t,
+		[]User{
+			{1, "Joe"},
+			{2, "Alice"},
+			{3, "Bob"},
+		},
+		func(pool *pgxpool.Pool) {
+			iter, err := cursoriterator.NewTypedCursorIterator[*User](pool, 2, "SELECT * FROM users ORDER BY id")
+			require.NoError(t, err)
+
+			var users []User
+			for iter.Next(context.Background()) {
+				users = append(users, *iter.Value())
+			}
+			require.NoError(t, iter.Error())
+			require.Equal(t, []User{{1, "Joe"}, {2, "Alice"}, {3, "Bob"}}, users)
+			require.NoError(t, iter.Close(context.Background()))
+		})
+}
+
+func TestTypedEmbeddedStruct(t *testing.T) {
+	t.Parallel()
+
+	type Timestamps struct {
+		CreatedAt time.Time `db:"created_at"`
+		UpdatedAt time.Time `db:"updated_at"`
+	}
+	type Post struct {
+		ID int `db:"id"`
+		Timestamps
+	}
+
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	updated := time.Date(2024, 2, 3, 4, 5, 6, 0, time.UTC)
+	expected := []Post{
+		{1, Timestamps{created, updated}},
+		{2, Timestamps{created.Add(time.Hour), updated.Add(time.Hour)}},
+		{3, Timestamps{created.Add(2 * time.Hour), updated.Add(2 * time.Hour)}},
+	}
+
+	iterate := func(t *testing.T, connector cursoriterator.PgxConnector) {
+		iter, err := cursoriterator.NewTypedCursorIterator[Post](
+			connector,
+			2,
+			"SELECT id, created_at, updated_at FROM posts ORDER BY id",
+		)
+		require.NoError(t, err)
+
+		var posts []Post
+		for iter.Next(context.Background()) {
+			posts = append(posts, iter.Value())
+		}
+		require.NoError(t, iter.Error())
+		require.NoError(t, iter.Close(context.Background()))
+		require.Len(t, posts, len(expected))
+		for i := range expected {
+			require.Equal(t, expected[i].ID, posts[i].ID)
+			require.True(t, expected[i].CreatedAt.Equal(posts[i].CreatedAt))
+			require.True(t, expected[i].UpdatedAt.Equal(posts[i].UpdatedAt))
+		}
+	}
+
+	t.Run("in memory", func(t *testing.T) {
+		t.Parallel()
+		rows := make([][]interface{}, len(expected))
+		for i, post := range expected {
+			rows[i] = []interface{}{post.ID, post.CreatedAt, post.UpdatedAt}
+		}
+		iterate(t, cursoriteratortest.NewConnector([]string{"id", "created_at", "updated_at"}, rows...))
+	})
+
+	t.Run("database", func(t *testing.T) {
+		t.Parallel()
+		runTest(t, nil, func(pool *pgxpool.Pool) {
+			_, err := pool.Exec(context.Background(), `
+CREATE TABLE posts (
+	id			integer PRIMARY KEY,
+	created_at	timestamptz NOT NULL,
+	updated_at	timestamptz NOT NULL
+)`)
+			require.NoError(t, err)
+			for _, post := range expected {
+				_, err = pool.Exec(context.Background(), "INSERT INTO posts VALUES($1, $2, $3)", post.ID, post.CreatedAt, post.UpdatedAt)
+				require.NoError(t, err)
+			}
+			iterate(t, pool)
+		})
+	})
+}
+
+func TestTypedTakeWithin(t *testing.T) {
+	t.Parallel()
+	const (
+		delay  = 5 * time.Millisecond
+		budget = 30 * time.Millisecond
+	)
+	connector, users := newUsersConnector(20)
+	// every row takes at least delay, because every fetch returns one row
+	connector.OnQuery = func(ctx context.Context, next cursoriteratortest.QueryFunc, sql string, args ...interface{}) (pgx.Rows, error) {
+		time.Sleep(delay)
+		return next(ctx, sql, args...)
+	}
+	iter, err := cursoriterator.NewTypedCursorIterator[User](connector, 1, "SELECT * FROM users")
+	require.NoError(t, err)
+
+	_, err = iter.TakeWithin(context.Background(), 0)
+	require.EqualError(t, err, "d must be bigger than 0")
+
+	start := time.Now()
+	values, err := iter.TakeWithin(context.Background(), budget)
+	require.NoError(t, err)
+	require.NotEmpty(t, values)
+	require.LessOrEqual(t, len(values), int(budget/delay)+1)
+	require.GreaterOrEqual(t, time.Since(start), budget)
+	require.Equal(t, users[:len(values)], values)
+
+	result := values
+	for {
+		values, err := iter.TakeWithin(context.Background(), budget)
+		require.NoError(t, err)
+		if len(values) == 0 {
+			break
+		}
+		require.LessOrEqual(t, len(values), int(budget/delay)+1)
+		result = append(result, values...)
+	}
+	require.Equal(t, users, result)
+	require.NoError(t, iter.Close(context.Background()))
+}
@@ -0,0 +1,57 @@
+package cursoriterator
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// WithValidateQuery lets the constructor validate the query, so syntax errors, unknown tables or columns and a wrong
+// amount of arguments are returned by the constructor instead of the first Next() call.
+// The query is prepared (and deallocated afterwards) in a short-lived transaction, which costs an additional round
+// trip to the database during the construction. The constructors do not accept a context, so the validation is
+// bounded by timeout instead, an unreachable database fails the construction with context.DeadlineExceeded.
+// Iterators created with NewCursorIteratorLazy() validate the query with the context of the first Next() call,
+// also bounded by timeout.
+// The connector must support prepared statements, SQLConnector does not.
+func WithValidateQuery(timeout time.Duration) Option {
+	return func(iter *CursorIterator) error {
+		if timeout <= 0 {
+			return errors.New("validate query timeout must be bigger than 0")
+		}
+		iter.validateQueryTimeout = timeout
+		return nil
+	}
+}
+
+// prepareQuery prepares the query of the iterator in a separate transaction, see WithValidateQuery().
+func (iter *CursorIterator) prepareQuery(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, iter.validateQueryTimeout)
+	defer cancel()
+
+	tx, err := iter.connector.Begin(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to start transaction")
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	query, args := iter.cursorQuery()
+	name := "validate_" + iter.cursorName
+	description, err := tx.Prepare(ctx, name, query)
+	if err != nil {
+		return errors.Wrap(err, "unable to prepare query")
+	}
+	// prepared statements belong to the session and survive the rollback
+	if conn := tx.Conn(); conn != nil {
+		if err := conn.Deallocate(ctx, name); err != nil {
+			return errors.Wrap(err, "unable to deallocate query")
+		}
+	}
+	if len(description.ParamOIDs) != len(args) {
+		return errors.Errorf("query expects %d args, got %d", len(description.ParamOIDs), len(args))
+	}
+	return nil
+}
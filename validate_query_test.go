@@ -0,0 +1,140 @@
+package cursoriterator_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+
+	cursoriterator "github.com/Eun/go-pgx-cursor-iterator/v2"
+	"github.com/Eun/go-pgx-cursor-iterator/v2/cursoriteratortest"
+)
+
+// prepareWith answers Prepare on the connector with the amount of parameters or the error.
+func prepareWith(connector *cursoriteratortest.Connector, params int, err error) *cursoriteratortest.Connector {
+	connector.OnPrepare = func(_ context.Context, name, sql string) (*pgconn.StatementDescription, error) {
+		if err != nil {
+			return nil, err
+		}
+		return &pgconn.StatementDescription{Name: name, SQL: sql, ParamOIDs: make([]uint32, params)}, nil
+	}
+	return connector
+}
+
+func TestWithValidateQuery(t *testing.T) {
+	t.Parallel()
+
+	t.Run("valid query", func(t *testing.T) {
+		t.Parallel()
+		connector, users := newUsersConnector(3)
+		values := make([]User, 2)
+		iter, err := cursoriterator.NewCursorIteratorWithOptions(
+			prepareWith(connector, 1, nil),
+			values,
+			[]cursoriterator.Option{cursoriterator.WithValidateQuery(time.Minute)},
+			"SELECT * FROM users WHERE id > $1", 0,
+		)
+		require.NoError(t, err)
+		// the validation transaction was rolled back
+		require.Equal(t, []string{"BEGIN", "ROLLBACK"}, connector.Statements())
+		expectValues(t, iter, values, users...)
+		require.NoError(t, iter.Close(context.Background()))
+	})
+
+	t.Run("invalid query", func(t *testing.T) {
+		t.Parallel()
+		connector, _ := newUsersConnector(3)
+		pgErr := &pgconn.PgError{Severity: "ERROR", Code: "42601", Message: `syntax error at or near "SELEC"`}
+		iter, err := cursoriterator.NewCursorIteratorWithOptions(
+			prepareWith(connector, 0, pgErr),
+			make([]User, 2),
+			[]cursoriterator.Option{cursoriterator.WithValidateQuery(time.Minute)},
+			"SELEC * FROM users",
+		)
+		require.Nil(t, iter)
+		require.ErrorIs(t, err, pgErr)
+		require.EqualError(t, err, `unable to prepare query: ERROR: syntax error at or near "SELEC" (SQLSTATE 42601)`)
+	})
+
+	t.Run("wrong amount of args", func(t *testing.T) {
+		t.Parallel()
+		connector, _ := newUsersConnector(3)
+		_, err := cursoriterator.NewCursorIteratorWithOptions(
+			prepareWith(connector, 2, nil),
+			make([]User, 2),
+			[]cursoriterator.Option{cursoriterator.WithValidateQuery(time.Minute)},
+			"SELECT * FROM users WHERE id > $1 AND name = $2", 0,
+		)
+		require.EqualError(t, err, "query expects 2 args, got 1")
+	})
+
+	t.Run("unreachable database", func(t *testing.T) {
+		t.Parallel()
+		// Begin blocks until the validation timed out, like a connection attempt to an unreachable database
+		connector := cursoriterator.ConnectorFunc(func(ctx context.Context) (pgx.Tx, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+		_, err := cursoriterator.NewCursorIteratorWithOptions(
+			connector,
+			make([]User, 2),
+			[]cursoriterator.Option{cursoriterator.WithValidateQuery(10 * time.Millisecond)},
+			"SELECT * FROM users",
+		)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		// a lazy iterator validates the query with the context of Next()
+		iter := cursoriterator.NewCursorIteratorLazy(
+			connector,
+			make([]User, 2),
+			[]cursoriterator.Option{cursoriterator.WithValidateQuery(time.Hour)},
+			"SELECT * FROM users",
+		)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		require.False(t, iter.Next(ctx))
+		require.ErrorIs(t, iter.Error(), context.DeadlineExceeded)
+	})
+
+	t.Run("invalid timeout", func(t *testing.T) {
+		t.Parallel()
+		connector, _ := newUsersConnector(3)
+		_, err := cursoriterator.NewCursorIteratorWithOptions(
+			connector,
+			make([]User, 2),
+			[]cursoriterator.Option{cursoriterator.WithValidateQuery(0)},
+			"SELECT * FROM users",
+		)
+		require.EqualError(t, err, "validate query timeout must be bigger than 0")
+	})
+
+	t.Run("database", func(t *testing.T) {
+		t.Parallel()
+		runTest(t, []User{{1, "Joe"}, {2, "Alice"}}, func(pool *pgxpool.Pool) {
+			values := make([]User, 2)
+			iter, err := cursoriterator.NewCursorIteratorWithOptions(
+				pool,
+				values,
+				[]cursoriterator.Option{cursoriterator.WithValidateQuery(time.Minute)},
+				"SELECT * FROM users WHERE id > $1", 0,
+			)
+			require.NoError(t, err)
+			expectValues(t, iter, values, User{1, "Joe"}, User{2, "Alice"})
+			require.NoError(t, iter.Close(context.Background()))
+
+			_, err = cursoriterator.NewCursorIteratorWithOptions(
+				pool,
+				values,
+				[]cursoriterator.Option{cursoriterator.WithValidateQuery(time.Minute)},
+				"SELECT * FROM unknown_table",
+			)
+			var pgErr *pgconn.PgError
+			require.ErrorAs(t, err, &pgErr)
+			require.Equal(t, "42P01", pgErr.Code)
+		})
+	})
+}
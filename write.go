@@ -0,0 +1,66 @@
+package cursoriterator
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// WriteCSV writes all remaining rows of the iterator as CSV to w.
+// If header is not empty it will be written as first record, rowFn converts a row into a record.
+// The output is flushed after every batch, so the rows are streamed to w.
+// WriteCSV does not close the iterator.
+func WriteCSV[T any](
+	ctx context.Context,
+	w io.Writer,
+	iter *TypedCursorIterator[T],
+	header []string,
+	rowFn func(T) []string,
+) error {
+	if rowFn == nil {
+		return errors.New("row function cannot be nil")
+	}
+	writer := csv.NewWriter(w)
+	if len(header) > 0 {
+		if err := writer.Write(header); err != nil {
+			return errors.Wrap(err, "unable to write header")
+		}
+	}
+	batchSize, err := iter.Capacity()
+	if err != nil {
+		return err
+	}
+	rows := 0
+	for iter.Next(ctx) {
+		if err := writer.Write(rowFn(iter.Value())); err != nil {
+			return errors.Wrap(err, "unable to write row")
+		}
+		rows++
+		if rows%batchSize == 0 {
+			writer.Flush()
+			if err := writer.Error(); err != nil {
+				return errors.Wrap(err, "unable to flush rows")
+			}
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	writer.Flush()
+	return errors.Wrap(writer.Error(), "unable to flush rows")
+}
+
+// WriteJSONLines writes all remaining rows of the iterator to w, one JSON object per line.
+// WriteJSONLines does not close the iterator.
+func WriteJSONLines[T any](ctx context.Context, w io.Writer, iter *TypedCursorIterator[T]) error {
+	encoder := json.NewEncoder(w)
+	for iter.Next(ctx) {
+		if err := encoder.Encode(iter.Value()); err != nil {
+			return errors.Wrap(err, "unable to write row")
+		}
+	}
+	return iter.Error()
+}
@@ -0,0 +1,49 @@
+package cursoriterator_test
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	cursoriterator "github.com/Eun/go-pgx-cursor-iterator/v2"
+	"github.com/Eun/go-pgx-cursor-iterator/v2/cursoriteratortest"
+)
+
+func newWriteIterator(t *testing.T) *cursoriterator.TypedCursorIterator[User] {
+	connector := cursoriteratortest.NewConnector(
+		[]string{"id", "name"},
+		[]interface{}{1, "Joe"},
+		[]interface{}{2, "Alice, Bob"},
+		[]interface{}{3, `"Mike"`},
+	)
+	iter, err := cursoriterator.NewTypedCursorIterator[User](connector, 2, "SELECT * FROM users")
+	require.NoError(t, err)
+	return iter
+}
+
+func TestWriteCSV(t *testing.T) {
+	t.Parallel()
+	iter := newWriteIterator(t)
+	var buf bytes.Buffer
+	err := cursoriterator.WriteCSV(context.Background(), &buf, iter, []string{"id", "name"}, func(user User) []string {
+		return []string{strconv.Itoa(user.ID), user.Name}
+	})
+	require.NoError(t, err)
+	require.NoError(t, iter.Close(context.Background()))
+	require.Equal(t, "id,name\n1,Joe\n2,\"Alice, Bob\"\n3,\"\"\"Mike\"\"\"\n", buf.String())
+}
+
+func TestWriteJSONLines(t *testing.T) {
+	t.Parallel()
+	iter := newWriteIterator(t)
+	var buf bytes.Buffer
+	require.NoError(t, cursoriterator.WriteJSONLines(context.Background(), &buf, iter))
+	require.NoError(t, iter.Close(context.Background()))
+	require.Equal(t, `{"ID":1,"Name":"Joe"}
+{"ID":2,"Name":"Alice, Bob"}
+{"ID":3,"Name":"\"Mike\""}
+`, buf.String())
+}